@@ -2,6 +2,7 @@ package main
 
 import (
 	"chunker/internal/domain/usecase"
+	"chunker/internal/repository/kms"
 	psql2 "chunker/internal/repository/psql"
 	"chunker/internal/repository/rabbitmq"
 	"chunker/internal/repository/redis"
@@ -9,6 +10,7 @@ import (
 	"chunker/pkg/client/psql"
 	redisGo "chunker/pkg/client/redis"
 	s3ClientGo "chunker/pkg/client/s3"
+	"chunker/pkg/utils"
 	"context"
 	"github.com/joho/godotenv"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -34,9 +36,20 @@ type Config struct {
 	S3Bucket    string
 	S3AccessKey string
 	S3SecretKey string
+	S3TLS       s3ClientGo.TLSConfig
+	S3SSE       s3.SSEMode
+	// S3KeyStoreKeyFile — мастер-ключ хранилища ключей SSE-C тенантов.
+	S3KeyStoreKeyFile string
 
-	RabbitMQURL string
-	ChunkSize   int
+	RabbitMQURL      string
+	Chunking         utils.ChunkPolicy
+	ChunkCompression utils.Compression
+	ParquetSplit     utils.ParquetSplitMode
+	SchemaSample     int
+	// MasterKeyFile — мастер-ключ для шифрования полей, пусто = без шифрования.
+	MasterKeyFile string
+	// RedactionSecretFile — секрет солей тенантов для hash и tokenize.
+	RedactionSecretFile string
 }
 
 func loadConfig() Config {
@@ -77,9 +90,64 @@ func loadConfig() Config {
 	rmqPort := mustGetEnv("RABBITMQ_PORT")
 	rabbitMQURL := "amqp://" + rmqUser + ":" + rmqPassword + "@" + rmqHost + ":" + rmqPort + "/"
 
+	// S3
+	var s3TLS bool
+	if v := os.Getenv("S3_USE_TLS"); v != "" {
+		s3TLS, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("Invalid S3_USE_TLS value: %v", err)
+		}
+	}
+	s3SSE, err := s3.ParseSSEMode(os.Getenv("S3_SSE"))
+	if err != nil {
+		log.Fatalf("Invalid S3_SSE value: %v", err)
+	}
+	s3KeyStoreKeyFile := os.Getenv("S3_SSE_KEY_STORE_KEY_FILE")
+	if s3SSE == s3.SSEC && s3KeyStoreKeyFile == "" {
+		log.Fatalf("Environment variable S3_SSE_KEY_STORE_KEY_FILE is required for S3_SSE=sse-c")
+	}
+	// ключ SSE-C уходит в заголовках каждого запроса: без TLS он открыт
+	if s3SSE == s3.SSEC && !s3TLS {
+		log.Fatalf("S3_SSE=sse-c requires S3_USE_TLS=true")
+	}
+
 	// CHUNKER ENV
-	chunkSizeStr := mustGetEnv("CHUNKER_CHUNK_SIZE")
-	chunkSize, err := strconv.Atoi(chunkSizeStr)
+	// CHUNKER_CHUNK_SIZE — предел записей, CHUNKER_CHUNK_BYTES — предел
+	// размера чанка; нужен хотя бы один.
+	var chunking utils.ChunkPolicy
+	if v := os.Getenv("CHUNKER_CHUNK_SIZE"); v != "" {
+		chunking.MaxRecords, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("Invalid CHUNKER_CHUNK_SIZE value: %v", err)
+		}
+	}
+	if v := os.Getenv("CHUNKER_CHUNK_BYTES"); v != "" {
+		chunking.MaxBytes, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("Invalid CHUNKER_CHUNK_BYTES value: %v", err)
+		}
+	}
+	if err := chunking.Validate(); err != nil {
+		log.Fatalf("Invalid CHUNKER_CHUNK_SIZE / CHUNKER_CHUNK_BYTES: %v", err)
+	}
+	chunkCompression, err := utils.ParseCompression(os.Getenv("CHUNKER_CHUNK_COMPRESSION"))
+	if err != nil {
+		log.Fatalf("Invalid CHUNKER_CHUNK_COMPRESSION value: %v", err)
+	}
+	if chunkCompression != utils.CompressionNone && chunkCompression != utils.CompressionGzip && chunkCompression != utils.CompressionZstd {
+		log.Fatalf("CHUNKER_CHUNK_COMPRESSION supports only gzip and zstd, got %s", chunkCompression)
+	}
+	parquetSplit, err := utils.ParseParquetSplitMode(os.Getenv("CHUNKER_PARQUET_SPLIT"))
+	if err != nil {
+		log.Fatalf("Invalid CHUNKER_PARQUET_SPLIT value: %v", err)
+	}
+	schemaSample := utils.DefaultSchemaSample
+	if v := os.Getenv("CHUNKER_SCHEMA_SAMPLE"); v != "" {
+		schemaSample, err = strconv.Atoi(v)
+		if err != nil || schemaSample < 0 {
+			log.Fatalf("Invalid CHUNKER_SCHEMA_SAMPLE value: %s", v)
+		}
+	}
 
 	return Config{
 		RedisAddr: redisHost + ":" + redisPort,
@@ -96,9 +164,19 @@ func loadConfig() Config {
 		S3Bucket:    mustGetEnv("S3_BUCKET"),
 		S3AccessKey: mustGetEnv("S3_ACCESS_KEY"),
 		S3SecretKey: mustGetEnv("S3_SECRET_KEY"),
+		S3TLS:       s3ClientGo.TLSConfig{Enabled: s3TLS, CAFile: os.Getenv("S3_CA_FILE")},
+		S3SSE:       s3SSE,
+
+		S3KeyStoreKeyFile: s3KeyStoreKeyFile,
 
-		RabbitMQURL: rabbitMQURL,
-		ChunkSize:   chunkSize,
+		RabbitMQURL:      rabbitMQURL,
+		Chunking:         chunking,
+		ChunkCompression: chunkCompression,
+		ParquetSplit:     parquetSplit,
+		SchemaSample:     schemaSample,
+		MasterKeyFile:    os.Getenv("CHUNKER_MASTER_KEY_FILE"),
+
+		RedactionSecretFile: os.Getenv("CHUNKER_REDACTION_SECRET_FILE"),
 	}
 }
 
@@ -130,8 +208,20 @@ func main() {
 	}
 
 	jobRepo := psql2.NewGormJobRepo(db)
+	schemaRepo := psql2.NewGormSchemaRepo(db)
+	ruleRepo := psql2.NewGormAlertRuleRepo(db)
+	baselineRepo := psql2.NewGormBaselineRepo(db)
+
+	var tenantKeys s3.CustomerKeyStore
+	if cfg.S3SSE == s3.SSEC {
+		masterKey, err := utils.ReadKeyFile(cfg.S3KeyStoreKeyFile)
+		if err != nil {
+			log.Fatalf("failed to load key store master key: %v", err)
+		}
+		tenantKeys = psql2.NewGormTenantKeyRepo(db, masterKey)
+	}
 
-	s3Client, err := s3ClientGo.NewS3Client(cfg.S3Host, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket)
+	s3Client, err := s3ClientGo.NewS3Client(cfg.S3Host, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3TLS)
 	if err != nil {
 		log.Fatalf("failed to init s3 client: %v", err)
 	}
@@ -147,9 +237,38 @@ func main() {
 		log.Fatalf("failed to init publisher: %v", err)
 	}
 
-	s3Repo := s3.NewS3Repo(s3Client)
+	s3Repo := s3.NewS3Repo(s3Client, cfg.S3SSE, tenantKeys)
 
-	chunkerUC := usecase.NewChunkerUseCase(jobRepo, s3Repo, jobPublisher, progressTracker, cfg.ChunkSize)
+	var keys usecase.KeyManager
+	if cfg.MasterKeyFile != "" {
+		localKMS, err := kms.NewLocalKMS(cfg.MasterKeyFile)
+		if err != nil {
+			log.Fatalf("failed to init kms: %v", err)
+		}
+		keys = localKMS
+	} else {
+		log.Println("CHUNKER_MASTER_KEY_FILE is not set, chunk fields will not be encrypted")
+	}
+
+	var redactionSecret []byte
+	if cfg.RedactionSecretFile != "" {
+		if redactionSecret, err = utils.ReadKeyFile(cfg.RedactionSecretFile); err != nil {
+			log.Fatalf("failed to load redaction secret: %v", err)
+		}
+	}
+
+	analysis := usecase.NewJobAnalysis(s3Repo, usecase.NewChunkReader(keys), usecase.NewResultWriter(s3Repo), utils.DefaultDetectorRegistry(), usecase.NewBaselineUpdater(baselineRepo))
+
+	chunkerUC := usecase.NewChunkerUseCase(jobRepo, schemaRepo, ruleRepo, s3Repo, jobPublisher, progressTracker, usecase.ChunkerConfig{
+		Chunking:         cfg.Chunking,
+		ChunkCompression: cfg.ChunkCompression,
+		ParquetSplit:     cfg.ParquetSplit,
+		SchemaSample:     cfg.SchemaSample,
+		Keys:             keys,
+		RedactionSecret:  redactionSecret,
+		Analysis:         analysis,
+		StatusCache:      progressTracker,
+	})
 
 	consumer, err := rabbitmq.NewChunkerConsumer(conn, "jobs.exchange", "jobs.created", "jobs.created.q", chunkerUC)
 	if err != nil {
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// AlertRule — правило оповещения тенанта, сохранённое gateway.
+type AlertRule struct {
+	TenantID   string
+	Name       string
+	Expression string
+	Severity   string
+	Enabled    bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// RuleSpec — правило, которое воркер проверяет на показаниях чанка.
+type RuleSpec struct {
+	Name       string
+	Expression string
+	Severity   string
+}
@@ -1,8 +1,47 @@
 package entity
 
+import "time"
+
 type Chunk struct {
-	JobID         string
-	ChunkID       int
-	PayloadURL    string
-	EncryptFields []string
+	JobID   string
+	ChunkID int
+	// TenantID — владелец задания, по нему выбирается ключ SSE-C объекта.
+	TenantID   string
+	PayloadURL string
+	// EncryptFields — поля, значения которых зашифрованы ключом DataKey.
+	EncryptFields []string    `json:",omitempty"`
+	DataKey       *WrappedKey `json:",omitempty"`
+	// Detectors — детекторы аномалий задания, пустой список = без поиска.
+	Detectors []DetectorSpec
+	// Rules — правила оповещений тенанта, срабатывания пишутся в аномалии.
+	Rules []RuleSpec `json:",omitempty"`
+	// Resample — передискретизация рядов после последнего чанка задания.
+	Resample *ResampleSpec `json:",omitempty"`
+	// Correlation — параметры анализа совместных аномалий задания.
+	Correlation *CorrelationSpec `json:",omitempty"`
+	// Compression — алгоритм сжатия объекта чанка (gzip, zstd), пусто = без сжатия.
+	Compression string `json:",omitempty"`
+	// Source — имя записи архива, из которой получен чанк; у разделов,
+	// собранных из нескольких записей, пусто.
+	Source string `json:",omitempty"`
+	// Partition — раздел задания, записи которого лежат в чанке.
+	Partition *ChunkPartition `json:",omitempty"`
+}
+
+// DefaultEncryptFields шифруются, если задание не задало свой список.
+var DefaultEncryptFields = []string{"temperature", "humidity"}
+
+// WrappedKey — ключ данных задания, зашифрованный мастер-ключом KMS.
+type WrappedKey struct {
+	KeyID      string
+	Ciphertext []byte
+}
+
+// ChunkPartition описывает раздел чанка. Все записи задания из раздела
+// лежат в этом чанке, других чанков раздела нет.
+type ChunkPartition struct {
+	SensorID    string     `json:",omitempty"`
+	Bucket      *int       `json:",omitempty"`
+	WindowStart *time.Time `json:",omitempty"`
+	WindowEnd   *time.Time `json:",omitempty"`
 }
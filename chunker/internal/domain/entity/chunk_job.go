@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"chunker/pkg/utils"
 	"gorm.io/gorm"
 	"time"
 )
@@ -15,6 +16,15 @@ const (
 	StatusFailed    JobStatus = "FAILED"
 )
 
+// JobStatusRecord — запись статуса задания в redis: по ней gateway
+// отвечает на опрос статуса, не обращаясь к Postgres.
+type JobStatusRecord struct {
+	UserID string    `json:"user_id"`
+	Status JobStatus `json:"status"`
+	// Quality — сводка качества данных без разбивки по датчикам.
+	Quality *utils.QualityReport `json:"quality,omitempty"`
+}
+
 type Job struct {
 	JobID     string    `json:"job_id"`
 	UserID    string    `json:"user_id"`
@@ -23,4 +33,9 @@ type Job struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Options      JobOptions     `json:"options" gorm:"type:jsonb"`
+	Diagnostics  JobDiagnostics `json:"-" gorm:"type:jsonb"`
+	SchemaReport SchemaReport   `json:"-" gorm:"type:jsonb"`
+	Result       JobResult      `json:"-" gorm:"type:jsonb"`
 }
@@ -1,12 +1,44 @@
 package entity
 
-import "time"
+import (
+	"chunker/pkg/utils"
+	"time"
+)
 
+// ChunkResult — итог анализа чанка. Его считает только этап анализа
+// чанкера (usecase.JobAnalysis) по опубликованным объектам чанков;
+// потребители очереди чанков получают данные и результатов не
+// возвращают.
 type ChunkResult struct {
 	JobID     string
 	ChunkID   int
 	Stats     ChunkStats
-	Anomalies []SensorReading
+	Anomalies []Anomaly
+}
+
+// Anomaly — значение метрики, которое детектор счёл аномальным.
+type Anomaly struct {
+	Reading  SensorReading
+	Metric   string
+	Value    float64
+	Detector string
+	// Severity — warning или critical.
+	Severity string
+	Reason   string
+}
+
+// ResampledReading — значение ряда датчика в интервале передискретизации.
+// Samples — число исходных показаний в интервале, 0 — интервал заполнен.
+// Метрики, которых в интервале нет, пусты (nil).
+type ResampledReading struct {
+	Timestamp   time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	SensorID    string    `json:"sensor_id" parquet:"sensor_id,dict"`
+	Temperature *float64  `json:"temperature,omitempty" parquet:"temperature,optional"`
+	Humidity    *float64  `json:"humidity,omitempty" parquet:"humidity,optional"`
+	Pressure    *float64  `json:"pressure,omitempty" parquet:"pressure,optional"`
+	// Metrics — прочие метрики датчика в интервале.
+	Metrics map[string]float64 `json:"metrics,omitempty" parquet:"metrics,optional"`
+	Samples int                `json:"samples" parquet:"samples"`
 }
 
 type ChunkStats struct {
@@ -14,6 +46,20 @@ type ChunkStats struct {
 	Temperature Stats
 	Humidity    Stats
 	Pressure    Stats
+	// Metrics — статистика прочих метрик по показаниям, где они есть.
+	Metrics map[string]Stats `json:",omitempty"`
+}
+
+// All возвращает статистику всех метрик чанка по именам.
+func (s ChunkStats) All() map[string]Stats {
+	all := make(map[string]Stats, len(s.Metrics)+3)
+	for name, st := range s.Metrics {
+		all[name] = st
+	}
+	all["temperature"] = s.Temperature
+	all["humidity"] = s.Humidity
+	all["pressure"] = s.Pressure
+	return all
 }
 
 type Stats struct {
@@ -21,12 +67,86 @@ type Stats struct {
 	Max  float64
 	Mean float64
 	Std  float64
+	// Count — число значений, по нему статистики чанков сливаются.
+	Count int
+	// P50–P99 точные по значениям чанка.
+	P50 float64
+	P90 float64
+	P95 float64
+	P99 float64
+	// Sketch — скетч квантилей для перцентилей задания.
+	Sketch *utils.DDSketch `json:",omitempty"`
 }
 
+// SensorReading — показание датчика. Основные метрики необязательны:
+// nil — в записи метрики нет, в отличие от настоящего нуля.
 type SensorReading struct {
-	Timestamp   time.Time
-	SensorID    string
-	Temperature float64
-	Humidity    float64
-	Pressure    float64
+	Timestamp   time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	SensorID    string    `json:"sensor_id" parquet:"sensor_id,dict"`
+	Temperature *float64  `json:"temperature,omitempty" parquet:"temperature,optional"`
+	Humidity    *float64  `json:"humidity,omitempty" parquet:"humidity,optional"`
+	Pressure    *float64  `json:"pressure,omitempty" parquet:"pressure,optional"`
+
+	// Tags — измерение и теги источника (InfluxDB line protocol и т.п.).
+	Tags map[string]string `json:"tags,omitempty" parquet:"tags,optional"`
+	// Metrics — прочие числовые метрики записи (co2, voltage, rssi…),
+	// Units — их единицы, как их заявил источник.
+	Metrics map[string]float64 `json:"metrics,omitempty" parquet:"metrics,optional"`
+	Units   map[string]string  `json:"units,omitempty" parquet:"-"`
+	// Extra — нечисловые поля записи.
+	Extra map[string]any `json:"extra,omitempty" parquet:"-"`
+}
+
+// BaseMetrics — метрики с отдельными полями SensorReading.
+var BaseMetrics = []string{"temperature", "humidity", "pressure"}
+
+func (r *SensorReading) base(metric string) **float64 {
+	switch metric {
+	case "temperature":
+		return &r.Temperature
+	case "humidity":
+		return &r.Humidity
+	case "pressure":
+		return &r.Pressure
+	}
+	return nil
+}
+
+// Value возвращает метрику показания по имени: основную или из Metrics.
+// ok = false, если метрики в показании нет.
+func (r SensorReading) Value(metric string) (float64, bool) {
+	if p := r.base(metric); p != nil {
+		if *p == nil {
+			return 0, false
+		}
+		return **p, true
+	}
+	v, ok := r.Metrics[metric]
+	return v, ok
+}
+
+// Values возвращает все метрики, которые есть в показании.
+func (r SensorReading) Values() map[string]float64 {
+	values := make(map[string]float64, len(BaseMetrics)+len(r.Metrics))
+	for _, metric := range BaseMetrics {
+		if v, ok := r.Value(metric); ok {
+			values[metric] = v
+		}
+	}
+	for metric, v := range r.Metrics {
+		values[metric] = v
+	}
+	return values
+}
+
+// SetValue записывает метрику: основную — в её поле, прочую — в Metrics.
+func (r *SensorReading) SetValue(metric string, v float64) {
+	if p := r.base(metric); p != nil {
+		*p = &v
+		return
+	}
+	if r.Metrics == nil {
+		r.Metrics = map[string]float64{}
+	}
+	r.Metrics[metric] = v
 }
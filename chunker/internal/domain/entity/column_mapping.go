@@ -0,0 +1,179 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поля SensorReading, на которые можно отобразить колонки источника.
+const (
+	FieldTimestamp   = "timestamp"
+	FieldSensorID    = "sensor_id"
+	FieldTemperature = "temperature"
+	FieldHumidity    = "humidity"
+	FieldPressure    = "pressure"
+)
+
+// ColumnMapping сопоставляет полям SensorReading колонки или JSON-пути
+// источника. Поля без сопоставления ищутся по обычным синонимам.
+type ColumnMapping struct {
+	Fields map[string]FieldMapping `json:"fields"`
+}
+
+type FieldMapping struct {
+	// Source — имя колонки или путь вида payload.values[0].temp.
+	Source string `json:"source"`
+	// Unit — единица значения в источнике, перевод в канонические
+	// единицы делает ParseReading.
+	Unit string `json:"unit,omitempty"`
+	// Scale и Offset применяются до перевода единиц: v*Scale + Offset.
+	Scale  *float64 `json:"scale,omitempty"`
+	Offset float64  `json:"offset,omitempty"`
+	// Format — формат времени для timestamp: rfc3339, unix_s, unix_ms,
+	// unix_us, unix_ns или Go-раскладка.
+	Format string `json:"format,omitempty"`
+}
+
+// Apply возвращает копию записи, где сопоставленные значения лежат под
+// именами полей SensorReading, а их единицы заявлены в "units".
+func (m *ColumnMapping) Apply(rec map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(rec))
+	for k, v := range rec {
+		out[k] = v
+	}
+	units := map[string]any{}
+	if declared, ok := rec[unitsKey].(map[string]any); ok {
+		for k, v := range declared {
+			units[k] = v
+		}
+	}
+	out[unitsKey] = units
+
+	// Значения читаются из нетронутой записи: поле может быть источником
+	// другого поля (temperature ← humidity, humidity ← temperature).
+	values := make(map[string]any, len(m.Fields))
+	for field, fm := range m.Fields {
+		v, ok := lookupPath(rec, fm.Source)
+		if !ok || v == nil {
+			if field == FieldTimestamp || field == FieldSensorID {
+				return nil, &FieldError{Field: field, Reason: fmt.Sprintf("source %s not found", fm.Source)}
+			}
+			continue
+		}
+		converted, err := fm.convert(field, v)
+		if err != nil {
+			return nil, &FieldError{Field: field, Reason: err.Error()}
+		}
+		values[field] = converted
+	}
+
+	// исходные колонки не должны попасть в Extra
+	for field, fm := range m.Fields {
+		delete(out, fm.Source)
+		delete(out, field)
+	}
+	for field, v := range values {
+		out[field] = v
+		if fm := m.Fields[field]; fm.Unit != "" {
+			units[field] = fm.Unit
+		}
+	}
+
+	return out, nil
+}
+
+func (fm FieldMapping) convert(field string, v any) (any, error) {
+	switch field {
+	case FieldSensorID:
+		return v, nil
+	case FieldTimestamp:
+		return parseMappedTime(v, fm.Format)
+	}
+
+	f, err := parseRecordFloat(v)
+	if err != nil {
+		return nil, err
+	}
+	if fm.Scale != nil {
+		f *= *fm.Scale
+	}
+	return f + fm.Offset, nil
+}
+
+func parseMappedTime(v any, format string) (time.Time, error) {
+	var unit time.Duration
+	switch format {
+	case "":
+		return parseRecordTime(v)
+	case "rfc3339":
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected rfc3339 string, got %T", v)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+		return ts.UTC(), err
+	case "unix_s":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected time string, got %T", v)
+		}
+		ts, err := time.Parse(format, strings.TrimSpace(s))
+		return ts.UTC(), err
+	}
+
+	f, err := parseRecordFloat(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(f*float64(unit))).UTC(), nil
+}
+
+var pathSegmentRe = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// lookupPath ищет значение по имени колонки, а если такой нет — по пути
+// вида payload.values[0].temp (префикс "$." допускается).
+func lookupPath(rec map[string]any, path string) (any, bool) {
+	if v, ok := rec[path]; ok {
+		return v, true
+	}
+
+	var cur any = rec
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		m := pathSegmentRe.FindStringSubmatch(seg)
+		if m == nil {
+			return nil, false
+		}
+		if m[1] != "" {
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = obj[m[1]]; !ok {
+				return nil, false
+			}
+		}
+		for _, idx := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if idx == "" {
+				continue
+			}
+			arr, ok := cur.([]any)
+			i, _ := strconv.Atoi(idx)
+			if !ok || i >= len(arr) {
+				return nil, false
+			}
+			cur = arr[i]
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,52 @@
+package entity
+
+import "testing"
+
+func TestColumnMappingApplySwap(t *testing.T) {
+	m := ColumnMapping{Fields: map[string]FieldMapping{
+		FieldTimestamp:   {Source: "ts"},
+		FieldSensorID:    {Source: "device"},
+		FieldTemperature: {Source: "humidity"},
+		FieldHumidity:    {Source: "temperature", Unit: "%RH"},
+	}}
+	rec := map[string]any{"ts": "2024-01-01T00:00:00Z", "device": "s1", "temperature": 40.0, "humidity": 21.5, "note": "x"}
+
+	// порядок обхода карты полей случаен, поэтому несколько прогонов
+	for i := 0; i < 20; i++ {
+		out, err := m.Apply(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out[FieldTemperature] != 21.5 || out[FieldHumidity] != 40.0 {
+			t.Fatalf("swap lost a field: %v", out)
+		}
+		if _, ok := out["ts"]; ok || out["note"] != "x" {
+			t.Fatalf("sources must be removed, other fields kept: %v", out)
+		}
+		if units := out[unitsKey].(map[string]any); units[FieldHumidity] != "%RH" || len(units) != 1 {
+			t.Fatalf("units: %v", units)
+		}
+	}
+	if rec["temperature"] != 40.0 || rec["humidity"] != 21.5 {
+		t.Errorf("input record modified: %v", rec)
+	}
+}
+
+func TestColumnMappingApplyMissingOptionalField(t *testing.T) {
+	m := ColumnMapping{Fields: map[string]FieldMapping{
+		FieldTimestamp:   {Source: "ts"},
+		FieldSensorID:    {Source: "device"},
+		FieldTemperature: {Source: "temp_c"},
+	}}
+	out, err := m.Apply(map[string]any{"ts": "2024-01-01T00:00:00Z", "device": "s1", "temperature": 99.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// несопоставленное значение под именем поля не должно выдать себя за него
+	if _, ok := out[FieldTemperature]; ok {
+		t.Errorf("temperature must be absent: %v", out)
+	}
+	if _, err := m.Apply(map[string]any{"ts": "2024-01-01T00:00:00Z"}); err == nil {
+		t.Error("missing sensor_id source must fail")
+	}
+}
@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+type JobDiagnostics struct {
+	TotalLines       int             `json:"total_lines,omitempty"`
+	MalformedLines   int             `json:"malformed_lines,omitempty"`
+	TotalRecords     int             `json:"total_records,omitempty"`
+	MalformedRecords int             `json:"malformed_records,omitempty"`
+	Malformed        []MalformedLine `json:"malformed,omitempty"`
+	SkippedEntries   []SkippedEntry  `json:"skipped_entries,omitempty"`
+
+	UnitConversions []UnitConversion `json:"unit_conversions,omitempty"`
+
+	Chunks *ChunkSizeStats `json:"chunks,omitempty"`
+	// EncryptedValues — сколько значений полей зашифровано ключом задания.
+	EncryptedValues int `json:"encrypted_values,omitempty"`
+	// Redactions — аудит обработки чувствительных полей.
+	Redactions []RedactedField `json:"redactions,omitempty"`
+}
+
+// MalformedLine описывает пропущенную строку (текстовые форматы)
+// или запись (бинарные форматы).
+type MalformedLine struct {
+	Source string `json:"source,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Record int    `json:"record,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type SkippedEntry struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// UnitConversion — сколько значений поля переведено из единицы From
+// в каноническую единицу To.
+type UnitConversion struct {
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Values int    `json:"values"`
+}
+
+// RedactedField — сколько значений поля обработано действием Action.
+type RedactedField struct {
+	Field  string `json:"field"`
+	Action string `json:"action"`
+	Values int    `json:"values"`
+}
+
+// ChunkSizeStats — политика, по которой резалось задание, и размеры чанков
+// в байтах до сжатия. StoredBytes — суммарный размер объектов в хранилище.
+type ChunkSizeStats struct {
+	Policy      ChunkPolicy `json:"policy"`
+	Count       int         `json:"count"`
+	TotalBytes  int64       `json:"total_bytes"`
+	MinBytes    int         `json:"min_bytes"`
+	MaxBytes    int         `json:"max_bytes"`
+	AvgBytes    int         `json:"avg_bytes"`
+	StoredBytes int64       `json:"stored_bytes"`
+	// Partitions — число разделов, если задание разбивалось на разделы.
+	Partitions int `json:"partitions,omitempty"`
+}
+
+func (d JobDiagnostics) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *JobDiagnostics) Scan(src any) error {
+	*d = JobDiagnostics{}
+	return scanJSONB(src, d)
+}
@@ -0,0 +1,144 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// JobOptions — параметры обработки, заданные при создании задания.
+type JobOptions struct {
+	// Sheet — лист книги .xlsx, пусто = первый лист.
+	Sheet string `json:"sheet,omitempty"`
+	// Precision — единицы отметок времени line protocol (ns, us, ms, s).
+	Precision string `json:"precision,omitempty"`
+	// Format — явно заданный формат (имя или MIME-тип), важнее расширения.
+	Format string `json:"format,omitempty"`
+	// ContentType — Content-Type загруженного файла.
+	ContentType string `json:"content_type,omitempty"`
+	// Schema — JSON Schema одной записи; пусто = контракт SensorReading.
+	Schema json.RawMessage `json:"schema,omitempty"`
+	// Mapping — сопоставление колонок полям SensorReading. Если задан
+	// MappingProfile, сюда копируется сохранённый профиль.
+	Mapping        *ColumnMapping `json:"mapping,omitempty"`
+	MappingProfile string         `json:"mapping_profile,omitempty"`
+	// Chunking заменяет политику нарезки chunker для этого задания.
+	Chunking *ChunkPolicy `json:"chunking,omitempty"`
+	// Partition — разбиение записей на разделы, nil = чанки по порядку записей.
+	Partition *PartitionSpec `json:"partition,omitempty"`
+	// EncryptFields — поля чанков, шифруемые ключом задания: поля
+	// SensorReading или пути metrics.<имя>, tags.<имя>, extra.<имя>
+	// (проверяет gateway). nil = поля по умолчанию chunker, пустой
+	// список = без шифрования.
+	EncryptFields []string `json:"encrypt_fields"`
+	// Redact — правила обработки чувствительных полей до записи чанков.
+	Redact []RedactionRule `json:"redact,omitempty"`
+	// Anomalies — детекторы аномалий для воркеров. nil = набор по умолчанию
+	// chunker, пустой список = без поиска аномалий.
+	Anomalies []DetectorSpec `json:"anomalies"`
+	// Resample — передискретизация рядов датчиков на этапе анализа, nil =
+	// без неё.
+	Resample *ResampleSpec `json:"resample,omitempty"`
+	// QualityRanges — правдоподобные диапазоны метрик для оценки качества
+	// поверх диапазонов по умолчанию chunker.
+	QualityRanges map[string]ValueRange `json:"quality_ranges,omitempty"`
+	// Correlation — параметры анализа корреляций и совместных аномалий,
+	// nil = параметры по умолчанию.
+	Correlation *CorrelationSpec `json:"correlation,omitempty"`
+}
+
+// CorrelationSpec — параметры анализа совместных аномалий датчиков.
+// Длительности — как в Go: 30s, 5m; пустые и нулевые поля = значения по
+// умолчанию.
+type CorrelationSpec struct {
+	// Interval — интервал, по средним которого сравниваются ряды (1m).
+	Interval string `json:"interval,omitempty"`
+	// Tolerance — насколько разнесённые во времени аномалии разных
+	// датчиков считаются одним инцидентом (1m).
+	Tolerance string `json:"tolerance,omitempty"`
+	// MinCorrelation — модуль коэффициента, с которого пара рядов попадает
+	// в отчёт (0.7).
+	MinCorrelation float64 `json:"min_correlation,omitempty"`
+}
+
+// ValueRange — допустимые значения метрики в канонических единицах.
+type ValueRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// DetectorSpec — детектор аномалий и его параметры; какие поля нужны,
+// зависит от детектора.
+type DetectorSpec struct {
+	Name       string   `json:"name"`
+	Metrics    []string `json:"metrics,omitempty"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	Threshold  float64  `json:"threshold,omitempty"`
+	Window     int      `json:"window,omitempty"`
+	MinSamples int      `json:"min_samples,omitempty"`
+}
+
+// ResampleSpec — интервал передискретизации и обработка разрывов.
+// Длительности — как в Go: 30s, 5m, 1h.
+type ResampleSpec struct {
+	Interval string `json:"interval"`
+	// Aggregate — mean, last или max; пусто = mean.
+	Aggregate string `json:"aggregate,omitempty"`
+	// GapThreshold — перерыв между показаниями, с которого он попадает
+	// в отчёт о разрывах; не меньше интервала, пусто = два интервала.
+	GapThreshold string `json:"gap_threshold,omitempty"`
+	// Fill — linear или previous; пусто = пустые интервалы пропускаются.
+	Fill string `json:"fill,omitempty"`
+}
+
+// ChunkPolicy — предел чанка по числу записей и/или по размеру в байтах;
+// чанк закрывается по тому пределу, что наступит раньше. Ноль — без предела.
+type ChunkPolicy struct {
+	MaxRecords int `json:"max_records,omitempty"`
+	MaxBytes   int `json:"max_bytes,omitempty"`
+}
+
+// PartitionSpec — разбиение записей на разделы по датчику и/или окну
+// времени: все записи задания из раздела попадают в один чанк.
+type PartitionSpec struct {
+	// Sensor — exact (раздел на датчик) или hash (Buckets корзин по хэшу
+	// sensor_id), пусто — без разбиения по датчику.
+	Sensor  string `json:"sensor,omitempty"`
+	Buckets int    `json:"buckets,omitempty"`
+	// Window — hour или day, пусто — без окон.
+	Window string `json:"window,omitempty"`
+}
+
+const (
+	PartitionSensorExact = "exact"
+	PartitionSensorHash  = "hash"
+	PartitionWindowHour  = "hour"
+	PartitionWindowDay   = "day"
+)
+
+// RedactionRule — обработка чувствительного поля: колонки CSV или пути
+// в JSON-записи через точку (owner.name, location.lat). Для Avro,
+// protobuf, line protocol и SenML путь задаётся в декодированной записи
+// до сопоставления колонок (tags.owner).
+type RedactionRule struct {
+	Field  string `json:"field"`
+	Action string `json:"action"`
+	// Precision — знаков после запятой для coarsen.
+	Precision int `json:"precision,omitempty"`
+}
+
+const (
+	RedactDrop     = "drop"
+	RedactHash     = "hash"
+	RedactTokenize = "tokenize"
+	RedactCoarsen  = "coarsen"
+)
+
+func (o JobOptions) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+func (o *JobOptions) Scan(src any) error {
+	*o = JobOptions{}
+	return scanJSONB(src, o)
+}
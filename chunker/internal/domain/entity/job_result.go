@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"chunker/pkg/utils"
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// JobResult — итоги анализа задания: их дописывают chunker и воркер
+// после последнего чанка, каждый — свои поля.
+type JobResult struct {
+	// Gaps — разрывы рядов датчиков длиннее порога передискретизации.
+	Gaps []SeriesGap `json:"gaps,omitempty"`
+	// Artifacts — ключи объектов результата по именам (readings, resampled,
+	// report).
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+	// Quality — оценка качества данных задания, её считает chunker.
+	Quality *utils.QualityReport `json:"quality,omitempty"`
+	// Metrics — статистика метрик по всем чанкам задания.
+	Metrics map[string]MetricSummary `json:"metrics,omitempty"`
+	// Anomalies — аномалии, найденные детекторами и правилами оповещений.
+	Anomalies *AnomalyReport `json:"anomalies,omitempty"`
+	// Correlation — корреляции рядов и инциденты из аномалий нескольких
+	// датчиков.
+	Correlation *utils.CorrelationReport `json:"correlation,omitempty"`
+}
+
+// MetricSummary — статистика метрики задания. Перцентили оценены по
+// слитым скетчам чанков с относительной погрешностью 1%.
+type MetricSummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	Std   float64 `json:"std"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// MaxResultAnomalies — сколько аномалий задания попадает в результат;
+// остальные учитываются только в счётчиках.
+const MaxResultAnomalies = 1000
+
+// AnomalyReport — аномалии задания: счётчики по детекторам и первые
+// MaxResultAnomalies аномалий по времени.
+type AnomalyReport struct {
+	Total      int            `json:"total"`
+	ByDetector map[string]int `json:"by_detector,omitempty"`
+	Items      []AnomalyItem  `json:"items,omitempty"`
+	Truncated  bool           `json:"truncated,omitempty"`
+}
+
+// AnomalyItem — аномалия показания датчика. Для срабатывания правила
+// Metric и Value пусты, Detector — "rule:<имя>".
+type AnomalyItem struct {
+	SensorID  string    `json:"sensor_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Metric    string    `json:"metric,omitempty"`
+	Value     float64   `json:"value,omitempty"`
+	Detector  string    `json:"detector"`
+	Severity  string    `json:"severity"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// SeriesGap — перерыв в показаниях датчика: между Start и End показаний нет.
+type SeriesGap struct {
+	SensorID string    `json:"sensor_id"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Seconds  float64   `json:"seconds"`
+}
+
+const (
+	ArtifactReadings  = "readings"
+	ArtifactResampled = "resampled"
+	ArtifactReport    = "report"
+)
+
+func (r JobResult) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *JobResult) Scan(src any) error {
+	*r = JobResult{}
+	return scanJSONB(src, r)
+}
@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// scanJSONB разбирает значение jsonb-колонки в dst. NULL оставляет dst как есть.
+func scanJSONB(src any, dst any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("unsupported jsonb value type %T", src)
+	}
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// ProtoSchema — дескриптор Protobuf-сообщения, зарегистрированный тенантом
+// для разбора его потоков телеметрии.
+type ProtoSchema struct {
+	TenantID      string
+	MessageName   string
+	DescriptorSet []byte
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
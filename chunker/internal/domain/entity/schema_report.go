@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// SchemaReport — выведенная схема входных данных и результат её проверки
+// по контракту SensorReading или JSON Schema пользователя.
+type SchemaReport struct {
+	Valid     bool                 `json:"valid"`
+	Validator string               `json:"validator"`
+	Sources   []SourceSchemaReport `json:"sources,omitempty"`
+}
+
+// SourceSchemaReport — отчёт по одному файлу: загруженному или записи архива.
+type SourceSchemaReport struct {
+	Source  string `json:"source,omitempty"`
+	Format  string `json:"format"`
+	Sampled int    `json:"sampled"`
+	// EmbeddedSchema — формат хранит схему в самом файле (parquet, avro):
+	// колонки выборки одинаковы у всех записей источника.
+	EmbeddedSchema bool                    `json:"embedded_schema,omitempty"`
+	Columns        []SchemaColumn          `json:"columns,omitempty"`
+	Errors         []SchemaValidationError `json:"errors,omitempty"`
+}
+
+type SchemaColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Nullable   bool   `json:"nullable,omitempty"`
+	TimeFormat string `json:"time_format,omitempty"`
+	Unit       string `json:"unit,omitempty"`
+}
+
+// SchemaValidationError — Row считается с единицы, без строки заголовка.
+type SchemaValidationError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (r SchemaReport) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *SchemaReport) Scan(src any) error {
+	*r = SchemaReport{}
+	return scanJSONB(src, r)
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"chunker/pkg/utils"
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// SensorBaseline — история датчика тенанта по завершённым заданиям:
+// скользящие моменты и сезонные профили каждой метрики.
+type SensorBaseline struct {
+	TenantID string          `gorm:"primaryKey;type:uuid"`
+	SensorID string          `gorm:"primaryKey"`
+	Metrics  BaselineMetrics `gorm:"type:jsonb;not null"`
+	// LastJobID — последнее учтённое задание: повторное обновление тем
+	// же заданием пропускается.
+	LastJobID string
+	Jobs      int
+	UpdatedAt time.Time
+}
+
+// BaselineMetrics — профили по именам метрик.
+type BaselineMetrics map[string]*utils.MetricProfile
+
+func (m BaselineMetrics) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *BaselineMetrics) Scan(src any) error {
+	*m = BaselineMetrics{}
+	return scanJSONB(src, m)
+}
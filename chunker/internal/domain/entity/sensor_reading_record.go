@@ -0,0 +1,331 @@
+package entity
+
+import (
+	"chunker/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"units"
+)
+
+// Синонимы полей SensorReading в записях бинарных форматов.
+var (
+	timestampKeys   = []string{"timestamp", "ts", "time"}
+	sensorIDKeys    = []string{"sensor_id", "sensorId", "sensorID", "sensor", "device_id"}
+	temperatureKeys = []string{"temperature", "temp"}
+	humidityKeys    = []string{"humidity"}
+	pressureKeys    = []string{"pressure"}
+)
+
+// tagsKey — вложенный объект с тегами записи.
+const tagsKey = "tags"
+
+// metricsKey — вложенный объект с прочими метриками записи, так их
+// пишет SensorReading.
+const metricsKey = "metrics"
+
+// FieldError — ошибка в конкретном поле записи.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string { return e.Field + ": " + e.Reason }
+
+// unitsKey — объект с единицами полей записи: {"temperature": "degF"}.
+// Его заполняют SenML и сопоставление колонок.
+const unitsKey = "units"
+
+// UnitConversions считает переводы единиц: поле -> исходная единица ->
+// число значений.
+type UnitConversions map[string]map[string]int
+
+func (c UnitConversions) add(field, from string) {
+	if c == nil {
+		return
+	}
+	if c[field] == nil {
+		c[field] = map[string]int{}
+	}
+	c[field][from]++
+}
+
+// ReadingFromRecord собирает SensorReading из декодированной записи
+// (Avro, Protobuf, line protocol и т.п.), см. ParseReading.
+func ReadingFromRecord(rec map[string]any) (SensorReading, error) {
+	return ParseReading(rec, nil)
+}
+
+// ParseReading собирает SensorReading из записи. Обязательны отметка
+// времени и идентификатор датчика, отсутствующих метрик в показании нет.
+// Метрики переводятся в канонические единицы: заявленные в "units" или
+// угаданные по имени колонки (temp_f, "Temperature (°F)"); переводы
+// считаются в conv, если он не nil. Физически невозможные значения —
+// ошибка записи. Теги из "tags" переносятся в Tags, прочие числовые
+// поля и поля объекта "metrics" — в Metrics, остальные скалярные — в Extra.
+func ParseReading(rec map[string]any, conv UnitConversions) (SensorReading, error) {
+	var r SensorReading
+	used := map[string]bool{tagsKey: true, unitsKey: true, metricsKey: true}
+	lookup := func(keys []string) (any, bool) {
+		for _, k := range keys {
+			used[k] = true
+		}
+		return lookupRecord(rec, keys)
+	}
+
+	v, ok := lookup(timestampKeys)
+	if !ok {
+		return r, &FieldError{Field: "timestamp", Reason: "missing"}
+	}
+	ts, err := parseRecordTime(v)
+	if err != nil {
+		return r, &FieldError{Field: "timestamp", Reason: err.Error()}
+	}
+	r.Timestamp = ts
+
+	v, ok = lookup(sensorIDKeys)
+	if !ok {
+		return r, &FieldError{Field: "sensor_id", Reason: "missing"}
+	}
+	switch id := v.(type) {
+	case string:
+		r.SensorID = id
+	default:
+		r.SensorID = fmt.Sprint(id)
+	}
+	if r.SensorID == "" {
+		return r, &FieldError{Field: "sensor_id", Reason: "empty"}
+	}
+
+	declared, _ := rec[unitsKey].(map[string]any)
+	for _, m := range []struct {
+		q    units.Quantity
+		keys []string
+	}{
+		{units.QuantityTemperature, temperatureKeys},
+		{units.QuantityHumidity, humidityKeys},
+		{units.QuantityPressure, pressureKeys},
+	} {
+		field := string(m.q)
+		key, v, unit, ok := lookupMetric(rec, m.keys)
+		if !ok {
+			continue
+		}
+		used[key] = true
+		if u, ok := declared[key].(string); ok && u != "" {
+			unit = u
+		} else if u, ok := declared[field].(string); ok && u != "" {
+			unit = u
+		}
+
+		f, err := parseRecordFloat(v)
+		if err != nil {
+			return r, &FieldError{Field: field, Reason: err.Error()}
+		}
+		if f, err = toCanonicalUnit(m.q, unit, f, r.Temperature, conv); err != nil {
+			return r, &FieldError{Field: field, Reason: err.Error()}
+		}
+		if err := units.CheckPlausible(m.q, f); err != nil {
+			return r, &FieldError{Field: field, Reason: err.Error()}
+		}
+		r.SetValue(field, f)
+	}
+
+	if tags, ok := rec[tagsKey].(map[string]any); ok && len(tags) > 0 {
+		r.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			r.Tags[k] = fmt.Sprint(v)
+		}
+	}
+
+	if nested, ok := rec[metricsKey].(map[string]any); ok {
+		for k, v := range nested {
+			if err := r.addMetric(k, v, declared); err != nil {
+				return r, err
+			}
+		}
+	}
+	for k, v := range rec {
+		if used[k] || !isScalar(v) {
+			continue
+		}
+		if isNumber(v) {
+			if err := r.addMetric(k, v, declared); err != nil {
+				return r, err
+			}
+			continue
+		}
+		if r.Extra == nil {
+			r.Extra = map[string]any{}
+		}
+		r.Extra[k] = v
+	}
+
+	return r, nil
+}
+
+// addMetric добавляет прочую метрику; единица берётся из "units" как есть,
+// без перевода. NaN и ±Inf — ошибка записи, как и для основных метрик.
+func (r *SensorReading) addMetric(name string, v any, declared map[string]any) error {
+	f, err := parseRecordFloat(v)
+	if err != nil {
+		return &FieldError{Field: name, Reason: err.Error()}
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return &FieldError{Field: name, Reason: "not a finite number"}
+	}
+	if r.Metrics == nil {
+		r.Metrics = map[string]float64{}
+	}
+	r.Metrics[name] = f
+	if u, ok := declared[name].(string); ok && u != "" {
+		if r.Units == nil {
+			r.Units = map[string]string{}
+		}
+		r.Units[name] = u
+	}
+	return nil
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case float64, float32, int, int32, int64, uint32, uint64, json.Number:
+		return true
+	default:
+		return false
+	}
+}
+
+// lookupMetric ищет метрику по синонимам, а затем среди колонок с единицей
+// в имени: temp_f, "Temperature (°F)". Возвращает найденный ключ и единицу
+// из имени колонки.
+func lookupMetric(rec map[string]any, keys []string) (string, any, string, bool) {
+	for _, k := range keys {
+		if v, ok := lookupRecord(rec, []string{k}); ok {
+			return k, v, "", true
+		}
+	}
+	for name, v := range rec {
+		if v == nil {
+			continue
+		}
+		base, unit := units.SplitColumnUnit(name)
+		if unit == "" {
+			continue
+		}
+		for _, k := range keys {
+			if strings.EqualFold(base, k) {
+				return name, v, unit, true
+			}
+		}
+	}
+	return "", nil, "", false
+}
+
+// toCanonicalUnit переводит значение в каноническую единицу величины.
+// Пустая единица означает, что значение уже в ней. Абсолютная влажность
+// пересчитывается в относительную по температуре той же записи (tempC,
+// nil — температуры в записи нет).
+func toCanonicalUnit(q units.Quantity, unit string, v float64, tempC *float64, conv UnitConversions) (float64, error) {
+	if unit == "" {
+		return v, nil
+	}
+	u, err := units.ParseUnit(q, unit)
+	if err != nil {
+		return 0, err
+	}
+	if u == units.CanonicalUnits[q] {
+		return v, nil
+	}
+
+	if q == units.QuantityHumidity && u == units.UnitAbsoluteHumidity {
+		if tempC == nil {
+			return 0, fmt.Errorf("absolute humidity requires temperature")
+		}
+		conv.add(string(q), u)
+		return units.AbsoluteToRelativeHumidity(v, *tempC), nil
+	}
+
+	out, err := units.ConvertUnit(q, u, v)
+	if err != nil {
+		return 0, err
+	}
+	conv.add(string(q), u)
+	return out, nil
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case string, bool, float64, float32, int, int32, int64, uint32, uint64, json.Number, time.Time:
+		return true
+	default:
+		return false
+	}
+}
+
+func lookupRecord(rec map[string]any, keys []string) (any, bool) {
+	for _, k := range keys {
+		v, ok := rec[k]
+		if !ok || v == nil {
+			continue
+		}
+		// Avro-объединения без null декодируются как {"тип": значение}.
+		if u, isMap := v.(map[string]any); isMap && len(u) == 1 {
+			for _, inner := range u {
+				v = inner
+			}
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+func parseRecordFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	default:
+		return 0, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func parseRecordTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC(), nil
+	case string:
+		if ts, ok := utils.ParseTimeText(t); ok {
+			return ts, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unrecognized time %q", t)
+		}
+		return utils.EpochToTime(f), nil
+	default:
+		f, err := parseRecordFloat(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return utils.EpochToTime(f), nil
+	}
+}
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseReadingRejectsNonFiniteMetrics(t *testing.T) {
+	base := func(extra map[string]any) map[string]any {
+		rec := map[string]any{"timestamp": "2024-01-01T00:00:00Z", "sensor_id": "s1", "temperature": 21.5}
+		for k, v := range extra {
+			rec[k] = v
+		}
+		return rec
+	}
+	for name, rec := range map[string]map[string]any{
+		"NaN":          base(map[string]any{"voltage": math.NaN()}),
+		"+Inf":         base(map[string]any{"voltage": math.Inf(1)}),
+		"json number":  base(map[string]any{"voltage": json.Number("-Inf")}),
+		"nested value": base(map[string]any{"metrics": map[string]any{"voltage": "NaN"}}),
+	} {
+		_, err := ParseReading(rec, nil)
+		var fe *FieldError
+		if !errors.As(err, &fe) || fe.Field != "voltage" {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	r, err := ParseReading(base(map[string]any{"voltage": 3.3, "metrics": map[string]any{"co2": "410"}}), nil)
+	if err != nil || r.Metrics["voltage"] != 3.3 || r.Metrics["co2"] != 410 {
+		t.Fatalf("finite metrics: %+v, %v", r.Metrics, err)
+	}
+}
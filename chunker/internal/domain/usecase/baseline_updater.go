@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"log"
+	"sort"
+	"sync"
+)
+
+type BaselineRepo interface {
+	GetBaseline(ctx context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error)
+	UpdateBaselines(ctx context.Context, tenantID string, sensorIDs []string, apply func(b *entity.SensorBaseline) bool) error
+}
+
+// BaselineUpdater ведёт историю датчиков между заданиями: воркер берёт из
+// него базовые линии для детектора baseline и после последнего чанка
+// завершённого задания переносит в историю наблюдения анализатора.
+type BaselineUpdater struct {
+	Repo BaselineRepo
+	// MaxWeight — сколько значений метрики помнит история.
+	MaxWeight float64
+}
+
+func NewBaselineUpdater(r BaselineRepo) *BaselineUpdater {
+	return &BaselineUpdater{Repo: r, MaxWeight: utils.DefaultBaselineWeight}
+}
+
+// Lookup возвращает базовые линии тенанта для анализатора. Датчик читается
+// из базы при первом обращении; ошибка чтения логируется, и датчик
+// проверяется без истории.
+func (u *BaselineUpdater) Lookup(ctx context.Context, tenantID string) utils.BaselineLookup {
+	var mu sync.Mutex
+	cache := map[string]entity.BaselineMetrics{}
+	return func(sensorID, metric string) *utils.MetricProfile {
+		mu.Lock()
+		defer mu.Unlock()
+		metrics, ok := cache[sensorID]
+		if !ok {
+			b, err := u.Repo.GetBaseline(ctx, tenantID, sensorID)
+			if err != nil {
+				log.Printf("baseline of sensor %s: %v\n", sensorID, err)
+			}
+			if b != nil {
+				metrics = b.Metrics
+			}
+			cache[sensorID] = metrics
+		}
+		return metrics[metric]
+	}
+}
+
+// Update сливает наблюдения задания с историей датчиков. Повторный вызов
+// для того же задания (например, после передоставки сообщения) ничего
+// не меняет.
+func (u *BaselineUpdater) Update(ctx context.Context, tenantID, jobID string, observed map[string]entity.BaselineMetrics) error {
+	if len(observed) == 0 {
+		return nil
+	}
+	sensorIDs := make([]string, 0, len(observed))
+	for id := range observed {
+		sensorIDs = append(sensorIDs, id)
+	}
+	sort.Strings(sensorIDs)
+
+	return u.Repo.UpdateBaselines(ctx, tenantID, sensorIDs, func(b *entity.SensorBaseline) bool {
+		if b.LastJobID == jobID {
+			return false
+		}
+		for metric, obs := range observed[b.SensorID] {
+			p := b.Metrics[metric]
+			if p == nil {
+				p = &utils.MetricProfile{}
+				b.Metrics[metric] = p
+			}
+			p.Merge(obs, u.MaxWeight)
+		}
+		b.LastJobID = jobID
+		b.Jobs++
+		return true
+	})
+}
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"fmt"
+	"maps"
+	"math"
+	"rules"
+	"slices"
+	"sort"
+	"time"
+)
+
+// ChunkAnalyzer считает статистику чанков задания и ищет в них аномалии.
+// Состояние детекторов (скользящие статистики по датчикам) живёт между
+// чанками, поэтому JobAnalysis держит один анализатор на задание и
+// подаёт чанки по порядку.
+type ChunkAnalyzer struct {
+	detectors []utils.Detector
+	rules     []analyzerRule
+	// наблюдения задания для истории датчиков
+	observed map[string]entity.BaselineMetrics
+}
+
+type analyzerRule struct {
+	spec entity.RuleSpec
+	eval *rules.RuleEvaluator
+}
+
+// NewChunkAnalyzer собирает детекторы и правила оповещений из параметров
+// чанка. baselines — история датчиков для детектора baseline, nil = без
+// истории.
+func NewChunkAnalyzer(specs []entity.DetectorSpec, ruleSpecs []entity.RuleSpec, registry *utils.DetectorRegistry, baselines utils.BaselineLookup) (*ChunkAnalyzer, error) {
+	a := &ChunkAnalyzer{observed: map[string]entity.BaselineMetrics{}}
+	for _, spec := range specs {
+		cfg := detectorConfig(spec)
+		cfg.Baselines = baselines
+		d, err := registry.Build(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.detectors = append(a.detectors, d)
+	}
+	for _, spec := range ruleSpecs {
+		rule, err := rules.ParseRule(spec.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: %w", spec.Name, err)
+		}
+		a.rules = append(a.rules, analyzerRule{spec: spec, eval: rule.NewEvaluator()})
+	}
+	return a, nil
+}
+
+// Analyze обрабатывает показания чанка в порядке времени: каждую метрику
+// показания проверяют детекторы, все вместе — правила оповещений.
+func (a *ChunkAnalyzer) Analyze(chunk entity.Chunk, readings []entity.SensorReading) entity.ChunkResult {
+	res := entity.ChunkResult{JobID: chunk.JobID, ChunkID: chunk.ChunkID, Stats: readingStats(readings)}
+
+	ordered := append([]entity.SensorReading(nil), readings...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Timestamp.Before(ordered[j].Timestamp) })
+
+	for _, r := range ordered {
+		in := rules.RuleInput{SensorID: r.SensorID, Time: r.Timestamp, Metrics: r.Values(), Tags: r.Tags}
+		for _, metric := range slices.Sorted(maps.Keys(in.Metrics)) {
+			v := in.Metrics[metric]
+			a.observe(r.SensorID, metric, r.Timestamp, v)
+
+			s := utils.Sample{SensorID: r.SensorID, Metric: metric, Time: r.Timestamp, Value: v}
+			for _, d := range a.detectors {
+				if f, ok := d.Check(s); ok {
+					res.Anomalies = append(res.Anomalies, entity.Anomaly{
+						Reading:  r,
+						Metric:   metric,
+						Value:    v,
+						Detector: f.Detector,
+						Severity: string(f.Severity),
+						Reason:   f.Reason,
+					})
+				}
+			}
+		}
+
+		for _, rule := range a.rules {
+			if rule.eval.Eval(in) {
+				res.Anomalies = append(res.Anomalies, entity.Anomaly{
+					Reading:  r,
+					Detector: "rule:" + rule.spec.Name,
+					Severity: rule.spec.Severity,
+					Reason:   rule.spec.Expression,
+				})
+			}
+		}
+	}
+	return res
+}
+
+func (a *ChunkAnalyzer) observe(sensorID, metric string, t time.Time, v float64) {
+	metrics := a.observed[sensorID]
+	if metrics == nil {
+		metrics = entity.BaselineMetrics{}
+		a.observed[sensorID] = metrics
+	}
+	p := metrics[metric]
+	if p == nil {
+		p = &utils.MetricProfile{}
+		metrics[metric] = p
+	}
+	p.Add(t, v)
+}
+
+// Observed — наблюдения всех чанков задания по датчикам; после
+// завершения задания их принимает BaselineUpdater.Update.
+func (a *ChunkAnalyzer) Observed() map[string]entity.BaselineMetrics {
+	return a.observed
+}
+
+// readingStats считает min/max/mean/std метрик чанка. SensorID
+// заполняется, если в чанке один датчик.
+func readingStats(readings []entity.SensorReading) entity.ChunkStats {
+	var st entity.ChunkStats
+	if len(readings) == 0 {
+		return st
+	}
+	st.SensorID = readings[0].SensorID
+	for _, r := range readings[1:] {
+		if r.SensorID != st.SensorID {
+			st.SensorID = ""
+			break
+		}
+	}
+	series := map[string][]float64{}
+	for _, r := range readings {
+		for metric, v := range r.Values() {
+			series[metric] = append(series[metric], v)
+		}
+	}
+	st.Temperature = seriesStats(series["temperature"])
+	st.Humidity = seriesStats(series["humidity"])
+	st.Pressure = seriesStats(series["pressure"])
+	for metric, values := range series {
+		if slices.Contains(entity.BaseMetrics, metric) {
+			continue
+		}
+		if st.Metrics == nil {
+			st.Metrics = map[string]entity.Stats{}
+		}
+		st.Metrics[metric] = seriesStats(values)
+	}
+	return st
+}
+
+// seriesStats считает статистику значений; values сортируется на месте.
+// NaN и ±Inf не учитываются.
+func seriesStats(values []float64) entity.Stats {
+	values = slices.DeleteFunc(values, func(v float64) bool { return math.IsNaN(v) || math.IsInf(v, 0) })
+	if len(values) == 0 {
+		return entity.Stats{}
+	}
+	s := entity.Stats{Min: math.Inf(1), Max: math.Inf(-1), Count: len(values), Sketch: utils.NewDDSketch(utils.DefaultSketchAccuracy)}
+	var sum, sumSq float64
+	for _, v := range values {
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+		sum += v
+		sumSq += v * v
+		s.Sketch.Add(v)
+	}
+	n := float64(len(values))
+	s.Mean = sum / n
+	s.Std = math.Sqrt(math.Max(sumSq/n-s.Mean*s.Mean, 0))
+	p := utils.Percentiles(values, utils.StatPercentiles)
+	s.P50, s.P90, s.P95, s.P99 = p[0], p[1], p[2], p[3]
+	return s
+}
+
+func detectorConfig(spec entity.DetectorSpec) utils.DetectorConfig {
+	return utils.DetectorConfig{
+		Name:       spec.Name,
+		Metrics:    spec.Metrics,
+		Min:        spec.Min,
+		Max:        spec.Max,
+		Threshold:  spec.Threshold,
+		Window:     spec.Window,
+		MinSamples: spec.MinSamples,
+	}
+}
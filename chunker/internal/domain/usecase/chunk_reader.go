@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"bytes"
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"fmt"
+	"io"
+)
+
+// ChunkReader восстанавливает полезную нагрузку чанка для воркера:
+// распаковывает объект и расшифровывает поля ключом данных задания.
+// Расшифровать может только воркер с доступом к мастер-ключу в Keys.
+type ChunkReader struct {
+	Keys KeyManager
+}
+
+func NewChunkReader(keys KeyManager) *ChunkReader {
+	return &ChunkReader{Keys: keys}
+}
+
+// ReadPayload возвращает чанк в том виде, в каком его выдал сплиттер.
+func (r *ChunkReader) ReadPayload(ctx context.Context, chunk entity.Chunk, data []byte) ([]byte, error) {
+	if chunk.Compression != "" {
+		zr, err := utils.NewDecompressReader(bytes.NewReader(data), utils.Compression(chunk.Compression))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("decompress chunk %d: %w", chunk.ChunkID, err)
+		}
+	}
+
+	if chunk.DataKey == nil {
+		return data, nil
+	}
+	if r.Keys == nil {
+		return nil, errEncryptionUnavailable
+	}
+	key, err := r.Keys.UnwrapKey(ctx, *chunk.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err = utils.DecryptChunkFields(data, chunk.EncryptFields, key, utils.ChunkAAD(chunk.JobID, chunk.ChunkID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt chunk %d: %w", chunk.ChunkID, err)
+	}
+	return data, nil
+}
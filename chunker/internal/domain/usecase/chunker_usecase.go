@@ -1,87 +1,770 @@
 package usecase
 
 import (
+	"bufio"
 	"chunker/internal/domain/entity"
 	"chunker/pkg/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"path/filepath"
+	"maps"
+	"rules"
+	"sort"
 	"strings"
+	"time"
+	"units"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type JobRepo interface {
 	GetJob(ctx context.Context, jobID string) (*entity.Job, error)
 	UpdateJobStatus(ctx context.Context, jobID string, status entity.JobStatus) error
+	SaveDiagnostics(ctx context.Context, jobID string, diag entity.JobDiagnostics) error
+	SaveSchemaReport(ctx context.Context, jobID string, report entity.SchemaReport) error
+	SaveJobResult(ctx context.Context, jobID string, result entity.JobResult) error
+}
+
+type SchemaRepo interface {
+	GetProtoSchema(ctx context.Context, tenantID string) (*entity.ProtoSchema, error)
+}
+
+type RuleRepo interface {
+	ListEnabledAlertRules(ctx context.Context, tenantID string) ([]entity.AlertRule, error)
 }
 
 type Storage interface {
-	UploadChunk(ctx context.Context, key string, file []byte) error
-	GetFileReader(ctx context.Context, key string) (io.ReadCloser, error)
+	UploadChunk(ctx context.Context, tenantID, key string, file []byte) error
+	UploadStream(ctx context.Context, tenantID, key string, r io.Reader, size int64) error
+	GetFileReader(ctx context.Context, tenantID, key string) (io.ReadCloser, error)
 }
 
 type Publisher interface {
 	Publish(ctx context.Context, body json.RawMessage) error
 }
 
+// KeyManager шифрует ключи данных заданий мастер-ключом (KMS).
+type KeyManager interface {
+	WrapKey(ctx context.Context, key []byte) (entity.WrappedKey, error)
+	UnwrapKey(ctx context.Context, wk entity.WrappedKey) ([]byte, error)
+}
+
 type ProgressTracker interface {
 	SetChunkStatus(ctx context.Context, jobID string, chunkID int, status string) error
 	GetJobProgress(ctx context.Context, jobID string) (completed, total int, err error)
 }
 
+// StatusCache — запись статуса задания, которую опрашивает gateway.
+type StatusCache interface {
+	SetJobStatus(ctx context.Context, jobID string, rec entity.JobStatusRecord) error
+}
+
 type ChunkerUseCase struct {
 	JobRepo         JobRepo
+	SchemaRepo      SchemaRepo
+	RuleRepo        RuleRepo
 	Storage         Storage
 	Publisher       Publisher
 	ProgressTracker ProgressTracker
-	ChunkSize       int // например, 5–10k строк
+	// StatusCache — nil = статус хранится только в Postgres.
+	StatusCache StatusCache
+	// Chunking — политика нарезки по умолчанию, задание может заменить её
+	// своей (JobOptions.Chunking).
+	Chunking utils.ChunkPolicy
+	// ChunkCompression — сжатие объектов чанков в хранилище, пусто = без сжатия.
+	ChunkCompression utils.Compression
+	ParquetSplit     utils.ParquetSplitMode
+	Formats          *utils.FormatRegistry
+	// Detectors — детекторы аномалий, которые можно заказать в задании.
+	Detectors *utils.DetectorRegistry
+	// SchemaSample — сколько записей источника проверяется до публикации
+	// чанков, 0 = проверка отключена.
+	SchemaSample int
+	// Keys — KMS для ключей данных, nil = шифрование полей недоступно.
+	Keys KeyManager
+	// RedactionSecret — секрет, из которого выводятся соли тенантов для
+	// hash и tokenize, nil = эти действия недоступны.
+	RedactionSecret []byte
+	// Analysis — анализ опубликованных чанков, после которого задание
+	// завершается; nil = задание остаётся в CHUNKING.
+	Analysis *JobAnalysis
 }
 
-func NewChunkerUseCase(j JobRepo, s Storage, p Publisher, pt ProgressTracker, chunkSize int) *ChunkerUseCase {
+// ChunkerConfig — настройки нарезки и необязательные зависимости
+// ChunkerUseCase, смысл полей и нулевых значений описан у одноимённых
+// полей ChunkerUseCase.
+type ChunkerConfig struct {
+	Chunking         utils.ChunkPolicy
+	ChunkCompression utils.Compression
+	ParquetSplit     utils.ParquetSplitMode
+	SchemaSample     int
+	Keys             KeyManager
+	RedactionSecret  []byte
+	Analysis         *JobAnalysis
+	StatusCache      StatusCache
+}
+
+func NewChunkerUseCase(j JobRepo, sr SchemaRepo, rr RuleRepo, s Storage, p Publisher, pt ProgressTracker, cfg ChunkerConfig) *ChunkerUseCase {
 	return &ChunkerUseCase{
-		JobRepo:         j,
-		Storage:         s,
-		Publisher:       p,
-		ProgressTracker: pt,
-		ChunkSize:       chunkSize,
+		JobRepo:          j,
+		SchemaRepo:       sr,
+		RuleRepo:         rr,
+		Storage:          s,
+		Publisher:        p,
+		ProgressTracker:  pt,
+		StatusCache:      cfg.StatusCache,
+		Chunking:         cfg.Chunking,
+		ChunkCompression: cfg.ChunkCompression,
+		ParquetSplit:     cfg.ParquetSplit,
+		Formats:          utils.DefaultFormatRegistry(),
+		Detectors:        utils.DefaultDetectorRegistry(),
+		SchemaSample:     cfg.SchemaSample,
+		Keys:             cfg.Keys,
+		RedactionSecret:  cfg.RedactionSecret,
+		Analysis:         cfg.Analysis,
 	}
 }
 
+// errUnsupportedFileType отличает неподдерживаемый формат от ошибок разбора:
+// внутри архива такие файлы пропускаются, а не валят всё задание.
+var errUnsupportedFileType = errors.New("unsupported file type")
+
+// errSourceUnreadable — источник не распаковывается или не разбирается:
+// запись архива с такой ошибкой пропускается и попадает в диагностику.
+var errSourceUnreadable = errors.New("source is unreadable")
+
+// errSchemaInvalid — выборка не прошла проверку схемы: задание завершается
+// со статусом FAILED и отчётом, а не уходит на повтор.
+var errSchemaInvalid = errors.New("schema validation failed")
+
+// errEncryptionUnavailable — задание просит шифрование, а KMS не настроен.
+var errEncryptionUnavailable = errors.New("field encryption requested but no key manager configured")
+
+// errRedactionInvalid — правила редактирования задания нельзя применить.
+var errRedactionInvalid = errors.New("redaction rules cannot be applied")
+
+// jobRun — состояние обработки одного задания: сквозная нумерация чанков
+// по всем источникам и накопленная диагностика.
+type jobRun struct {
+	job         *entity.Job
+	nextChunkID int
+	diag        entity.JobDiagnostics
+	report      entity.SchemaReport
+	validate    utils.RecordValidator // JSON Schema задания, nil = контракт SensorReading
+	units       entity.UnitConversions
+	chunking    utils.ChunkPolicy
+	// ключ данных задания и поля, которые им шифруются
+	dataKey       []byte
+	wrappedKey    *entity.WrappedKey
+	encryptFields []string
+	redactor      *utils.Redactor // nil = без редактирования
+	detectors     []entity.DetectorSpec
+	rules         []entity.RuleSpec
+	quality       *utils.QualityTracker
+	qualityReport *utils.QualityReport
+	published     []entity.Chunk
+}
+
+func (r *jobRun) skipEntry(name, reason string) {
+	log.Printf("job %s: skipping archive entry %s: %s\n", r.job.JobID, name, reason)
+	r.diag.SkippedEntries = append(r.diag.SkippedEntries, entity.SkippedEntry{Name: name, Reason: reason})
+}
+
 func (u *ChunkerUseCase) ProcessJob(ctx context.Context, job *entity.Job) error {
 	log.Printf("Processing job %s\n", job.JobID)
 
-	fileReader, err := u.Storage.GetFileReader(ctx, job.FileKey)
+	rawReader, err := u.Storage.GetFileReader(ctx, job.UserID, job.FileKey)
+	if err != nil {
+		return err
+	}
+	defer rawReader.Close()
+
+	fileReader, innerKey, compression, err := utils.Decompress(rawReader, job.FileKey)
 	if err != nil {
 		return err
 	}
 	defer fileReader.Close()
+	if compression != utils.CompressionNone {
+		log.Printf("job %s: decompressing %s input\n", job.JobID, compression)
+	}
+
+	run := &jobRun{job: job, units: entity.UnitConversions{}, chunking: u.Chunking}
+	if c := job.Options.Chunking; c != nil {
+		run.chunking = utils.ChunkPolicy{MaxRecords: c.MaxRecords, MaxBytes: c.MaxBytes}
+	}
+	run.quality = utils.NewQualityTracker(qualityConfig(job))
+	run.diag.Chunks = &entity.ChunkSizeStats{Policy: entity.ChunkPolicy{MaxRecords: run.chunking.MaxRecords, MaxBytes: run.chunking.MaxBytes}}
+	if err := u.prepareValidation(run); err != nil {
+		return err
+	}
+	if err := u.prepareRedaction(run); err != nil {
+		log.Printf("job %s: %v\n", job.JobID, err)
+		return u.setStatus(ctx, run, entity.StatusFailed)
+	}
+	if err := u.prepareDetectors(run); err != nil {
+		log.Printf("job %s: %v\n", job.JobID, err)
+		return u.setStatus(ctx, run, entity.StatusFailed)
+	}
+	if job.Options.Resample != nil {
+		if _, err := resampleConfig(*job.Options.Resample); err != nil {
+			log.Printf("job %s: resample: %v\n", job.JobID, err)
+			return u.setStatus(ctx, run, entity.StatusFailed)
+		}
+	}
+	if _, err := correlationConfig(job.Options.Correlation); err != nil {
+		log.Printf("job %s: correlation: %v\n", job.JobID, err)
+		return u.setStatus(ctx, run, entity.StatusFailed)
+	}
+	if err := u.prepareRules(ctx, run); err != nil {
+		return err
+	}
+	if err := u.prepareEncryption(ctx, run); err != nil {
+		if errors.Is(err, errEncryptionUnavailable) {
+			log.Printf("job %s: %v\n", job.JobID, err)
+			return u.setStatus(ctx, run, entity.StatusFailed)
+		}
+		return err
+	}
+	br := bufio.NewReader(fileReader)
+
+	// Все источники разбираются и проверяются до публикации первого чанка:
+	// задание с ошибкой схемы в любом источнике не оставляет чанков в очереди.
+	var sources []*preparedSource
+	if format := u.detectArchive(job, innerKey, br); format != utils.ArchiveNone {
+		log.Printf("job %s: unpacking %s archive\n", job.JobID, format)
+		err = utils.WalkArchive(format, br, func(name string, r io.Reader) error {
+			src, err := u.prepareSource(ctx, run, name, name, r)
+			switch {
+			case errors.Is(err, errUnsupportedFileType) || errors.Is(err, errSourceUnreadable):
+				run.skipEntry(name, err.Error())
+				return nil
+			case errors.Is(err, errSchemaInvalid):
+				// остальные записи проверяются ради полного отчёта
+				return nil
+			case err != nil:
+				return err
+			}
+			sources = append(sources, src)
+			return nil
+		}, run.skipEntry)
+	} else {
+		var src *preparedSource
+		if src, err = u.prepareSource(ctx, run, "", innerKey, br); err == nil {
+			sources = append(sources, src)
+		}
+	}
+	// повтор не поможет: вход не уменьшится
+	if errors.Is(err, utils.ErrInputTooLarge) {
+		log.Printf("job %s: %v\n", job.JobID, err)
+		return u.setStatus(ctx, run, entity.StatusFailed)
+	}
+	if err != nil && !errors.Is(err, errSchemaInvalid) {
+		return err
+	}
+	if run.report.Valid {
+		if err := u.publishSources(ctx, run, sources); err != nil {
+			return err
+		}
+	}
+
+	run.addUnitConversions()
+	run.addRedactions()
+	if err := u.JobRepo.SaveDiagnostics(ctx, job.JobID, run.diag); err != nil {
+		return err
+	}
+	if err := u.JobRepo.SaveSchemaReport(ctx, job.JobID, run.report); err != nil {
+		return err
+	}
+	quality := run.quality.Report(run.diag.MalformedLines + run.diag.MalformedRecords)
+	if err := u.JobRepo.SaveJobResult(ctx, job.JobID, entity.JobResult{Quality: &quality}); err != nil {
+		return err
+	}
+	run.qualityReport = &quality
+
+	if !run.report.Valid {
+		log.Printf("job %s: %v\n", job.JobID, errSchemaInvalid)
+		return u.setStatus(ctx, run, entity.StatusFailed)
+	}
+	if err := u.setStatus(ctx, run, entity.StatusChunking); err != nil {
+		return err
+	}
+	if u.Analysis == nil {
+		return nil
+	}
+	return u.analyze(ctx, run)
+}
+
+// setStatus меняет статус задания в Postgres, затем в записи статуса для
+// gateway: запись не опережает базу.
+func (u *ChunkerUseCase) setStatus(ctx context.Context, run *jobRun, status entity.JobStatus) error {
+	if err := u.JobRepo.UpdateJobStatus(ctx, run.job.JobID, status); err != nil {
+		return err
+	}
+	if u.StatusCache == nil {
+		return nil
+	}
+	rec := entity.JobStatusRecord{UserID: run.job.UserID, Status: status, Quality: run.qualityReport.Summary()}
+	return u.StatusCache.SetJobStatus(ctx, run.job.JobID, rec)
+}
+
+// analyze прогоняет опубликованные чанки через анализ и завершает
+// задание. Ошибка анализа завершает задание со статусом FAILED: повтор
+// сообщения заново опубликовал бы все чанки.
+func (u *ChunkerUseCase) analyze(ctx context.Context, run *jobRun) error {
+	job := run.job
+	if err := u.setStatus(ctx, run, entity.StatusRunning); err != nil {
+		return err
+	}
+	result, err := u.Analysis.Run(ctx, job, run.published, run.qualityReport)
+	if err != nil {
+		log.Printf("job %s: analysis: %v\n", job.JobID, err)
+		return u.setStatus(ctx, run, entity.StatusFailed)
+	}
+	if err := u.JobRepo.SaveJobResult(ctx, job.JobID, result); err != nil {
+		return err
+	}
+	return u.setStatus(ctx, run, entity.StatusCompleted)
+}
+
+// prepareValidation компилирует JSON Schema из параметров задания
+// (gateway уже проверил, что она компилируется). Без неё записи
+// проверяются контрактом SensorReading, см. checkSchema.
+func (u *ChunkerUseCase) prepareValidation(run *jobRun) error {
+	run.report = entity.SchemaReport{Valid: true, Validator: "sensor_reading"}
+	if len(run.job.Options.Schema) == 0 {
+		return nil
+	}
+
+	validate, err := utils.NewJSONSchemaValidator(run.job.Options.Schema)
+	if err != nil {
+		return err
+	}
+	run.report.Validator = "json_schema"
+	run.validate = validate
+	return nil
+}
+
+// prepareRedaction собирает правила редактирования задания. Соль тенанта
+// выводится из RedactionSecret, без секрета доступны только drop и coarsen.
+func (u *ChunkerUseCase) prepareRedaction(run *jobRun) error {
+	if len(run.job.Options.Redact) == 0 {
+		return nil
+	}
+
+	rules := make([]utils.RedactRule, 0, len(run.job.Options.Redact))
+	for _, r := range run.job.Options.Redact {
+		rules = append(rules, utils.RedactRule{Field: r.Field, Action: utils.RedactAction(r.Action), Precision: r.Precision})
+	}
+	var salt []byte
+	if u.RedactionSecret != nil {
+		salt = utils.TenantSalt(u.RedactionSecret, run.job.UserID)
+	}
+	redactor, err := utils.NewRedactor(rules, salt)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRedactionInvalid, err)
+	}
+	run.redactor = redactor
+	return nil
+}
+
+// prepareDetectors проверяет детекторы аномалий задания до нарезки, чтобы
+// ошибка в параметрах не всплыла только у воркеров.
+func (u *ChunkerUseCase) prepareDetectors(run *jobRun) error {
+	specs := run.job.Options.Anomalies
+	if specs == nil {
+		for _, cfg := range utils.DefaultDetectors {
+			specs = append(specs, entity.DetectorSpec{Name: cfg.Name})
+		}
+	}
+	for _, spec := range specs {
+		if _, err := u.Detectors.Build(detectorConfig(spec)); err != nil {
+			return err
+		}
+	}
+	run.detectors = append([]entity.DetectorSpec{}, specs...)
+	return nil
+}
+
+// prepareRules загружает включённые правила оповещений тенанта: воркеры
+// получают их вместе с чанками. Правило, которое перестало разбираться,
+// пропускается, а не валит задание.
+func (u *ChunkerUseCase) prepareRules(ctx context.Context, run *jobRun) error {
+	alertRules, err := u.RuleRepo.ListEnabledAlertRules(ctx, run.job.UserID)
+	if err != nil {
+		return fmt.Errorf("alert rules: %w", err)
+	}
+	for _, r := range alertRules {
+		if _, err := rules.ParseRule(r.Expression); err != nil {
+			log.Printf("job %s: skipping alert rule %s: %v\n", run.job.JobID, r.Name, err)
+			continue
+		}
+		run.rules = append(run.rules, entity.RuleSpec{Name: r.Name, Expression: r.Expression, Severity: r.Severity})
+	}
+	return nil
+}
+
+// prepareEncryption создаёт ключ данных задания и оборачивает его в KMS.
+// Без KMS поля по умолчанию не шифруются, а явно заданный список — ошибка.
+func (u *ChunkerUseCase) prepareEncryption(ctx context.Context, run *jobRun) error {
+	fields := run.job.Options.EncryptFields
+	if fields == nil {
+		if u.Keys == nil {
+			return nil
+		}
+		fields = entity.DefaultEncryptFields
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if u.Keys == nil {
+		return errEncryptionUnavailable
+	}
+
+	key, err := utils.GenerateDataKey()
+	if err != nil {
+		return err
+	}
+	wk, err := u.Keys.WrapKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	run.dataKey, run.wrappedKey, run.encryptFields = key, &wk, fields
+	return nil
+}
+
+// preparedSource — чанки источника, прошедшие проверку и обработку, но
+// ещё не опубликованные.
+type preparedSource struct {
+	name   string
+	chunks [][]byte
+	header bool
+}
+
+// prepareSource разбивает на чанки один файл: само загруженное задание
+// или запись архива (source — имя записи, для обычного файла пусто).
+func (u *ChunkerUseCase) prepareSource(ctx context.Context, run *jobRun, source, fileKey string, r io.Reader) (*preparedSource, error) {
+	fileReader, innerKey, _, err := utils.Decompress(r, fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSourceUnreadable, err)
+	}
+	defer fileReader.Close()
+
+	splitter, input, err := u.resolveSplitter(run.job, innerKey, bufio.NewReader(fileReader))
+	if err != nil {
+		return nil, err
+	}
+
+	caps := splitter.Capabilities()
+	opts := utils.SplitOptions{
+		Chunking:     run.chunking,
+		ParquetSplit: u.ParquetSplit,
+		Sheet:        run.job.Options.Sheet,
+		Precision:    run.job.Options.Precision,
+		Convert:      readingConverter(run.job.Options.Mapping, run.units),
+	}
+	// Форматы с конвертацией отдают уже SensorReading, поэтому
+	// чувствительные поля обрабатываются в декодированной записи, пока
+	// у полей имена из источника.
+	if caps.Converts && run.redactor != nil {
+		opts.Convert = redactingConverter(run.redactor, opts.Convert)
+	}
+	if caps.RequiresSchema {
+		opts.Descriptor, err = u.protoDescriptor(ctx, run.job.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := splitter.Split(input, opts)
+	if err != nil {
+		if source != "" {
+			return nil, fmt.Errorf("%w: split %s: %v", errSourceUnreadable, splitter.Name(), err)
+		}
+		return nil, fmt.Errorf("split %s file: %w", splitter.Name(), err)
+	}
+	run.addNDJSONStats(source, res.Lines)
+	run.addDecodeStats(source, res.Records)
+
+	if err := u.checkSchema(run, source, splitter, res.Chunks); err != nil {
+		return nil, err
+	}
+
+	// Чувствительные поля обрабатываются до сопоставления колонок, по
+	// именам из исходного файла, и ни в каком виде не попадают в чанки.
+	chunks := res.Chunks
+	header := caps.Header
+	if run.redactor != nil && !caps.Converts {
+		if chunks, err = run.redactor.RedactChunks(chunks, header); err != nil {
+			return nil, fmt.Errorf("redact fields: %w", err)
+		}
+	}
+
+	// Записи форматов без конвертации пересобираются в SensorReading:
+	// каждая проходит сопоставление колонок, перевод единиц и проверку
+	// правдоподобия, а не только выборка схемы.
+	if !caps.Converts {
+		var stats utils.DecodeStats
+		chunks, stats, err = utils.MapChunks(chunks, header, run.chunking, opts.Convert)
+		if err != nil {
+			return nil, fmt.Errorf("apply column mapping: %w", err)
+		}
+		run.addDecodeStats(source, stats)
+		header = false
+	}
+
+	// качество оценивается по записям в том виде, в каком их получат
+	// воркеры, но до шифрования полей
+	if err := run.quality.AddChunks(chunks, header, qualityReading); err != nil {
+		return nil, fmt.Errorf("quality report: %w", err)
+	}
+
+	return &preparedSource{name: source, chunks: chunks, header: header}, nil
+}
+
+// publishSources публикует чанки проверенных источников задания.
+func (u *ChunkerUseCase) publishSources(ctx context.Context, run *jobRun, sources []*preparedSource) error {
+	if spec := run.job.Options.Partition; spec != nil {
+		return u.publishPartitions(ctx, run, *spec, sources)
+	}
+	for _, src := range sources {
+		if err := u.publishChunks(ctx, run, src.name, src.chunks, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	fileType := determineFileType(job.FileKey)
-	if fileType == "" {
-		return fmt.Errorf("unsupported file type for file: %s", job.FileKey)
+// publishPartitions перекладывает записи всех источников задания по
+// разделам и публикует каждый раздел одним чанком, чтобы статистика
+// датчика или окна считалась без слияния чанков. Политика нарезки к
+// разделам не применяется.
+func (u *ChunkerUseCase) publishPartitions(ctx context.Context, run *jobRun, spec entity.PartitionSpec, sources []*preparedSource) error {
+	partitions := map[string]*entity.ChunkPartition{}
+	keyOf, err := partitionKey(spec, partitions)
+	if err != nil {
+		return err
+	}
+
+	p := utils.NewPartitioner(keyOf)
+	for _, src := range sources {
+		stats, err := p.Add(src.chunks, src.header)
+		if err != nil {
+			return fmt.Errorf("partition records: %w", err)
+		}
+		run.addDroppedRecords(src.name, stats)
+		src.chunks = nil
 	}
 
-	var chunks [][]byte
-	switch fileType {
-	case "csv":
-		chunks, err = utils.SplitCSVToChunks(fileReader, u.ChunkSize)
-	case "json":
-		chunks, err = utils.SplitJSONToChunks(fileReader, u.ChunkSize)
+	for _, part := range p.Result() {
+		if err := u.publishChunks(ctx, run, "", [][]byte{part.Chunk}, partitions[part.Key]); err != nil {
+			return err
+		}
+		run.diag.Chunks.Partitions++
+	}
+	return nil
+}
+
+// partitionKey строит функцию ключа раздела по спецификации задания
+// и запоминает в partitions описание каждого встреченного раздела.
+// Ключи сортируются по датчику (корзине), затем по началу окна.
+func partitionKey(spec entity.PartitionSpec, partitions map[string]*entity.ChunkPartition) (utils.PartitionKeyFunc, error) {
+	var window time.Duration
+	switch spec.Window {
+	case "":
+	case entity.PartitionWindowHour:
+		window = time.Hour
+	case entity.PartitionWindowDay:
+		window = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("unknown partition window %s", spec.Window)
+	}
+	switch spec.Sensor {
+	case "", entity.PartitionSensorExact:
+	case entity.PartitionSensorHash:
+		if spec.Buckets <= 0 {
+			return nil, fmt.Errorf("invalid partition buckets: %d", spec.Buckets)
+		}
 	default:
-		return fmt.Errorf("unsupported file type: %s", fileType)
+		return nil, fmt.Errorf("unknown sensor partitioning %s", spec.Sensor)
 	}
 
-	for i, data := range chunks {
+	return func(rec map[string]any) (string, error) {
+		r, err := entity.ParseReading(rec, nil)
+		if err != nil {
+			return "", err
+		}
+
+		var p entity.ChunkPartition
+		var key string
+		switch spec.Sensor {
+		case entity.PartitionSensorExact:
+			p.SensorID = r.SensorID
+			key = r.SensorID
+		case entity.PartitionSensorHash:
+			b := utils.SensorBucket(r.SensorID, spec.Buckets)
+			p.Bucket = &b
+			key = fmt.Sprintf("%05d", b)
+		}
+		if window > 0 {
+			start, end := utils.TimeWindow(r.Timestamp, window)
+			p.WindowStart, p.WindowEnd = &start, &end
+			key += "\x00" + start.Format(time.RFC3339)
+		}
+
+		if _, ok := partitions[key]; !ok {
+			partitions[key] = &p
+		}
+		return key, nil
+	}, nil
+}
+
+// resolveSplitter выбирает формат (см. lookupSplitter) и возвращает вход
+// для него: сплиттеры без Capabilities.Streaming держат чанки источника
+// в памяти, поэтому их распакованный вход ограничен MaxSplitInputSize.
+func (u *ChunkerUseCase) resolveSplitter(job *entity.Job, fileKey string, br *bufio.Reader) (utils.Splitter, io.Reader, error) {
+	s, err := u.lookupSplitter(job, fileKey, br)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.Capabilities().Streaming {
+		return s, br, nil
+	}
+	return s, utils.LimitSplitInput(br), nil
+}
+
+// lookupSplitter выбирает формат: явно заданный при загрузке, затем
+// по расширению, по Content-Type загрузки и по сигнатуре.
+func (u *ChunkerUseCase) lookupSplitter(job *entity.Job, fileKey string, br *bufio.Reader) (utils.Splitter, error) {
+	if name := job.Options.Format; name != "" {
+		s, ok := u.Formats.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown format %s, supported: %s", name, strings.Join(u.Formats.Names(), ", "))
+		}
+		return s, nil
+	}
+	if s, ok := u.Formats.ByExtension(fileKey); ok {
+		return s, nil
+	}
+	if s, ok := u.Formats.ByMIME(job.Options.ContentType); ok {
+		return s, nil
+	}
+	header, _ := br.Peek(utils.FormatSniffSize)
+	if s, ok := u.Formats.Sniff(header); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w for file: %s", errUnsupportedFileType, fileKey)
+}
+
+// detectArchive не даёт принять за архив форматы, которые сами являются
+// zip-контейнерами (.xlsx): если формат распознан, файл разбирается целиком.
+func (u *ChunkerUseCase) detectArchive(job *entity.Job, fileKey string, br *bufio.Reader) utils.ArchiveFormat {
+	if job.Options.Format != "" {
+		return utils.ArchiveFromExt(fileKey)
+	}
+	if _, ok := u.Formats.ByExtension(fileKey); ok {
+		return utils.ArchiveNone
+	}
+	header, _ := br.Peek(utils.FormatSniffSize)
+	if _, ok := u.Formats.Sniff(header); ok {
+		return utils.ArchiveNone
+	}
+	return utils.DetectArchive(fileKey, br)
+}
+
+// checkSchema выводит схему по первым SchemaSample записям источника
+// и проверяет их до публикации чанков. У форматов со схемой в файле
+// (EmbeddedSchema) колонки выборки верны для всего источника, что
+// отмечается в отчёте.
+func (u *ChunkerUseCase) checkSchema(run *jobRun, source string, splitter utils.Splitter, chunks [][]byte) error {
+	if u.SchemaSample <= 0 {
+		return nil
+	}
+
+	caps := splitter.Capabilities()
+	validate := run.validate
+	if validate == nil {
+		// записи сконвертированных форматов уже прошли сопоставление
+		mapping := run.job.Options.Mapping
+		if caps.Converts {
+			mapping = nil
+		}
+		validate = readingContract(mapping)
+	}
+
+	records, err := utils.SampleRecords(chunks, caps.Header, u.SchemaSample)
+	if err != nil {
+		return fmt.Errorf("sample records: %w", err)
+	}
+	schema := utils.InferSchema(records)
+
+	rep := entity.SourceSchemaReport{Source: source, Format: splitter.Name(), Sampled: schema.Sampled, EmbeddedSchema: caps.EmbeddedSchema}
+	for _, c := range schema.Columns {
+		rep.Columns = append(rep.Columns, entity.SchemaColumn{
+			Name:       c.Name,
+			Type:       string(c.Type),
+			Nullable:   c.Nullable,
+			TimeFormat: c.TimeFormat,
+			Unit:       c.Unit,
+		})
+	}
+	for _, e := range utils.ValidateRecords(records, validate) {
+		rep.Errors = append(rep.Errors, entity.SchemaValidationError{Row: e.Row, Column: e.Column, Reason: e.Reason})
+	}
+	run.report.Sources = append(run.report.Sources, rep)
+
+	if len(rep.Errors) > 0 {
+		run.report.Valid = false
+		return errSchemaInvalid
+	}
+	return nil
+}
+
+// publishChunks шифрует поля, загружает и публикует чанки источника —
+// JSON-массивы записей; partition — раздел, которому принадлежат все
+// chunks, nil = без разбиения.
+func (u *ChunkerUseCase) publishChunks(ctx context.Context, run *jobRun, source string, chunks [][]byte, partition *entity.ChunkPartition) error {
+	job := run.job
+	for _, data := range chunks {
+		i := run.nextChunkID
+		run.nextChunkID++
+
 		chunk := entity.Chunk{
 			JobID:         job.JobID,
 			ChunkID:       i,
+			TenantID:      job.UserID,
 			PayloadURL:    fmt.Sprintf("jobs/%s/chunks/%d", job.JobID, i),
-			EncryptFields: []string{"temperature", "humidity"},
+			EncryptFields: run.encryptFields,
+			DataKey:       run.wrappedKey,
+			Detectors:     run.detectors,
+			Rules:         run.rules,
+			Resample:      run.job.Options.Resample,
+			Correlation:   run.job.Options.Correlation,
+			Compression:   string(u.ChunkCompression),
+			Source:        source,
 		}
+		chunk.Partition = partition
 
-		if err := u.Storage.UploadChunk(ctx, chunk.PayloadURL, data); err != nil {
+		if run.dataKey != nil {
+			var n int
+			var err error
+			data, n, err = utils.EncryptChunkFields(data, run.encryptFields, run.dataKey, utils.ChunkAAD(job.JobID, i))
+			if err != nil {
+				return fmt.Errorf("encrypt fields: %w", err)
+			}
+			run.diag.EncryptedValues += n
+		}
+
+		size := len(data)
+		data, err := utils.Compress(data, u.ChunkCompression)
+		if err != nil {
+			return err
+		}
+		run.addChunkSize(size, len(data))
+
+		if err := u.Storage.UploadChunk(ctx, job.UserID, chunk.PayloadURL, data); err != nil {
 			return err
 		}
 
@@ -95,19 +778,164 @@ func (u *ChunkerUseCase) ProcessJob(ctx context.Context, job *entity.Job) error
 		}
 
 		_ = u.ProgressTracker.SetChunkStatus(ctx, job.JobID, i, "PUBLISHED")
+		run.published = append(run.published, chunk)
 	}
 
-	return u.JobRepo.UpdateJobStatus(ctx, job.JobID, entity.StatusChunking)
+	return nil
 }
 
-func determineFileType(fileKey string) string {
-	ext := strings.ToLower(filepath.Ext(fileKey))
-	switch ext {
-	case ".csv":
-		return "csv"
-	case ".json":
-		return "json"
-	default:
-		return ext
+func (r *jobRun) addChunkSize(size, stored int) {
+	s := r.diag.Chunks
+	if s.Count == 0 || size < s.MinBytes {
+		s.MinBytes = size
+	}
+	if size > s.MaxBytes {
+		s.MaxBytes = size
+	}
+	s.Count++
+	s.TotalBytes += int64(size)
+	s.StoredBytes += int64(stored)
+	s.AvgBytes = int(s.TotalBytes / int64(s.Count))
+}
+
+// addDroppedRecords учитывает записи, отброшенные после разбора: сами
+// записи уже посчитаны в TotalRecords.
+func (r *jobRun) addDroppedRecords(source string, stats utils.DecodeStats) {
+	if stats.Malformed > 0 {
+		log.Printf("job %s: dropped %d records without partition\n", r.job.JobID, stats.Malformed)
+	}
+
+	r.diag.MalformedRecords += stats.Malformed
+	for _, m := range stats.Samples {
+		if len(r.diag.Malformed) >= utils.MaxMalformedSamples {
+			break
+		}
+		r.diag.Malformed = append(r.diag.Malformed, entity.MalformedLine{Source: source, Record: m.Line, Reason: m.Reason})
+	}
+}
+
+func (r *jobRun) addNDJSONStats(source string, stats utils.NDJSONStats) {
+	if stats.Malformed > 0 {
+		log.Printf("job %s: skipped %d malformed lines out of %d\n", r.job.JobID, stats.Malformed, stats.Lines)
+	}
+
+	r.diag.TotalLines += stats.Lines
+	r.diag.MalformedLines += stats.Malformed
+	for _, m := range stats.Samples {
+		if len(r.diag.Malformed) >= utils.MaxMalformedSamples {
+			break
+		}
+		r.diag.Malformed = append(r.diag.Malformed, entity.MalformedLine{Source: source, Line: m.Line, Reason: m.Reason})
+	}
+}
+
+func (r *jobRun) addDecodeStats(source string, stats utils.DecodeStats) {
+	if stats.Malformed > 0 {
+		log.Printf("job %s: skipped %d malformed records\n", r.job.JobID, stats.Malformed)
+	}
+
+	r.diag.TotalRecords += stats.Records + stats.Malformed
+	r.diag.MalformedRecords += stats.Malformed
+	for _, m := range stats.Samples {
+		if len(r.diag.Malformed) >= utils.MaxMalformedSamples {
+			break
+		}
+		r.diag.Malformed = append(r.diag.Malformed, entity.MalformedLine{Source: source, Record: m.Line, Reason: m.Reason})
+	}
+}
+
+func (r *jobRun) addUnitConversions() {
+	for field, from := range r.units {
+		to := units.CanonicalUnits[units.Quantity(field)]
+		for unit, n := range from {
+			r.diag.UnitConversions = append(r.diag.UnitConversions, entity.UnitConversion{Field: field, From: unit, To: to, Values: n})
+		}
+	}
+	sort.Slice(r.diag.UnitConversions, func(i, j int) bool {
+		a, b := r.diag.UnitConversions[i], r.diag.UnitConversions[j]
+		return a.Field < b.Field || a.Field == b.Field && a.From < b.From
+	})
+}
+
+func (r *jobRun) addRedactions() {
+	if r.redactor == nil {
+		return
+	}
+	for _, c := range r.redactor.Counts() {
+		log.Printf("job %s: redacted %d values of %s (%s)\n", r.job.JobID, c.Values, c.Field, c.Action)
+		r.diag.Redactions = append(r.diag.Redactions, entity.RedactedField{Field: c.Field, Action: string(c.Action), Values: c.Values})
+	}
+}
+
+// readingConverter приводит записи к SensorReading, предварительно
+// применяя сопоставление колонок. Переводы единиц считаются в units.
+func readingConverter(mapping *entity.ColumnMapping, units entity.UnitConversions) utils.RecordConverter {
+	return func(rec map[string]any) (any, error) {
+		if mapping != nil {
+			var err error
+			if rec, err = mapping.Apply(rec); err != nil {
+				return nil, err
+			}
+		}
+		return entity.ParseReading(rec, units)
+	}
+}
+
+// redactingConverter обрабатывает чувствительные поля записи до
+// конвертации.
+func redactingConverter(redactor *utils.Redactor, convert utils.RecordConverter) utils.RecordConverter {
+	return func(rec map[string]any) (any, error) {
+		redactor.RedactRecord(rec)
+		return convert(rec)
+	}
+}
+
+// readingContract проверяет, что из записи собирается SensorReading
+// (с учётом сопоставления колонок, если оно задано).
+func readingContract(mapping *entity.ColumnMapping) utils.RecordValidator {
+	convert := readingConverter(mapping, nil)
+	return func(rec map[string]any) []utils.ValidationError {
+		if rec == nil {
+			return []utils.ValidationError{{Reason: "record is not an object"}}
+		}
+		if _, err := convert(rec); err != nil {
+			var fe *entity.FieldError
+			if errors.As(err, &fe) {
+				return []utils.ValidationError{{Column: fe.Field, Reason: fe.Reason}}
+			}
+			return []utils.ValidationError{{Reason: err.Error()}}
+		}
+		return nil
+	}
+}
+
+// protoDescriptor загружает дескриптор сообщения, зарегистрированный тенантом.
+func (u *ChunkerUseCase) protoDescriptor(ctx context.Context, tenantID string) (protoreflect.MessageDescriptor, error) {
+	schema, err := u.SchemaRepo.GetProtoSchema(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf schema for tenant %s: %w", tenantID, err)
+	}
+	return utils.ParseMessageDescriptor(schema.DescriptorSet, schema.MessageName)
+}
+
+// qualityConfig берёт диапазоны метрик задания поверх диапазонов по
+// умолчанию; отметки времени сравниваются с моментом загрузки.
+func qualityConfig(job *entity.Job) utils.QualityConfig {
+	cfg := utils.QualityConfig{Reference: job.CreatedAt}
+	if len(job.Options.QualityRanges) > 0 {
+		cfg.Ranges = maps.Clone(utils.DefaultQualityRanges)
+		for metric, r := range job.Options.QualityRanges {
+			cfg.Ranges[metric] = utils.QualityRange{Min: r.Min, Max: r.Max}
+		}
+	}
+	return cfg
+}
+
+func qualityReading(rec map[string]any) (string, time.Time, map[string]float64, error) {
+	r, err := entity.ReadingFromRecord(rec)
+	if err != nil {
+		id, _ := rec["sensor_id"].(string)
+		return id, time.Time{}, nil, err
 	}
+	return r.SensorID, r.Timestamp, r.Values(), nil
 }
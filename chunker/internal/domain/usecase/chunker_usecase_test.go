@@ -0,0 +1,274 @@
+package usecase
+
+import (
+	"bytes"
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const validCSV = "timestamp,sensor_id,temperature\n2024-01-01T00:00:00Z,s1,20.5\n2024-01-01T00:01:00Z,s1,20.7\n"
+
+func TestProcessJobValidatesAllSourcesBeforePublishing(t *testing.T) {
+	archive := buildZip(t, map[string]string{
+		"a.csv": validCSV,
+		// без sensor_id показание не собрать
+		"b.csv": "timestamp,temperature\n2024-01-01T00:00:00Z,20.5\n",
+	})
+	f := newChunkerFixture(t, "bundle.zip", archive, entity.JobOptions{})
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.publisher.chunks) != 0 {
+		t.Errorf("published %d chunks of an invalid job", len(f.publisher.chunks))
+	}
+	if f.job.Status != entity.StatusFailed {
+		t.Errorf("status = %s, want FAILED", f.job.Status)
+	}
+	if rep := f.job.SchemaReport; rep.Valid || len(rep.Sources) != 2 {
+		t.Errorf("schema report: %+v", rep)
+	}
+}
+
+func TestProcessJobFailsOnOversizedInput(t *testing.T) {
+	defer func(limit int64) { utils.MaxSplitInputSize = limit }(utils.MaxSplitInputSize)
+	utils.MaxSplitInputSize = int64(len(validCSV)) - 1
+	f := newChunkerFixture(t, "data.csv", []byte(validCSV), entity.JobOptions{})
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if f.job.Status != entity.StatusFailed || len(f.publisher.chunks) != 0 {
+		t.Errorf("status %s, %d chunks", f.job.Status, len(f.publisher.chunks))
+	}
+
+	// вход ровно в предел принимается
+	utils.MaxSplitInputSize = int64(len(validCSV))
+	f = newChunkerFixture(t, "data.csv", []byte(validCSV), entity.JobOptions{})
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.publisher.chunks) != 1 {
+		t.Errorf("input at the limit: %d chunks", len(f.publisher.chunks))
+	}
+}
+
+func TestProcessJobReportsEmbeddedSchema(t *testing.T) {
+	type row struct {
+		Timestamp   time.Time `parquet:"timestamp,timestamp(millisecond)"`
+		SensorID    string    `parquet:"sensor_id"`
+		Temperature float64   `parquet:"temperature"`
+	}
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, []row{{Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), SensorID: "s1", Temperature: 20.5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"data.parquet": true, "data.csv": false} {
+		data := buf.Bytes()
+		if name == "data.csv" {
+			data = []byte(validCSV)
+		}
+		f := newChunkerFixture(t, name, data, entity.JobOptions{})
+		if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+			t.Fatal(err)
+		}
+		if src := f.job.SchemaReport.Sources; len(src) != 1 || src[0].EmbeddedSchema != want {
+			t.Errorf("%s: schema report %+v", name, src)
+		}
+	}
+}
+
+func TestProcessJobSkipsUnreadableArchiveEntries(t *testing.T) {
+	archive := buildZip(t, map[string]string{
+		"a.csv":    validCSV,
+		"b.csv.gz": "not gzip at all",
+	})
+	f := newChunkerFixture(t, "bundle.zip", archive, entity.JobOptions{})
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.publisher.chunks) != 1 || f.publisher.chunks[0].Source != "a.csv" {
+		t.Errorf("chunks: %+v", f.publisher.chunks)
+	}
+	skipped := f.job.Diagnostics.SkippedEntries
+	if len(skipped) != 1 || skipped[0].Name != "b.csv.gz" {
+		t.Errorf("skipped: %+v", skipped)
+	}
+}
+
+func TestProcessJobNormalizesEveryRecord(t *testing.T) {
+	// первые 150 записей в канонических единицах, дальше — невозможное
+	// значение и запись с заявленной единицей
+	var b strings.Builder
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 150; i++ {
+		fmt.Fprintf(&b, `{"timestamp":%q,"sensor_id":"s1","temperature":20}`+"\n", start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339))
+	}
+	b.WriteString(`{"timestamp":"2024-01-02T00:00:00Z","sensor_id":"s1","temperature":-300}` + "\n")
+	b.WriteString(`{"timestamp":"2024-01-02T00:01:00Z","sensor_id":"s1","temperature":212,"units":{"temperature":"degF"}}` + "\n")
+
+	f := newChunkerFixture(t, "readings.ndjson", []byte(b.String()), entity.JobOptions{})
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+
+	diag := f.job.Diagnostics
+	if diag.MalformedRecords != 1 {
+		t.Errorf("malformed = %d, want 1 (impossible temperature)", diag.MalformedRecords)
+	}
+	if len(diag.UnitConversions) != 1 || diag.UnitConversions[0].From != "degF" || diag.UnitConversions[0].Values != 1 {
+		t.Errorf("unit conversions: %+v", diag.UnitConversions)
+	}
+
+	readings := publishedReadings(t, f)
+	if len(readings) != 151 || metric(readings[150], "temperature") != 100 {
+		t.Errorf("got %d readings, last %+v", len(readings), readings[len(readings)-1])
+	}
+}
+
+// publishedReadings читает показания всех опубликованных чанков задания.
+// metric возвращает метрику показания или NaN, если её нет.
+func metric(r entity.SensorReading, name string) float64 {
+	if v, ok := r.Value(name); ok {
+		return v
+	}
+	return math.NaN()
+}
+
+func publishedReadings(t *testing.T, f *chunkerFixture) []entity.SensorReading {
+	t.Helper()
+	var out []entity.SensorReading
+	for _, c := range f.publisher.chunks {
+		rc, err := f.storage.GetFileReader(context.Background(), c.TenantID, c.PayloadURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var batch []entity.SensorReading
+		if err := json.NewDecoder(rc).Decode(&batch); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, batch...)
+	}
+	return out
+}
+
+func TestProcessJobPublishesOneChunkPerPartition(t *testing.T) {
+	// датчик s1 встречается в обоих источниках, записей больше предела чанка
+	var a, b strings.Builder
+	a.WriteString("timestamp,sensor_id,temperature\n")
+	b.WriteString("timestamp,sensor_id,temperature\n")
+	for i := 0; i < 150; i++ {
+		ts := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339)
+		fmt.Fprintf(&a, "%s,s1,%d\n", ts, i)
+		fmt.Fprintf(&b, "%s,s2,%d\n", ts, i)
+	}
+	fmt.Fprintf(&b, "2024-01-02T00:00:00Z,s1,1\n")
+	archive := buildZip(t, map[string]string{"a.csv": a.String(), "b.csv": b.String()})
+	f := newChunkerFixture(t, "bundle.zip", archive, entity.JobOptions{Partition: &entity.PartitionSpec{Sensor: entity.PartitionSensorExact}})
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.publisher.chunks) != 2 {
+		t.Fatalf("published %d chunks, want one per sensor", len(f.publisher.chunks))
+	}
+	counts := map[string]int{}
+	for _, r := range publishedReadings(t, f) {
+		counts[r.SensorID]++
+	}
+	if counts["s1"] != 151 || counts["s2"] != 150 {
+		t.Errorf("readings per sensor: %v", counts)
+	}
+	for _, c := range f.publisher.chunks {
+		if c.Partition == nil || c.Partition.SensorID == "" {
+			t.Errorf("chunk %d without partition", c.ChunkID)
+		}
+	}
+}
+
+func TestChunkReaderDecryptsPublishedChunks(t *testing.T) {
+	var file strings.Builder
+	file.WriteString("timestamp,sensor_id,temperature,humidity\n")
+	for i := 0; i < 150; i++ {
+		ts := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339)
+		fmt.Fprintf(&file, "%s,s1,%d,%d\n", ts, i, 40+i%10)
+	}
+	f := newChunkerFixture(t, "data.csv", []byte(file.String()), entity.JobOptions{})
+	f.uc.Keys = fakeKeys{}
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.publisher.chunks) != 2 || f.job.Diagnostics.EncryptedValues != 300 {
+		t.Fatalf("chunks %d, encrypted %d", len(f.publisher.chunks), f.job.Diagnostics.EncryptedValues)
+	}
+
+	payload := func(c entity.Chunk) []byte {
+		rc, err := f.storage.GetFileReader(context.Background(), c.TenantID, c.PayloadURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		return data
+	}
+	reader := NewChunkReader(fakeKeys{})
+	var n int
+	for _, c := range f.publisher.chunks {
+		data, err := reader.ReadPayload(context.Background(), c, payload(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var batch []entity.SensorReading
+		if err := json.Unmarshal(data, &batch); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range batch {
+			if metric(r, "temperature") != float64(n) {
+				t.Fatalf("reading %d: %+v", n, r)
+			}
+			n++
+		}
+	}
+
+	// полезная нагрузка одного чанка не расшифровывается под другим
+	first, second := f.publisher.chunks[0], f.publisher.chunks[1]
+	if _, err := reader.ReadPayload(context.Background(), second, payload(first)); err == nil {
+		t.Fatal("expected error for payload of another chunk")
+	}
+}
+
+func TestProcessJobRedactsConvertedFormatsBySourceNames(t *testing.T) {
+	file := "env,sensor_id=s1,owner=Ann temp=20.56 1704067200000000000\n"
+	opts := entity.JobOptions{
+		Mapping: &entity.ColumnMapping{Fields: map[string]entity.FieldMapping{
+			"temperature": {Source: "temp"},
+		}},
+		Redact: []entity.RedactionRule{
+			{Field: "temp", Action: entity.RedactCoarsen},
+			{Field: "tags.owner", Action: entity.RedactDrop},
+		},
+	}
+	f := newChunkerFixture(t, "data.lp", []byte(file), opts)
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+
+	readings := publishedReadings(t, f)
+	if len(readings) != 1 || metric(readings[0], "temperature") != 21 {
+		t.Fatalf("readings: %+v", readings)
+	}
+	if len(f.job.Diagnostics.Redactions) != 2 {
+		t.Errorf("redactions: %+v", f.job.Diagnostics.Redactions)
+	}
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+)
+
+// correlationConfig проверяет параметры анализа; nil — параметры по
+// умолчанию.
+func correlationConfig(spec *entity.CorrelationSpec) (utils.CorrelationConfig, error) {
+	if spec == nil {
+		return utils.ParseCorrelationConfig("", "", 0)
+	}
+	return utils.ParseCorrelationConfig(spec.Interval, spec.Tolerance, spec.MinCorrelation)
+}
+
+// readingCorrelation — шаг JobAnalysis: по мере разбора чанков копит
+// средние рядов всех датчиков и метрик задания по интервалам, после
+// последнего чанка считает попарные корреляции и собирает аномалии
+// (включая срабатывания правил), совпавшие по времени у нескольких
+// датчиков, в инциденты. Отчёт попадает в JobResult.Correlation рядом
+// с аномалиями датчиков.
+type readingCorrelation struct {
+	cfg    utils.CorrelationConfig
+	series *utils.CorrelationSeries
+}
+
+func newReadingCorrelation(spec *entity.CorrelationSpec) (*readingCorrelation, error) {
+	cfg, err := correlationConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &readingCorrelation{cfg: cfg, series: utils.NewCorrelationSeries(cfg.Interval)}, nil
+}
+
+// Add учитывает показания очередного чанка.
+func (c *readingCorrelation) Add(readings []entity.SensorReading) {
+	for _, r := range readings {
+		for metric, v := range r.Values() {
+			c.series.Add(utils.SeriesKey{SensorID: r.SensorID, Metric: metric}, r.Timestamp, v)
+		}
+	}
+}
+
+// Report строит отчёт по накопленным рядам и аномалиям всех чанков.
+func (c *readingCorrelation) Report(anomalies []entity.Anomaly) *utils.CorrelationReport {
+	events := make([]utils.AnomalyEvent, 0, len(anomalies))
+	for _, a := range anomalies {
+		events = append(events, utils.AnomalyEvent{
+			SeriesKey: utils.SeriesKey{SensorID: a.Reading.SensorID, Metric: a.Metric},
+			Time:      a.Reading.Timestamp,
+			Detector:  a.Detector,
+			Severity:  utils.Severity(a.Severity),
+		})
+	}
+
+	rep := c.series.Analyze(events, c.cfg)
+	return &rep
+}
@@ -0,0 +1,287 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeJobRepo хранит задания в памяти; SaveJobResult сливает поля
+// результата так же, как jsonb-слияние в Postgres.
+type fakeJobRepo struct {
+	mu       sync.Mutex
+	jobs     map[string]*entity.Job
+	statuses []entity.JobStatus
+}
+
+func newFakeJobRepo(jobs ...*entity.Job) *fakeJobRepo {
+	r := &fakeJobRepo{jobs: map[string]*entity.Job{}}
+	for _, j := range jobs {
+		r.jobs[j.JobID] = j
+	}
+	return r
+}
+
+func (r *fakeJobRepo) job(jobID string) (*entity.Job, error) {
+	j, ok := r.jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", jobID)
+	}
+	return j, nil
+}
+
+func (r *fakeJobRepo) GetJob(_ context.Context, jobID string) (*entity.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.job(jobID)
+}
+
+func (r *fakeJobRepo) UpdateJobStatus(_ context.Context, jobID string, status entity.JobStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, err := r.job(jobID)
+	if err != nil {
+		return err
+	}
+	j.Status = status
+	r.statuses = append(r.statuses, status)
+	return nil
+}
+
+func (r *fakeJobRepo) SaveDiagnostics(_ context.Context, jobID string, diag entity.JobDiagnostics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, err := r.job(jobID)
+	if err != nil {
+		return err
+	}
+	j.Diagnostics = diag
+	return nil
+}
+
+func (r *fakeJobRepo) SaveSchemaReport(_ context.Context, jobID string, report entity.SchemaReport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, err := r.job(jobID)
+	if err != nil {
+		return err
+	}
+	j.SchemaReport = report
+	return nil
+}
+
+func (r *fakeJobRepo) SaveJobResult(_ context.Context, jobID string, result entity.JobResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, err := r.job(jobID)
+	if err != nil {
+		return err
+	}
+	merged := map[string]json.RawMessage{}
+	for _, v := range []entity.JobResult{j.Result, result} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	j.Result = entity.JobResult{}
+	return json.Unmarshal(data, &j.Result)
+}
+
+type fakeStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{objects: map[string][]byte{}}
+}
+
+func (s *fakeStorage) UploadChunk(_ context.Context, tenantID, key string, file []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[tenantID+"/"+key] = append([]byte(nil), file...)
+	return nil
+}
+
+func (s *fakeStorage) UploadStream(ctx context.Context, tenantID, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("object %s: read %d bytes, want %d", key, len(data), size)
+	}
+	return s.UploadChunk(ctx, tenantID, key, data)
+}
+
+func (s *fakeStorage) GetFileReader(_ context.Context, tenantID, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[tenantID+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+type fakePublisher struct {
+	mu     sync.Mutex
+	chunks []entity.Chunk
+}
+
+func (p *fakePublisher) Publish(_ context.Context, body json.RawMessage) error {
+	var c entity.Chunk
+	if err := json.Unmarshal(body, &c); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chunks = append(p.chunks, c)
+	return nil
+}
+
+type fakeProgress struct{}
+
+func (fakeProgress) SetChunkStatus(context.Context, string, int, string) error { return nil }
+func (fakeProgress) GetJobProgress(context.Context, string) (int, int, error)  { return 0, 0, nil }
+
+type fakeSchemaRepo struct{}
+
+func (fakeSchemaRepo) GetProtoSchema(context.Context, string) (*entity.ProtoSchema, error) {
+	return nil, fmt.Errorf("no schema")
+}
+
+type fakeRuleRepo struct {
+	rules []entity.AlertRule
+}
+
+func (r fakeRuleRepo) ListEnabledAlertRules(context.Context, string) ([]entity.AlertRule, error) {
+	return r.rules, nil
+}
+
+// chunkerFixture — ChunkerUseCase на фейковых репозиториях с загруженным
+// файлом задания.
+type chunkerFixture struct {
+	uc        *ChunkerUseCase
+	jobs      *fakeJobRepo
+	storage   *fakeStorage
+	publisher *fakePublisher
+	job       *entity.Job
+}
+
+func newChunkerFixture(t *testing.T, fileKey string, file []byte, opts entity.JobOptions) *chunkerFixture {
+	t.Helper()
+	job := &entity.Job{JobID: "job-1", UserID: "tenant-1", FileKey: fileKey, Status: entity.StatusPending, Options: opts}
+	f := &chunkerFixture{
+		jobs:      newFakeJobRepo(job),
+		storage:   newFakeStorage(),
+		publisher: &fakePublisher{},
+		job:       job,
+	}
+	if err := f.storage.UploadChunk(context.Background(), job.UserID, fileKey, file); err != nil {
+		t.Fatal(err)
+	}
+	f.uc = NewChunkerUseCase(f.jobs, fakeSchemaRepo{}, fakeRuleRepo{}, f.storage, f.publisher, fakeProgress{},
+		ChunkerConfig{Chunking: utils.ChunkPolicy{MaxRecords: 100}, SchemaSample: 100})
+	return f
+}
+
+// withAnalysis включает анализ опубликованных чанков. baselines может
+// быть nil — тогда анализ идёт без истории датчиков.
+func (f *chunkerFixture) withAnalysis(baselines ...BaselineRepo) *chunkerFixture {
+	var updater *BaselineUpdater
+	if len(baselines) > 0 {
+		updater = NewBaselineUpdater(baselines[0])
+	}
+	f.uc.Analysis = NewJobAnalysis(f.storage, NewChunkReader(f.uc.Keys), NewResultWriter(f.storage), f.uc.Detectors, updater)
+	return f
+}
+
+func buildZip(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeKeys «оборачивает» ключ данных без шифрования.
+type fakeKeys struct{}
+
+func (fakeKeys) WrapKey(_ context.Context, key []byte) (entity.WrappedKey, error) {
+	return entity.WrappedKey{KeyID: "test", Ciphertext: append([]byte(nil), key...)}, nil
+}
+
+func (fakeKeys) UnwrapKey(_ context.Context, wk entity.WrappedKey) ([]byte, error) {
+	return wk.Ciphertext, nil
+}
+
+// fakeBaselineRepo хранит историю датчиков в памяти.
+type fakeBaselineRepo struct {
+	mu        sync.Mutex
+	baselines map[string]*entity.SensorBaseline
+}
+
+func newFakeBaselineRepo() *fakeBaselineRepo {
+	return &fakeBaselineRepo{baselines: map[string]*entity.SensorBaseline{}}
+}
+
+func (r *fakeBaselineRepo) GetBaseline(_ context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.baselines[tenantID+"/"+sensorID], nil
+}
+
+func (r *fakeBaselineRepo) UpdateBaselines(_ context.Context, tenantID string, sensorIDs []string, apply func(b *entity.SensorBaseline) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range sensorIDs {
+		b, ok := r.baselines[tenantID+"/"+id]
+		if !ok {
+			b = &entity.SensorBaseline{TenantID: tenantID, SensorID: id, Metrics: entity.BaselineMetrics{}}
+		}
+		if apply(b) {
+			r.baselines[tenantID+"/"+id] = b
+		}
+	}
+	return nil
+}
+
+// fakeStatusCache запоминает записи статуса по порядку.
+type fakeStatusCache struct {
+	mu      sync.Mutex
+	records []entity.JobStatusRecord
+}
+
+func (c *fakeStatusCache) SetJobStatus(_ context.Context, _ string, rec entity.JobStatusRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, rec)
+	return nil
+}
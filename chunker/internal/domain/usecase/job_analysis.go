@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// JobAnalysis — этап анализа задания после публикации чанков. Чанки
+// читаются из хранилища так, как их получит воркер (ChunkReader:
+// распаковка и расшифровка полей), и по порядку проходят через один
+// ChunkAnalyzer задания. Артефакты результата пишет Results.
+//
+// Опубликованные чанки идут и потребителям очереди, и сюда, но
+// ChunkResult и результат задания считает только этот этап: сообщения
+// чанков результатов не несут и обратно в чанкер не возвращаются.
+type JobAnalysis struct {
+	Storage   Storage
+	Reader    *ChunkReader
+	Results   *ResultWriter
+	Detectors *utils.DetectorRegistry
+	// Baselines — история датчиков: из неё детектор baseline берёт
+	// ожидаемые значения, в неё попадают наблюдения задания. nil = без
+	// истории.
+	Baselines *BaselineUpdater
+}
+
+func NewJobAnalysis(s Storage, r *ChunkReader, w *ResultWriter, detectors *utils.DetectorRegistry, baselines *BaselineUpdater) *JobAnalysis {
+	return &JobAnalysis{Storage: s, Reader: r, Results: w, Detectors: detectors, Baselines: baselines}
+}
+
+// Run анализирует опубликованные чанки задания и возвращает поля
+// результата, которые считает этот этап; quality — отчёт о качестве,
+// посчитанный при нарезке, он попадает в PDF-отчёт.
+func (a *JobAnalysis) Run(ctx context.Context, job *entity.Job, chunks []entity.Chunk, quality *utils.QualityReport) (entity.JobResult, error) {
+	result := entity.JobResult{Quality: quality, Artifacts: map[string]string{}}
+	if err := a.analyzeChunks(ctx, job, chunks, &result); err != nil {
+		return result, err
+	}
+
+	key, err := a.Results.WriteReport(ctx, job, result)
+	if err != nil {
+		return result, fmt.Errorf("report: %w", err)
+	}
+	result.Artifacts[entity.ArtifactReport] = key
+	return result, nil
+}
+
+// analyzeChunks прогоняет чанки через анализатор по одному. Показания
+// чанка сразу уходят в Parquet (временный файл, row group на чанк),
+// в передискретизацию и корреляцию, которые хранят только агрегаты по
+// интервалам, так что показания всего задания в памяти не собираются.
+// Наблюдения переносятся в историю датчиков, когда все чанки уже
+// разобраны.
+func (a *JobAnalysis) analyzeChunks(ctx context.Context, job *entity.Job, chunks []entity.Chunk, result *entity.JobResult) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	var lookup utils.BaselineLookup
+	if a.Baselines != nil {
+		lookup = a.Baselines.Lookup(ctx, job.UserID)
+	}
+	// детекторы и правила одинаковы во всех чанках задания
+	analyzer, err := NewChunkAnalyzer(chunks[0].Detectors, chunks[0].Rules, a.Detectors, lookup)
+	if err != nil {
+		return err
+	}
+	correlation, err := newReadingCorrelation(job.Options.Correlation)
+	if err != nil {
+		return fmt.Errorf("correlate: %w", err)
+	}
+	var resampled *readingResampler
+	if spec := job.Options.Resample; spec != nil {
+		if resampled, err = newReadingResampler(*spec); err != nil {
+			return fmt.Errorf("resample: %w", err)
+		}
+	}
+	readings, err := utils.NewParquetFileWriter[entity.SensorReading]()
+	if err != nil {
+		return fmt.Errorf("readings parquet: %w", err)
+	}
+	defer readings.Remove()
+
+	var anomalies []entity.Anomaly
+	var chunkResults []entity.ChunkResult
+	for _, chunk := range chunks {
+		rows, err := a.readChunk(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		res := analyzer.Analyze(chunk, rows)
+		anomalies = append(anomalies, res.Anomalies...)
+		chunkResults = append(chunkResults, res)
+
+		if err := readings.Write(rows); err != nil {
+			return fmt.Errorf("readings parquet: %w", err)
+		}
+		correlation.Add(rows)
+		if resampled != nil {
+			resampled.Add(rows)
+		}
+	}
+
+	result.Anomalies = anomalyReport(anomalies)
+	if result.Metrics, err = SummarizeChunks(chunkResults); err != nil {
+		return fmt.Errorf("summarize: %w", err)
+	}
+	result.Correlation = correlation.Report(anomalies)
+
+	key, err := a.Results.UploadReadings(ctx, job.UserID, job.JobID, readings)
+	if err != nil {
+		return fmt.Errorf("readings parquet: %w", err)
+	}
+	result.Artifacts[entity.ArtifactReadings] = key
+	if resampled != nil {
+		if err := a.Results.WriteResampled(ctx, job.UserID, job.JobID, resampled, result); err != nil {
+			return fmt.Errorf("resample: %w", err)
+		}
+	}
+
+	if a.Baselines != nil {
+		if err := a.Baselines.Update(ctx, job.UserID, job.JobID, analyzer.Observed()); err != nil {
+			return fmt.Errorf("update baselines: %w", err)
+		}
+	}
+	return nil
+}
+
+// readChunk загружает чанк и разбирает его показания.
+func (a *JobAnalysis) readChunk(ctx context.Context, chunk entity.Chunk) ([]entity.SensorReading, error) {
+	rc, err := a.Storage.GetFileReader(ctx, chunk.TenantID, chunk.PayloadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read chunk %d: %w", chunk.ChunkID, err)
+	}
+	if data, err = a.Reader.ReadPayload(ctx, chunk, data); err != nil {
+		return nil, err
+	}
+
+	var readings []entity.SensorReading
+	if err := json.Unmarshal(data, &readings); err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", chunk.ChunkID, err)
+	}
+	return readings, nil
+}
+
+// anomalyReport считает аномалии по детекторам и оставляет первые
+// MaxResultAnomalies по времени.
+func anomalyReport(anomalies []entity.Anomaly) *entity.AnomalyReport {
+	if len(anomalies) == 0 {
+		return nil
+	}
+	ordered := append([]entity.Anomaly(nil), anomalies...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Reading.Timestamp.Before(ordered[j].Reading.Timestamp)
+	})
+
+	rep := &entity.AnomalyReport{Total: len(ordered), ByDetector: map[string]int{}}
+	for _, an := range ordered {
+		rep.ByDetector[an.Detector]++
+		if len(rep.Items) == entity.MaxResultAnomalies {
+			rep.Truncated = true
+			continue
+		}
+		rep.Items = append(rep.Items, entity.AnomalyItem{
+			SensorID:  an.Reading.SensorID,
+			Timestamp: an.Reading.Timestamp,
+			Metric:    an.Metric,
+			Value:     an.Value,
+			Detector:  an.Detector,
+			Severity:  an.Severity,
+			Reason:    an.Reason,
+		})
+	}
+	return rep
+}
@@ -0,0 +1,384 @@
+package usecase
+
+import (
+	"bytes"
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// analysisCSV — 150 показаний датчика s1 (два чанка по 100), температура
+// растёт от 20 с шагом 0.1 и один раз подскакивает до 90.
+func analysisCSV(spike int) string {
+	var b strings.Builder
+	b.WriteString("timestamp,sensor_id,temperature,humidity\n")
+	for i := 0; i < 150; i++ {
+		ts := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339)
+		temp := 20 + float64(i)/10
+		if i == spike {
+			temp = 90
+		}
+		fmt.Fprintf(&b, "%s,s1,%g,%d\n", ts, temp, 40+i%5)
+	}
+	return b.String()
+}
+
+func TestProcessJobAnalyzesPublishedChunks(t *testing.T) {
+	hi := 50.0
+	opts := entity.JobOptions{Anomalies: []entity.DetectorSpec{{Name: "threshold", Metrics: []string{"temperature"}, Max: &hi}}}
+	f := newChunkerFixture(t, "data.csv", []byte(analysisCSV(120)), opts)
+	f.uc.Keys = fakeKeys{}
+	f.uc.RuleRepo = fakeRuleRepo{rules: []entity.AlertRule{{Name: "hot", Expression: "temperature > 80", Severity: "critical", Enabled: true}}}
+	f.withAnalysis()
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.publisher.chunks) != 2 || f.job.Diagnostics.EncryptedValues == 0 {
+		t.Fatalf("chunks %d, encrypted %d", len(f.publisher.chunks), f.job.Diagnostics.EncryptedValues)
+	}
+	if got := f.jobs.statuses; len(got) < 2 || got[len(got)-1] != entity.StatusCompleted {
+		t.Fatalf("statuses: %v", got)
+	}
+
+	rep := f.job.Result.Anomalies
+	if rep == nil || rep.Total != 2 || rep.ByDetector["threshold"] != 1 || rep.ByDetector["rule:hot"] != 1 {
+		t.Fatalf("anomalies: %+v", rep)
+	}
+	want := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	for _, a := range rep.Items {
+		if a.SensorID != "s1" || !a.Timestamp.Equal(want) {
+			t.Errorf("anomaly: %+v", a)
+		}
+	}
+	if f.job.Result.Quality == nil {
+		t.Error("analysis result replaced the quality report")
+	}
+
+	// показания задания выгружены в Parquet расшифрованными
+	key := f.job.Result.Artifacts[entity.ArtifactReadings]
+	data := f.storage.objects[f.job.UserID+"/"+key]
+	readings, err := parquet.Read[entity.SensorReading](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("readings artifact %q: %v", key, err)
+	}
+	if len(readings) != 150 || metric(readings[120], "temperature") != 90 || metric(readings[0], "humidity") != 40 {
+		t.Errorf("got %d readings, first %+v", len(readings), readings[0])
+	}
+	// чанки пишутся в файл по одному: row group на чанк
+	if pf, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data))); err != nil || len(pf.RowGroups()) != 2 {
+		t.Errorf("readings artifact row groups: %v", err)
+	}
+}
+
+func TestProcessJobResamplesReadings(t *testing.T) {
+	// показания s1 каждую минуту, кроме 01:00–01:29
+	var b strings.Builder
+	b.WriteString("timestamp,sensor_id,temperature,humidity\n")
+	for i := 0; i < 150; i++ {
+		if i >= 60 && i < 90 {
+			continue
+		}
+		ts := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339)
+		fmt.Fprintf(&b, "%s,s1,%d,40\n", ts, 20+i/10)
+	}
+	opts := entity.JobOptions{Resample: &entity.ResampleSpec{Interval: "10m", Fill: "linear"}}
+	f := newChunkerFixture(t, "data.csv", []byte(b.String()), opts)
+	f.withAnalysis()
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	gaps := f.job.Result.Gaps
+	if len(gaps) != 1 || gaps[0].SensorID != "s1" || gaps[0].Seconds != 31*60 {
+		t.Fatalf("gaps: %+v", gaps)
+	}
+
+	key := f.job.Result.Artifacts[entity.ArtifactResampled]
+	data := f.storage.objects[f.job.UserID+"/"+key]
+	rows, err := parquet.Read[entity.ResampledReading](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("resampled artifact %q: %v", key, err)
+	}
+	// 12 интервалов с показаниями и 3 заполненных в разрыве
+	if len(rows) != 15 || rows[0].Samples != 10 || rows[0].Temperature == nil || *rows[0].Temperature != 20 {
+		t.Fatalf("got %d rows, first %+v", len(rows), rows[0])
+	}
+	if rows[7].Samples != 0 || rows[7].Temperature == nil || *rows[7].Temperature != 27 {
+		t.Errorf("filled interval: %+v", rows[7])
+	}
+	if f.job.Result.Artifacts[entity.ArtifactReadings] == "" {
+		t.Error("readings artifact missing")
+	}
+}
+
+func TestProcessJobCachesStatusAndWritesReport(t *testing.T) {
+	hi := 50.0
+	opts := entity.JobOptions{Anomalies: []entity.DetectorSpec{{Name: "threshold", Metrics: []string{"temperature"}, Max: &hi}}}
+	f := newChunkerFixture(t, "data.csv", []byte(analysisCSV(120)), opts)
+	cache := &fakeStatusCache{}
+	f.uc.StatusCache = cache
+	f.withAnalysis()
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	var statuses []entity.JobStatus
+	for _, rec := range cache.records {
+		statuses = append(statuses, rec.Status)
+		if rec.UserID != f.job.UserID || rec.Quality == nil || rec.Quality.Records != 150 || rec.Quality.Sensors != nil {
+			t.Fatalf("status record: %+v", rec)
+		}
+	}
+	if fmt.Sprint(statuses) != fmt.Sprint(f.jobs.statuses) {
+		t.Fatalf("cached statuses %v, repo statuses %v", statuses, f.jobs.statuses)
+	}
+
+	key := f.job.Result.Artifacts[entity.ArtifactReport]
+	pdf := f.storage.objects[f.job.UserID+"/"+key]
+	if key != "jobs/job-1/result.pdf" || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Fatalf("report %q: %d bytes", key, len(pdf))
+	}
+	for _, want := range []string{"(Data quality) Tj", "(Score: ", "(  s1: score ", "(  threshold: 1) Tj"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("report has no %q", want)
+		}
+	}
+}
+
+func TestProcessJobSummarizesMetrics(t *testing.T) {
+	f := newChunkerFixture(t, "data.csv", []byte(analysisCSV(120)), entity.JobOptions{})
+	f.withAnalysis()
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	// статистика слита из двух чанков
+	temp, ok := f.job.Result.Metrics["temperature"]
+	if !ok || temp.Count != 150 || temp.Min != 20 || temp.Max != 90 {
+		t.Fatalf("temperature: %+v", f.job.Result.Metrics)
+	}
+	values := make([]float64, 150)
+	for i := range values {
+		values[i] = 20 + float64(i)/10
+	}
+	values[120] = 90
+	exact := utils.Percentiles(values, utils.StatPercentiles)
+	for i, got := range []float64{temp.P50, temp.P90, temp.P95, temp.P99} {
+		if math.Abs(got-exact[i])/exact[i] > 2*utils.DefaultSketchAccuracy {
+			t.Errorf("p%g = %g, exact %g", utils.StatPercentiles[i]*100, got, exact[i])
+		}
+	}
+	if hum := f.job.Result.Metrics["humidity"]; hum.Count != 150 || hum.Min != 40 || hum.Max != 44 || hum.Mean != 42 {
+		t.Errorf("humidity: %+v", hum)
+	}
+
+	pdf := f.storage.objects[f.job.UserID+"/"+f.job.Result.Artifacts[entity.ArtifactReport]]
+	if !bytes.Contains(pdf, []byte("(temperature: 150 values, min 20, max 90")) {
+		t.Error("report has no metric summary")
+	}
+}
+
+func TestSummarizeChunksClampsPercentiles(t *testing.T) {
+	// у постоянного значения оценка корзины скетча не равна самому значению
+	chunk := func(id int) entity.ChunkResult {
+		sketch := utils.NewDDSketch(utils.DefaultSketchAccuracy)
+		for i := 0; i < 10; i++ {
+			sketch.Add(21.3)
+		}
+		st := entity.Stats{Min: 21.3, Max: 21.3, Mean: 21.3, Count: 10, Sketch: sketch}
+		return entity.ChunkResult{ChunkID: id, Stats: entity.ChunkStats{Temperature: st}}
+	}
+	if est := chunk(0).Stats.Temperature.Sketch.Quantile(0.5); est == 21.3 {
+		t.Fatalf("sketch estimate %g is exact, the test checks nothing", est)
+	}
+
+	summary, err := SummarizeChunks([]entity.ChunkResult{chunk(0), chunk(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := summary["temperature"]
+	for _, got := range []float64{temp.P50, temp.P90, temp.P95, temp.P99} {
+		if got != 21.3 {
+			t.Errorf("percentile %g outside [%g, %g]", got, temp.Min, temp.Max)
+		}
+	}
+}
+
+func TestProcessJobCorrelatesSensors(t *testing.T) {
+	// s2 повторяет s1 со сдвигом на 5 градусов, у обоих скачок в 00:30
+	var b strings.Builder
+	b.WriteString("timestamp,sensor_id,temperature,humidity\n")
+	for i := 0; i < 60; i++ {
+		ts := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339)
+		temp := 20 + float64(i)/10
+		if i == 30 {
+			temp = 90
+		}
+		fmt.Fprintf(&b, "%s,s1,%g,%d\n", ts, temp, 40+i%5)
+		fmt.Fprintf(&b, "%s,s2,%g,%d\n", ts, temp+5, 60-i%5)
+	}
+	hi := 80.0
+	opts := entity.JobOptions{Anomalies: []entity.DetectorSpec{{Name: "threshold", Metrics: []string{"temperature"}, Max: &hi}}}
+	f := newChunkerFixture(t, "data.csv", []byte(b.String()), opts)
+	f.withAnalysis()
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	rep := f.job.Result.Correlation
+	if rep == nil {
+		t.Fatal("no correlation report")
+	}
+	pairs := map[string]float64{}
+	for _, p := range rep.Pairs {
+		pairs[p.A.SensorID+"/"+p.A.Metric+"~"+p.B.SensorID+"/"+p.B.Metric] = p.Coefficient
+	}
+	if c := pairs["s1/temperature~s2/temperature"]; c < 0.99 {
+		t.Errorf("temperature pair: %v", pairs)
+	}
+	if c := pairs["s1/humidity~s2/humidity"]; c > -0.99 {
+		t.Errorf("humidity pair: %v", pairs)
+	}
+
+	if len(rep.Incidents) != 1 {
+		t.Fatalf("incidents: %+v", rep.Incidents)
+	}
+	inc := rep.Incidents[0]
+	want := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+	if inc.Anomalies != 2 || strings.Join(inc.Sensors, ",") != "s1,s2" || !inc.Start.Equal(want) || inc.Correlation < 0.99 {
+		t.Errorf("incident: %+v", inc)
+	}
+
+	pdf := f.storage.objects[f.job.UserID+"/"+f.job.Result.Artifacts[entity.ArtifactReport]]
+	if !bytes.Contains(pdf, []byte("(  incident 2024-01-01T00:30:00Z")) {
+		t.Error("report has no incidents")
+	}
+}
+
+func TestProcessJobKeepsZeroAndAbsentMetricsApart(t *testing.T) {
+	// s1 мерит только температуру, половина значений — настоящие 0 °C;
+	// s2 мерит только CO2
+	var b strings.Builder
+	for i := 0; i < 10; i++ {
+		ts := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339)
+		fmt.Fprintf(&b, `{"timestamp":%q,"sensor_id":"s1","temperature":%d}`+"\n", ts, 2*(i%2))
+		fmt.Fprintf(&b, `{"timestamp":%q,"sensor_id":"s2","co2":%d}`+"\n", ts, 410+i)
+	}
+	opts := entity.JobOptions{Resample: &entity.ResampleSpec{Interval: "2m", Fill: "linear"}}
+	f := newChunkerFixture(t, "data.ndjson", []byte(b.String()), opts)
+	f.withAnalysis()
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	metrics := f.job.Result.Metrics
+	if temp := metrics["temperature"]; temp.Count != 10 || temp.Min != 0 || temp.Max != 2 || temp.Mean != 1 {
+		t.Errorf("temperature: %+v", temp)
+	}
+	if co2 := metrics["co2"]; co2.Count != 10 || co2.Min != 410 {
+		t.Errorf("co2: %+v", co2)
+	}
+	for _, absent := range []string{"humidity", "pressure"} {
+		if m, ok := metrics[absent]; ok {
+			t.Errorf("%s is not in the upload but summarized: %+v", absent, m)
+		}
+	}
+	if q := f.job.Result.Quality; q == nil || q.OutOfRange != 0 {
+		t.Errorf("quality: %+v", q)
+	}
+
+	for _, r := range publishedReadings(t, f) {
+		_, hasTemp := r.Value("temperature")
+		if hasTemp != (r.SensorID == "s1") || r.Humidity != nil || r.Pressure != nil {
+			t.Fatalf("published reading: %+v", r)
+		}
+	}
+
+	key := f.job.Result.Artifacts[entity.ArtifactResampled]
+	data := f.storage.objects[f.job.UserID+"/"+key]
+	rows, err := parquet.Read[entity.ResampledReading](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		switch {
+		case row.SensorID == "s1" && (row.Temperature == nil || *row.Temperature != 1):
+			t.Errorf("s1 interval: %+v", row)
+		case row.SensorID == "s2" && (row.Temperature != nil || row.Metrics["co2"] == 0):
+			t.Errorf("s2 interval: %+v", row)
+		}
+	}
+}
+
+func TestProcessJobFailsWhenChunksCannotBeRead(t *testing.T) {
+	f := newChunkerFixture(t, "data.csv", []byte(analysisCSV(-1)), entity.JobOptions{})
+	f.uc.Keys = fakeKeys{}
+	f.withAnalysis()
+	// анализ без KMS не расшифрует поля
+	f.uc.Analysis.Reader = NewChunkReader(nil)
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if f.job.Status != entity.StatusFailed {
+		t.Fatalf("status %s, want FAILED", f.job.Status)
+	}
+	if _, ok := f.job.Result.Artifacts[entity.ArtifactReport]; ok {
+		t.Error("failed job has a report")
+	}
+}
+
+func TestProcessJobUpdatesBaselines(t *testing.T) {
+	repo := newFakeBaselineRepo()
+	opts := entity.JobOptions{Anomalies: []entity.DetectorSpec{{Name: "baseline"}}}
+	f := newChunkerFixture(t, "data.csv", []byte(analysisCSV(-1)), opts)
+	f.uc.Keys = fakeKeys{}
+	f.withAnalysis(repo)
+
+	if err := f.uc.ProcessJob(context.Background(), f.job); err != nil {
+		t.Fatal(err)
+	}
+	if f.job.Status != entity.StatusCompleted || f.job.Result.Anomalies != nil {
+		t.Fatalf("status %s, anomalies %+v", f.job.Status, f.job.Result.Anomalies)
+	}
+	b, err := repo.GetBaseline(context.Background(), f.job.UserID, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Jobs != 1 || b.LastJobID != "job-1" || b.Metrics["temperature"] == nil || b.Metrics["temperature"].Overall.N != 150 {
+		t.Fatalf("baseline: %+v", b)
+	}
+
+	// повторная доставка того же задания историю не меняет
+	if _, err := f.uc.Analysis.Run(context.Background(), f.job, f.publisher.chunks, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = repo.GetBaseline(context.Background(), f.job.UserID, "s1"); b.Jobs != 1 || b.Metrics["temperature"].Overall.N != 150 {
+		t.Fatalf("redelivery changed baseline: jobs %d, n %g", b.Jobs, b.Metrics["temperature"].Overall.N)
+	}
+
+	// следующее задание тенанта сверяется с накопленной историей
+	next := newChunkerFixture(t, "data.csv", []byte(analysisCSV(120)), opts)
+	next.job.JobID = "job-2"
+	next.jobs.jobs = map[string]*entity.Job{next.job.JobID: next.job}
+	next.uc.Keys = fakeKeys{}
+	next.withAnalysis(repo)
+	if err := next.uc.ProcessJob(context.Background(), next.job); err != nil {
+		t.Fatal(err)
+	}
+	rep := next.job.Result.Anomalies
+	if rep == nil || rep.ByDetector["baseline"] == 0 {
+		t.Fatalf("baseline detector found nothing: %+v", rep)
+	}
+	if b, _ = repo.GetBaseline(context.Background(), f.job.UserID, "s1"); b.Jobs != 2 || b.LastJobID != "job-2" {
+		t.Fatalf("baseline after second job: jobs %d, last %s", b.Jobs, b.LastJobID)
+	}
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Сколько строк раздела попадает в отчёт; полные данные — в результате
+// задания и артефактах.
+const (
+	reportMaxSensors   = 50
+	reportMaxAnomalies = 20
+	reportMaxGaps      = 20
+	reportMaxPairs     = 20
+	reportMaxIncidents = 20
+)
+
+// renderReport собирает PDF-отчёт завершённого задания.
+func renderReport(job *entity.Job, result entity.JobResult) []byte {
+	doc := utils.NewPDFDocument()
+	doc.Heading("Job " + job.JobID)
+	doc.Text("File: %s", job.FileKey)
+	doc.Text("Generated: %s", time.Now().UTC().Format(time.RFC3339))
+
+	if q := result.Quality; q != nil {
+		doc.Heading("Data quality")
+		doc.Text("Score: %.1f / 100", q.Score)
+		doc.Text("Records: %d, completeness %.1f%%", q.Records, q.Completeness*100)
+		doc.Text("Duplicates: %d, out of order: %d, out of range: %d", q.Duplicates, q.OutOfOrder, q.OutOfRange)
+		doc.Text("Clock skew: %d, parse failures: %d", q.ClockSkewed, q.ParseFailures)
+		for i, s := range q.Sensors {
+			if i == reportMaxSensors {
+				doc.Text("... and %d more sensors", len(q.Sensors)-i)
+				break
+			}
+			doc.Text("  %s: score %.1f, %d records, completeness %.1f%%", s.SensorID, s.Score, s.Records, s.Completeness*100)
+		}
+	}
+
+	if len(result.Metrics) > 0 {
+		doc.Heading("Metrics")
+		metrics := make([]string, 0, len(result.Metrics))
+		for name := range result.Metrics {
+			metrics = append(metrics, name)
+		}
+		sort.Strings(metrics)
+		for _, name := range metrics {
+			m := result.Metrics[name]
+			doc.Text("%s: %d values, min %.4g, max %.4g, mean %.4g, std %.3g", name, m.Count, m.Min, m.Max, m.Mean, m.Std)
+			doc.Text("  p50 %.4g, p90 %.4g, p95 %.4g, p99 %.4g", m.P50, m.P90, m.P95, m.P99)
+		}
+	}
+
+	doc.Heading("Anomalies")
+	if rep := result.Anomalies; rep == nil {
+		doc.Text("None found")
+	} else {
+		doc.Text("Total: %d", rep.Total)
+		detectors := make([]string, 0, len(rep.ByDetector))
+		for name := range rep.ByDetector {
+			detectors = append(detectors, name)
+		}
+		sort.Strings(detectors)
+		for _, name := range detectors {
+			doc.Text("  %s: %d", name, rep.ByDetector[name])
+		}
+		for i, a := range rep.Items {
+			if i == reportMaxAnomalies {
+				doc.Text("... see the job result for all anomalies")
+				break
+			}
+			doc.Text("  %s %s %s [%s] %s", a.Timestamp.Format(time.RFC3339), a.SensorID, a.Detector, a.Severity, a.Reason)
+		}
+	}
+
+	if c := result.Correlation; c != nil && (len(c.Pairs) > 0 || len(c.Incidents) > 0) {
+		doc.Heading("Correlation")
+		for i, p := range c.Pairs {
+			if i == reportMaxPairs {
+				doc.Text("... and %d more pairs", len(c.Pairs)-i)
+				break
+			}
+			doc.Text("  %s/%s ~ %s/%s: %.3f over %d intervals", p.A.SensorID, p.A.Metric, p.B.SensorID, p.B.Metric, p.Coefficient, p.Samples)
+		}
+		for i, inc := range c.Incidents {
+			if i == reportMaxIncidents {
+				doc.Text("... and %d more incidents", len(c.Incidents)-i)
+				break
+			}
+			doc.Text("  incident %s - %s [%s]: %d anomalies on %s", inc.Start.Format(time.RFC3339), inc.End.Format(time.RFC3339), inc.Severity, inc.Anomalies, strings.Join(inc.Sensors, ", "))
+		}
+	}
+
+	if len(result.Gaps) > 0 {
+		doc.Heading("Gaps")
+		for i, g := range result.Gaps {
+			if i == reportMaxGaps {
+				doc.Text("... and %d more gaps", len(result.Gaps)-i)
+				break
+			}
+			doc.Text("  %s: %s - %s (%.0f s)", g.SensorID, g.Start.Format(time.RFC3339), g.End.Format(time.RFC3339), g.Seconds)
+		}
+	}
+	return doc.Bytes()
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"sort"
+)
+
+func resampleConfig(spec entity.ResampleSpec) (utils.ResampleConfig, error) {
+	return utils.ParseResampleConfig(spec.Interval, spec.Aggregate, spec.GapThreshold, spec.Fill)
+}
+
+// readingResampler — шаг JobAnalysis: по мере разбора чанков сводит ряды
+// показаний каждого датчика к интервалу spec. Показания не копятся:
+// utils.Resampler хранит только агрегаты интервалов.
+type readingResampler struct {
+	cfg     utils.ResampleConfig
+	sensors map[string]*utils.Resampler
+}
+
+func newReadingResampler(spec entity.ResampleSpec) (*readingResampler, error) {
+	cfg, err := resampleConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &readingResampler{cfg: cfg, sensors: map[string]*utils.Resampler{}}, nil
+}
+
+// Add учитывает показания очередного чанка.
+func (s *readingResampler) Add(readings []entity.SensorReading) {
+	for _, r := range readings {
+		rs, ok := s.sensors[r.SensorID]
+		if !ok {
+			rs = utils.NewResampler(s.cfg)
+			s.sensors[r.SensorID] = rs
+		}
+		rs.Add(utils.SeriesPoint{Time: r.Timestamp, Values: r.Values()})
+	}
+}
+
+// Each передаёт fn ряд и разрывы каждого датчика по порядку ID; ряды
+// упорядочены по времени.
+func (s *readingResampler) Each(fn func(rows []entity.ResampledReading, gaps []entity.SeriesGap) error) error {
+	sensors := make([]string, 0, len(s.sensors))
+	for id := range s.sensors {
+		sensors = append(sensors, id)
+	}
+	sort.Strings(sensors)
+
+	for _, id := range sensors {
+		points, found := s.sensors[id].Result()
+		rows := make([]entity.ResampledReading, 0, len(points))
+		for _, p := range points {
+			rows = append(rows, resampledReading(id, p))
+		}
+		var gaps []entity.SeriesGap
+		for _, g := range found {
+			gaps = append(gaps, entity.SeriesGap{SensorID: id, Start: g.Start, End: g.End, Seconds: g.End.Sub(g.Start).Seconds()})
+		}
+		if err := fn(rows, gaps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resampledReading переносит интервал ряда в строку артефакта; метрики
+// без значения в интервале остаются пустыми.
+func resampledReading(sensorID string, p utils.SeriesPoint) entity.ResampledReading {
+	rr := entity.ResampledReading{Timestamp: p.Time, SensorID: sensorID, Samples: p.Samples}
+	for metric, v := range p.Values {
+		switch metric {
+		case "temperature":
+			rr.Temperature = &v
+		case "humidity":
+			rr.Humidity = &v
+		case "pressure":
+			rr.Pressure = &v
+		default:
+			if rr.Metrics == nil {
+				rr.Metrics = map[string]float64{}
+			}
+			rr.Metrics[metric] = v
+		}
+	}
+	return rr
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"fmt"
+)
+
+// ResultWriter сохраняет артефакты результата задания в то же хранилище,
+// что и чанки. Итоги анализа в задание записывает ChunkerUseCase.
+type ResultWriter struct {
+	Storage Storage
+}
+
+func NewResultWriter(s Storage) *ResultWriter {
+	return &ResultWriter{Storage: s}
+}
+
+// UploadReadings выгружает очищенные показания задания, записанные
+// в readings по чанкам, и возвращает ключ объекта.
+func (w *ResultWriter) UploadReadings(ctx context.Context, tenantID, jobID string, readings *utils.ParquetFileWriter[entity.SensorReading]) (string, error) {
+	return uploadParquet(ctx, w, tenantID, fmt.Sprintf("jobs/%s/result/readings.parquet", jobID), readings)
+}
+
+// WriteResampled выгружает ряды resampled в Parquet по датчику на row
+// group и дописывает в result ключ артефакта и отчёт о разрывах.
+func (w *ResultWriter) WriteResampled(ctx context.Context, tenantID, jobID string, resampled *readingResampler, result *entity.JobResult) error {
+	out, err := utils.NewParquetFileWriter[entity.ResampledReading]()
+	if err != nil {
+		return err
+	}
+	defer out.Remove()
+
+	var gaps []entity.SeriesGap
+	err = resampled.Each(func(rows []entity.ResampledReading, found []entity.SeriesGap) error {
+		gaps = append(gaps, found...)
+		return out.Write(rows)
+	})
+	if err != nil {
+		return err
+	}
+	key, err := uploadParquet(ctx, w, tenantID, fmt.Sprintf("jobs/%s/result/resampled.parquet", jobID), out)
+	if err != nil {
+		return err
+	}
+
+	if result.Artifacts == nil {
+		result.Artifacts = map[string]string{}
+	}
+	result.Artifacts[entity.ArtifactResampled] = key
+	result.Gaps = append(result.Gaps, gaps...)
+	return nil
+}
+
+// WriteReport выгружает PDF-отчёт задания и возвращает ключ объекта.
+// Ключ постоянный: по нему gateway отдаёт ссылку на отчёт в статусе.
+func (w *ResultWriter) WriteReport(ctx context.Context, job *entity.Job, result entity.JobResult) (string, error) {
+	key := fmt.Sprintf("jobs/%s/result.pdf", job.JobID)
+	if err := w.Storage.UploadChunk(ctx, job.UserID, key, renderReport(job, result)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func uploadParquet[T any](ctx context.Context, w *ResultWriter, tenantID, key string, f *utils.ParquetFileWriter[T]) (string, error) {
+	r, size, err := f.Finish()
+	if err != nil {
+		return "", err
+	}
+	if err := w.Storage.UploadStream(ctx, tenantID, key, r, size); err != nil {
+		return "", err
+	}
+	return key, nil
+}
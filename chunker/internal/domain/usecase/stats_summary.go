@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"fmt"
+	"math"
+)
+
+// SummarizeChunks сводит статистику чанков задания по метрикам: min/max,
+// среднее и отклонение сливаются точно, перцентили оцениваются по
+// слитым скетчам чанков и ограничиваются точными min/max (оценка
+// корзины скетча может выйти за диапазон). JobAnalysis кладёт результат в JobResult.Metrics.
+func SummarizeChunks(results []entity.ChunkResult) (map[string]entity.MetricSummary, error) {
+	type acc struct {
+		min, max float64
+		moments  utils.Moments
+		sketch   *utils.DDSketch
+	}
+	accs := map[string]*acc{}
+	for _, res := range results {
+		for metric, st := range res.Stats.All() {
+			if st.Count == 0 {
+				continue
+			}
+			a := accs[metric]
+			if a == nil {
+				a = &acc{min: math.Inf(1), max: math.Inf(-1), sketch: utils.NewDDSketch(utils.DefaultSketchAccuracy)}
+				accs[metric] = a
+			}
+			a.min = math.Min(a.min, st.Min)
+			a.max = math.Max(a.max, st.Max)
+			n := float64(st.Count)
+			a.moments.Merge(utils.Moments{N: n, Mean: st.Mean, M2: st.Std * st.Std * n})
+			if err := a.sketch.Merge(st.Sketch); err != nil {
+				return nil, fmt.Errorf("chunk %d %s: %w", res.ChunkID, metric, err)
+			}
+		}
+	}
+
+	out := make(map[string]entity.MetricSummary, len(accs))
+	for metric, a := range accs {
+		quantile := func(q float64) float64 {
+			return math.Min(math.Max(a.sketch.Quantile(q), a.min), a.max)
+		}
+		out[metric] = entity.MetricSummary{
+			Count: int(a.moments.N),
+			Min:   a.min,
+			Max:   a.max,
+			Mean:  a.moments.Mean,
+			Std:   math.Sqrt(a.moments.M2 / a.moments.N),
+			P50:   quantile(0.5),
+			P90:   quantile(0.9),
+			P95:   quantile(0.95),
+			P99:   quantile(0.99),
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package kms
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// LocalKMS — заменитель KMS для разработки и однохостовых установок:
+// мастер-ключ лежит в локальном файле. Ключ данных шифруется AES-GCM,
+// идентификатор ключа входит в AAD.
+type LocalKMS struct {
+	keyID     string
+	masterKey []byte
+}
+
+// NewLocalKMS читает мастер-ключ из файла: 32 байта как есть, в hex
+// или в base64.
+func NewLocalKMS(keyFile string) (*LocalKMS, error) {
+	key, err := utils.ReadKeyFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("master key: %w", err)
+	}
+
+	sum := sha256.Sum256(key)
+	return &LocalKMS{keyID: "local/" + hex.EncodeToString(sum[:8]), masterKey: key}, nil
+}
+
+func (k *LocalKMS) WrapKey(_ context.Context, key []byte) (entity.WrappedKey, error) {
+	ct, err := utils.SealAESGCM(k.masterKey, key, []byte(k.keyID))
+	if err != nil {
+		return entity.WrappedKey{}, fmt.Errorf("wrap data key: %w", err)
+	}
+	return entity.WrappedKey{KeyID: k.keyID, Ciphertext: ct}, nil
+}
+
+func (k *LocalKMS) UnwrapKey(_ context.Context, wk entity.WrappedKey) ([]byte, error) {
+	if wk.KeyID != k.keyID {
+		return nil, fmt.Errorf("data key is wrapped by %s, local master key is %s", wk.KeyID, k.keyID)
+	}
+	key, err := utils.OpenAESGCM(k.masterKey, wk.Ciphertext, []byte(k.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	return key, nil
+}
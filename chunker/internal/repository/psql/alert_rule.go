@@ -0,0 +1,22 @@
+package psql
+
+import (
+	"chunker/internal/domain/entity"
+	"context"
+	"gorm.io/gorm"
+)
+
+type GormAlertRuleRepo struct {
+	db *gorm.DB
+}
+
+func NewGormAlertRuleRepo(db *gorm.DB) *GormAlertRuleRepo {
+	return &GormAlertRuleRepo{db: db}
+}
+
+// ListEnabledAlertRules возвращает включённые правила тенанта по имени.
+func (r *GormAlertRuleRepo) ListEnabledAlertRules(ctx context.Context, tenantID string) ([]entity.AlertRule, error) {
+	var rules []entity.AlertRule
+	err := r.db.WithContext(ctx).Where("tenant_id = ? AND enabled", tenantID).Order("name").Find(&rules).Error
+	return rules, err
+}
@@ -0,0 +1,23 @@
+package psql
+
+import (
+	"chunker/internal/domain/entity"
+	"context"
+	"gorm.io/gorm"
+)
+
+type GormSchemaRepo struct {
+	db *gorm.DB
+}
+
+func NewGormSchemaRepo(db *gorm.DB) *GormSchemaRepo {
+	return &GormSchemaRepo{db: db}
+}
+
+func (r *GormSchemaRepo) GetProtoSchema(ctx context.Context, tenantID string) (*entity.ProtoSchema, error) {
+	var schema entity.ProtoSchema
+	if err := r.db.WithContext(ctx).First(&schema, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
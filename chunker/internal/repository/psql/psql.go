@@ -3,6 +3,7 @@ package psql
 import (
 	"chunker/internal/domain/entity"
 	"context"
+	"encoding/json"
 	"gorm.io/gorm"
 )
 
@@ -27,3 +28,27 @@ func (r *GormJobRepo) UpdateJobStatus(ctx context.Context, jobID string, status
 		Where("job_id = ?", jobID).
 		Update("status", status).Error
 }
+
+func (r *GormJobRepo) SaveDiagnostics(ctx context.Context, jobID string, diag entity.JobDiagnostics) error {
+	return r.db.WithContext(ctx).Model(&entity.Job{}).
+		Where("job_id = ?", jobID).
+		Update("diagnostics", diag).Error
+}
+
+// SaveJobResult дописывает заполненные поля result к сохранённому
+// результату: chunker и воркер пишут разные поля одного результата.
+func (r *GormJobRepo) SaveJobResult(ctx context.Context, jobID string, result entity.JobResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(&entity.Job{}).
+		Where("job_id = ?", jobID).
+		Update("result", gorm.Expr("COALESCE(result, '{}'::jsonb) || ?::jsonb", string(data))).Error
+}
+
+func (r *GormJobRepo) SaveSchemaReport(ctx context.Context, jobID string, report entity.SchemaReport) error {
+	return r.db.WithContext(ctx).Model(&entity.Job{}).
+		Where("job_id = ?", jobID).
+		Update("schema_report", report).Error
+}
@@ -0,0 +1,62 @@
+package psql
+
+import (
+	"chunker/internal/domain/entity"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type GormBaselineRepo struct {
+	db *gorm.DB
+}
+
+func NewGormBaselineRepo(db *gorm.DB) *GormBaselineRepo {
+	return &GormBaselineRepo{db: db}
+}
+
+func (r *GormBaselineRepo) GetBaseline(ctx context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error) {
+	var b entity.SensorBaseline
+	err := r.db.WithContext(ctx).Where("tenant_id = ? AND sensor_id = ?", tenantID, sensorID).Limit(1).Find(&b).Error
+	if err != nil || b.SensorID == "" {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// UpdateBaselines в одной транзакции блокирует строки датчиков, применяет
+// к каждой apply (отсутствующие создаются пустыми) и сохраняет строки,
+// для которых apply вернул true.
+func (r *GormBaselineRepo) UpdateBaselines(ctx context.Context, tenantID string, sensorIDs []string, apply func(b *entity.SensorBaseline) bool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []entity.SensorBaseline
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND sensor_id IN ?", tenantID, sensorIDs).
+			Order("sensor_id"). // одинаковый порядок блокировок у параллельных заданий
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		byID := map[string]*entity.SensorBaseline{}
+		for i := range existing {
+			byID[existing[i].SensorID] = &existing[i]
+		}
+
+		updated := make([]entity.SensorBaseline, 0, len(sensorIDs))
+		for _, id := range sensorIDs {
+			b := byID[id]
+			if b == nil {
+				b = &entity.SensorBaseline{TenantID: tenantID, SensorID: id, Metrics: entity.BaselineMetrics{}}
+			}
+			if !apply(b) {
+				continue
+			}
+			b.UpdatedAt = time.Now()
+			updated = append(updated, *b)
+		}
+		if len(updated) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&updated).Error
+	})
+}
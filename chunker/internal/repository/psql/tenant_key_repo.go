@@ -0,0 +1,32 @@
+package psql
+
+import (
+	"chunker/internal/domain/entity"
+	"chunker/pkg/utils"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// GormTenantKeyRepo читает ключи SSE-C тенантов, созданные gateway при
+// загрузке файлов. Ключи зашифрованы мастер-ключом хранилища ключей.
+type GormTenantKeyRepo struct {
+	db        *gorm.DB
+	masterKey []byte
+}
+
+func NewGormTenantKeyRepo(db *gorm.DB, masterKey []byte) *GormTenantKeyRepo {
+	return &GormTenantKeyRepo{db: db, masterKey: masterKey}
+}
+
+func (r *GormTenantKeyRepo) CustomerKey(ctx context.Context, tenantID string) ([]byte, error) {
+	var stored entity.TenantKey
+	if err := r.db.WithContext(ctx).First(&stored, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, fmt.Errorf("tenant key not found: %w", err)
+	}
+	key, err := utils.OpenAESGCM(r.masterKey, stored.Key, []byte(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("unseal tenant key: %w", err)
+	}
+	return key, nil
+}
@@ -1,11 +1,18 @@
 package redis
 
 import (
+	"chunker/internal/domain/entity"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"time"
 )
 
+// jobStatusTTL — сколько живёт запись статуса; после неё gateway читает
+// статус из Postgres и кэширует заново.
+const jobStatusTTL = 24 * time.Hour
+
 type RedisRepo struct {
 	client *redis.Client
 }
@@ -37,3 +44,12 @@ func (r *RedisRepo) GetJobProgress(ctx context.Context, jobID string) (completed
 	}
 	return completed, total, nil
 }
+
+// SetJobStatus обновляет запись статуса задания, которую читает gateway.
+func (r *RedisRepo) SetJobStatus(ctx context.Context, jobID string, rec entity.JobStatusRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, "job_status:"+jobID, data, jobStatusTTL).Err()
+}
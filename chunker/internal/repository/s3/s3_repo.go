@@ -6,35 +6,92 @@ import (
 	"context"
 	"fmt"
 	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"io"
 )
 
+// SSEMode — шифрование объектов на стороне S3, должно совпадать с gateway.
+type SSEMode string
+
+const (
+	SSENone SSEMode = ""
+	// SSES3 — ключами хранилища (SSE-S3), прозрачно для чтения.
+	SSES3 SSEMode = "sse-s3"
+	// SSEC — ключом тенанта (SSE-C), который передаётся в каждом запросе.
+	SSEC SSEMode = "sse-c"
+)
+
+func ParseSSEMode(s string) (SSEMode, error) {
+	switch m := SSEMode(s); m {
+	case SSENone, SSES3, SSEC:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown S3 SSE mode %q, want sse-s3 or sse-c", s)
+	}
+}
+
+// CustomerKeyStore выдаёт ключ SSE-C тенанта.
+type CustomerKeyStore interface {
+	CustomerKey(ctx context.Context, tenantID string) ([]byte, error)
+}
+
 type S3Repo struct {
 	StorageS3 *s3.StorageS3
+	SSE       SSEMode
+	Keys      CustomerKeyStore // нужен только для SSEC
 }
 
-func NewS3Repo(storageS3 *s3.StorageS3) *S3Repo {
+func NewS3Repo(storageS3 *s3.StorageS3, sse SSEMode, keys CustomerKeyStore) *S3Repo {
 	return &S3Repo{
 		StorageS3: storageS3,
+		SSE:       sse,
+		Keys:      keys,
+	}
+}
+
+// serverSide возвращает параметры SSE для объектов тенанта, nil = без SSE.
+func (s *S3Repo) serverSide(ctx context.Context, tenantID string) (encrypt.ServerSide, error) {
+	switch s.SSE {
+	case SSES3:
+		return encrypt.NewSSE(), nil
+	case SSEC:
+		if s.Keys == nil {
+			return nil, fmt.Errorf("sse-c requires a customer key store")
+		}
+		key, err := s.Keys.CustomerKey(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("customer key: %w", err)
+		}
+		return encrypt.NewSSEC(key)
+	default:
+		return nil, nil
 	}
 }
 
-func (s *S3Repo) UploadChunk(ctx context.Context, key string, file []byte) error {
+func (s *S3Repo) UploadChunk(ctx context.Context, tenantID, key string, file []byte) error {
+	return s.UploadStream(ctx, tenantID, key, bytes.NewReader(file), int64(len(file)))
+}
+
+// UploadStream выгружает объект известного размера из потока, не читая
+// его целиком в память.
+func (s *S3Repo) UploadStream(ctx context.Context, tenantID, key string, r io.Reader, size int64) error {
 	if s.StorageS3 == nil || s.StorageS3.Client == nil {
 		return fmt.Errorf("s3 client not initialized")
 	}
+	sse, err := s.serverSide(ctx, tenantID)
+	if err != nil {
+		return err
+	}
 
-	reader := bytes.NewReader(file)
-	fileSize := int64(len(file))
-
-	_, err := s.StorageS3.Client.PutObject(
+	_, err = s.StorageS3.Client.PutObject(
 		ctx,
 		s.StorageS3.Bucket,
 		key,
-		reader,
-		fileSize,
+		r,
+		size,
 		minio.PutObjectOptions{
-			ContentType: "application/octet-stream",
+			ContentType:          "application/octet-stream",
+			ServerSideEncryption: sse,
 		},
 	)
 	if err != nil {
@@ -44,8 +101,19 @@ func (s *S3Repo) UploadChunk(ctx context.Context, key string, file []byte) error
 	return nil
 }
 
-func (s *S3Repo) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
-	obj, err := s.StorageS3.Client.GetObject(ctx, s.StorageS3.Bucket, key, minio.GetObjectOptions{})
+// GetFileReader читает объект тенанта. SSE-S3 расшифровывается хранилищем
+// само, для SSE-C в запрос добавляется ключ тенанта.
+func (s *S3Repo) GetFileReader(ctx context.Context, tenantID, key string) (io.ReadCloser, error) {
+	var opts minio.GetObjectOptions
+	if s.SSE == SSEC {
+		sse, err := s.serverSide(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		opts.ServerSideEncryption = sse
+	}
+
+	obj, err := s.StorageS3.Client.GetObject(ctx, s.StorageS3.Bucket, key, opts)
 	if err != nil {
 		return nil, fmt.Errorf("s3 get object: %w", err)
 	}
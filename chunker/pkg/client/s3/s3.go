@@ -1,9 +1,11 @@
 package s3
 
 import (
+	"crypto/x509"
 	"fmt"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"os"
 )
 
 type StorageS3 struct {
@@ -12,11 +14,36 @@ type StorageS3 struct {
 	Client   *minio.Client
 }
 
-func NewS3Client(endpoint, accessKeyID, secretKey, bucket string) (*StorageS3, error) {
-	client, err := minio.New(endpoint, &minio.Options{
+// TLSConfig — подключение к S3 по TLS; CAFile — PEM с корневыми
+// сертификатами для самоподписанного endpoint, пусто = системные.
+type TLSConfig struct {
+	Enabled bool
+	CAFile  string
+}
+
+func NewS3Client(endpoint, accessKeyID, secretKey, bucket string, tlsCfg TLSConfig) (*StorageS3, error) {
+	opts := &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKeyID, secretKey, ""),
-		Secure: false,
-	})
+		Secure: tlsCfg.Enabled,
+	}
+	if tlsCfg.Enabled && tlsCfg.CAFile != "" {
+		transport, err := minio.DefaultTransport(true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create S3 transport: %w", err)
+		}
+		pem, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read S3 CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in S3 CA file %s", tlsCfg.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+		opts.Transport = transport
+	}
+
+	client, err := minio.New(endpoint, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
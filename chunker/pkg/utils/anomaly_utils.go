@@ -0,0 +1,394 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Sample — одно значение метрики датчика.
+type Sample struct {
+	SensorID string
+	Metric   string
+	Time     time.Time
+	Value    float64
+}
+
+// seriesKey — ряд значений одной метрики одного датчика: состояние
+// детекторов ведётся по рядам.
+func (s Sample) seriesKey() string { return s.SensorID + "\x00" + s.Metric }
+
+// Finding — аномалия, найденная детектором.
+type Finding struct {
+	Detector string
+	Severity Severity
+	Reason   string
+}
+
+// Detector проверяет значения по одному в порядке времени и учитывает
+// их в своём состоянии.
+type Detector interface {
+	Name() string
+	Check(s Sample) (Finding, bool)
+}
+
+// DetectorConfig — параметры детектора. Каждый детектор читает только
+// свои поля, нулевые значения заменяются умолчаниями.
+type DetectorConfig struct {
+	Name string
+	// Metrics — проверяемые метрики, пусто = все.
+	Metrics []string
+	// Min, Max — границы для threshold.
+	Min, Max *float64
+	// Threshold — |z| для zscore, множитель IQR для iqr, предел скорости
+	// изменения в единицах метрики в секунду для rate.
+	Threshold float64
+	// Window — окно iqr или число одинаковых значений подряд для stuck.
+	Window int
+	// MinSamples — сколько значений ряда накопить до первой проверки.
+	MinSamples int
+	// Baselines — история датчиков для baseline; заполняет анализатор.
+	Baselines BaselineLookup
+}
+
+type DetectorFactory func(cfg DetectorConfig) (Detector, error)
+
+// DetectorRegistry — детекторы по именам. Регистрация новых детекторов
+// не требует правок в конвейере.
+type DetectorRegistry struct {
+	factories map[string]DetectorFactory
+	names     []string
+}
+
+func NewDetectorRegistry() *DetectorRegistry {
+	return &DetectorRegistry{factories: map[string]DetectorFactory{}}
+}
+
+func (r *DetectorRegistry) Register(name string, f DetectorFactory) error {
+	name = strings.ToLower(name)
+	if name == "" {
+		return fmt.Errorf("detector has empty name")
+	}
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("detector %s is already registered", name)
+	}
+	r.factories[name] = f
+	r.names = append(r.names, name)
+	return nil
+}
+
+// Build создаёт детектор по конфигурации; Metrics ограничивают его
+// заданными метриками.
+func (r *DetectorRegistry) Build(cfg DetectorConfig) (Detector, error) {
+	f, ok := r.factories[strings.ToLower(cfg.Name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown detector %s, supported: %s", cfg.Name, strings.Join(r.Names(), ", "))
+	}
+	d, err := f(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("detector %s: %w", cfg.Name, err)
+	}
+	if len(cfg.Metrics) == 0 {
+		return d, nil
+	}
+	metrics := map[string]bool{}
+	for _, m := range cfg.Metrics {
+		metrics[m] = true
+	}
+	return &metricFilter{Detector: d, metrics: metrics}, nil
+}
+
+func (r *DetectorRegistry) Names() []string {
+	names := append([]string(nil), r.names...)
+	sort.Strings(names)
+	return names
+}
+
+type metricFilter struct {
+	Detector
+	metrics map[string]bool
+}
+
+func (f *metricFilter) Check(s Sample) (Finding, bool) {
+	if !f.metrics[s.Metric] {
+		return Finding{}, false
+	}
+	return f.Detector.Check(s)
+}
+
+// DefaultDetectorRegistry содержит встроенные детекторы.
+func DefaultDetectorRegistry() *DetectorRegistry {
+	r := NewDetectorRegistry()
+	for name, f := range map[string]DetectorFactory{
+		"threshold": NewThresholdDetector,
+		"zscore":    NewZScoreDetector,
+		"iqr":       NewIQRDetector,
+		"rate":      NewRateDetector,
+		"stuck":     NewStuckDetector,
+		"baseline":  NewBaselineDetector,
+	} {
+		if err := r.Register(name, f); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// DefaultDetectors не требуют настройки под метрики и включены, если
+// задание не задало свой набор. baseline молчит, пока у датчика нет
+// истории.
+var DefaultDetectors = []DetectorConfig{{Name: "zscore"}, {Name: "iqr"}, {Name: "stuck"}, {Name: "baseline"}}
+
+// thresholdDetector — статические границы значения.
+type thresholdDetector struct {
+	min, max *float64
+}
+
+func NewThresholdDetector(cfg DetectorConfig) (Detector, error) {
+	if cfg.Min == nil && cfg.Max == nil {
+		return nil, fmt.Errorf("set min, max or both")
+	}
+	if cfg.Min != nil && cfg.Max != nil && *cfg.Min > *cfg.Max {
+		return nil, fmt.Errorf("min %g is greater than max %g", *cfg.Min, *cfg.Max)
+	}
+	return &thresholdDetector{min: cfg.Min, max: cfg.Max}, nil
+}
+
+func (d *thresholdDetector) Name() string { return "threshold" }
+
+func (d *thresholdDetector) Check(s Sample) (Finding, bool) {
+	switch {
+	case d.min != nil && s.Value < *d.min:
+		return Finding{Detector: d.Name(), Severity: SeverityCritical, Reason: fmt.Sprintf("%s %g is below %g", s.Metric, s.Value, *d.min)}, true
+	case d.max != nil && s.Value > *d.max:
+		return Finding{Detector: d.Name(), Severity: SeverityCritical, Reason: fmt.Sprintf("%s %g is above %g", s.Metric, s.Value, *d.max)}, true
+	}
+	return Finding{}, false
+}
+
+// runningStats — среднее и дисперсия по алгоритму Уэлфорда.
+type runningStats struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+func (r *runningStats) add(v float64) {
+	r.n++
+	delta := v - r.mean
+	r.mean += delta / float64(r.n)
+	r.m2 += delta * (v - r.mean)
+}
+
+func (r *runningStats) std() float64 {
+	if r.n < 2 {
+		return 0
+	}
+	return math.Sqrt(r.m2 / float64(r.n-1))
+}
+
+// zscoreDetector сравнивает значение со средним ряда в стандартных
+// отклонениях. Значение проверяется до того, как попадёт в статистику.
+type zscoreDetector struct {
+	threshold  float64
+	minSamples int
+	series     map[string]*runningStats
+}
+
+func NewZScoreDetector(cfg DetectorConfig) (Detector, error) {
+	d := &zscoreDetector{threshold: cfg.Threshold, minSamples: cfg.MinSamples, series: map[string]*runningStats{}}
+	if d.threshold == 0 {
+		d.threshold = 3
+	}
+	if d.minSamples == 0 {
+		d.minSamples = 30
+	}
+	if d.threshold < 0 || d.minSamples < 2 {
+		return nil, fmt.Errorf("threshold must be positive and min_samples at least 2")
+	}
+	return d, nil
+}
+
+func (d *zscoreDetector) Name() string { return "zscore" }
+
+func (d *zscoreDetector) Check(s Sample) (Finding, bool) {
+	st := d.series[s.seriesKey()]
+	if st == nil {
+		st = &runningStats{}
+		d.series[s.seriesKey()] = st
+	}
+	defer st.add(s.Value)
+
+	std := st.std()
+	if st.n < d.minSamples || std == 0 {
+		return Finding{}, false
+	}
+	z := (s.Value - st.mean) / std
+	if math.Abs(z) < d.threshold {
+		return Finding{}, false
+	}
+	sev := SeverityWarning
+	if math.Abs(z) >= 2*d.threshold {
+		sev = SeverityCritical
+	}
+	return Finding{Detector: d.Name(), Severity: sev, Reason: fmt.Sprintf("%s %g is %.1f std from mean %.3g", s.Metric, s.Value, z, st.mean)}, true
+}
+
+// iqrDetector — заборы Тьюки по скользящему окну ряда: за Q1 − k·IQR
+// и Q3 + k·IQR значение подозрительно, за 2k — критично.
+type iqrDetector struct {
+	k          float64
+	window     int
+	minSamples int
+	series     map[string][]float64
+}
+
+func NewIQRDetector(cfg DetectorConfig) (Detector, error) {
+	d := &iqrDetector{k: cfg.Threshold, window: cfg.Window, minSamples: cfg.MinSamples, series: map[string][]float64{}}
+	if d.k == 0 {
+		d.k = 1.5
+	}
+	if d.window == 0 {
+		d.window = 100
+	}
+	if d.minSamples == 0 {
+		d.minSamples = min(20, d.window)
+	}
+	if d.k < 0 || d.minSamples < 4 || d.minSamples > d.window {
+		return nil, fmt.Errorf("threshold must be positive and min_samples in 4..window")
+	}
+	return d, nil
+}
+
+func (d *iqrDetector) Name() string { return "iqr" }
+
+func (d *iqrDetector) Check(s Sample) (Finding, bool) {
+	key := s.seriesKey()
+	values := d.series[key]
+	defer func() {
+		if len(values) == d.window {
+			values = values[1:]
+		}
+		d.series[key] = append(values, s.Value)
+	}()
+	if len(values) < d.minSamples {
+		return Finding{}, false
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	if iqr == 0 {
+		return Finding{}, false
+	}
+
+	var dist float64
+	switch {
+	case s.Value < q1-d.k*iqr:
+		dist = (q1 - s.Value) / iqr
+	case s.Value > q3+d.k*iqr:
+		dist = (s.Value - q3) / iqr
+	default:
+		return Finding{}, false
+	}
+	sev := SeverityWarning
+	if dist >= 2*d.k {
+		sev = SeverityCritical
+	}
+	return Finding{Detector: d.Name(), Severity: sev, Reason: fmt.Sprintf("%s %g is outside [%.3g, %.3g]", s.Metric, s.Value, q1-d.k*iqr, q3+d.k*iqr)}, true
+}
+
+// quantile — линейная интерполяция по отсортированным значениям.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// rateDetector — скачок: изменение быстрее Threshold единиц в секунду.
+type rateDetector struct {
+	maxRate float64
+	last    map[string]Sample
+}
+
+func NewRateDetector(cfg DetectorConfig) (Detector, error) {
+	if cfg.Threshold <= 0 {
+		return nil, fmt.Errorf("threshold (max change per second) must be positive")
+	}
+	return &rateDetector{maxRate: cfg.Threshold, last: map[string]Sample{}}, nil
+}
+
+func (d *rateDetector) Name() string { return "rate" }
+
+func (d *rateDetector) Check(s Sample) (Finding, bool) {
+	key := s.seriesKey()
+	prev, ok := d.last[key]
+	d.last[key] = s
+	if !ok {
+		return Finding{}, false
+	}
+	dt := s.Time.Sub(prev.Time).Seconds()
+	if dt <= 0 {
+		return Finding{}, false
+	}
+	rate := math.Abs(s.Value-prev.Value) / dt
+	if rate <= d.maxRate {
+		return Finding{}, false
+	}
+	sev := SeverityWarning
+	if rate > 2*d.maxRate {
+		sev = SeverityCritical
+	}
+	return Finding{Detector: d.Name(), Severity: sev, Reason: fmt.Sprintf("%s changed from %g to %g in %s (%.3g/s, limit %g/s)", s.Metric, prev.Value, s.Value, s.Time.Sub(prev.Time), rate, d.maxRate)}, true
+}
+
+// stuckDetector — залипший датчик: одно и то же значение Window раз
+// подряд. Об одном залипании сообщается один раз.
+type stuckDetector struct {
+	n      int
+	series map[string]*stuckRun
+}
+
+type stuckRun struct {
+	value float64
+	count int
+}
+
+func NewStuckDetector(cfg DetectorConfig) (Detector, error) {
+	d := &stuckDetector{n: cfg.Window, series: map[string]*stuckRun{}}
+	if d.n == 0 {
+		d.n = 10
+	}
+	if d.n < 2 {
+		return nil, fmt.Errorf("window must be at least 2")
+	}
+	return d, nil
+}
+
+func (d *stuckDetector) Name() string { return "stuck" }
+
+func (d *stuckDetector) Check(s Sample) (Finding, bool) {
+	run := d.series[s.seriesKey()]
+	if run == nil || run.value != s.Value {
+		d.series[s.seriesKey()] = &stuckRun{value: s.Value, count: 1}
+		return Finding{}, false
+	}
+	run.count++
+	if run.count != d.n {
+		return Finding{}, false
+	}
+	return Finding{Detector: d.Name(), Severity: SeverityWarning, Reason: fmt.Sprintf("%s is stuck at %g for %d readings", s.Metric, s.Value, d.n)}, true
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// runDetector подаёт значения одного ряда с шагом в минуту и возвращает
+// индексы значений, на которых сработал детектор.
+func runDetector(t *testing.T, cfg DetectorConfig, values []float64) ([]int, []Finding) {
+	t.Helper()
+	d, err := DefaultDetectorRegistry().Build(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var idx []int
+	var found []Finding
+	for i, v := range values {
+		if f, ok := d.Check(Sample{SensorID: "s1", Metric: "temperature", Time: start.Add(time.Duration(i) * time.Minute), Value: v}); ok {
+			idx = append(idx, i)
+			found = append(found, f)
+		}
+	}
+	return idx, found
+}
+
+// noisy — ряд около 20 с небольшим разбросом.
+func noisy(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = 20 + float64(i%5)*0.1
+	}
+	return out
+}
+
+func TestThresholdDetector(t *testing.T) {
+	lo, hi := -40.0, 85.0
+	idx, found := runDetector(t, DetectorConfig{Name: "threshold", Min: &lo, Max: &hi}, []float64{20, -41, 90, 85})
+	if len(idx) != 2 || idx[0] != 1 || idx[1] != 2 {
+		t.Fatalf("flagged %v", idx)
+	}
+	if found[0].Detector != "threshold" || found[0].Severity != SeverityCritical || found[0].Reason == "" {
+		t.Fatalf("finding = %+v", found[0])
+	}
+}
+
+func TestZScoreDetector(t *testing.T) {
+	values := append(noisy(40), 35, 20.2)
+	idx, found := runDetector(t, DetectorConfig{Name: "zscore"}, values)
+	if len(idx) != 1 || idx[0] != 40 || found[0].Severity != SeverityCritical {
+		t.Fatalf("flagged %v %+v", idx, found)
+	}
+}
+
+func TestIQRDetector(t *testing.T) {
+	values := append(noisy(30), 20.7, 25)
+	idx, found := runDetector(t, DetectorConfig{Name: "iqr", Window: 30}, values)
+	if len(idx) != 2 || found[0].Severity != SeverityWarning || found[1].Severity != SeverityCritical {
+		t.Fatalf("flagged %v %+v", idx, found)
+	}
+}
+
+func TestRateDetector(t *testing.T) {
+	// предел 0.1 градуса в секунду, шаг — минута
+	idx, found := runDetector(t, DetectorConfig{Name: "rate", Threshold: 0.1}, []float64{20, 21, 30, 45})
+	if len(idx) != 2 || idx[0] != 2 || found[0].Severity != SeverityWarning || found[1].Severity != SeverityCritical {
+		t.Fatalf("flagged %v %+v", idx, found)
+	}
+}
+
+func TestStuckDetector(t *testing.T) {
+	values := []float64{1, 2, 2, 2, 2, 2, 3, 3, 3}
+	idx, _ := runDetector(t, DetectorConfig{Name: "stuck", Window: 3}, values)
+	if len(idx) != 2 || idx[0] != 3 || idx[1] != 8 {
+		t.Fatalf("flagged %v", idx)
+	}
+}
+
+func TestDetectorMetricsFilter(t *testing.T) {
+	hi := 10.0
+	d, err := DefaultDetectorRegistry().Build(DetectorConfig{Name: "threshold", Max: &hi, Metrics: []string{"humidity"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Check(Sample{Metric: "temperature", Value: 50}); ok {
+		t.Fatal("temperature must be ignored")
+	}
+	if _, ok := d.Check(Sample{Metric: "humidity", Value: 50}); !ok {
+		t.Fatal("humidity must be checked")
+	}
+}
+
+func TestDetectorRegistryRejectsInvalidConfig(t *testing.T) {
+	r := DefaultDetectorRegistry()
+	for _, cfg := range []DetectorConfig{
+		{Name: "unknown"},
+		{Name: "threshold"},
+		{Name: "rate"},
+		{Name: "stuck", Window: 1},
+		{Name: "iqr", Window: 10, MinSamples: 20},
+	} {
+		if _, err := r.Build(cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+	if err := r.Register("zscore", NewZScoreDetector); err == nil {
+		t.Fatal("expected error for duplicate detector")
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveNone ArchiveFormat = ""
+	ArchiveZip  ArchiveFormat = "zip"
+	ArchiveTar  ArchiveFormat = "tar"
+)
+
+// tarMagicOffset — смещение поля magic ("ustar") в заголовке tar.
+const tarMagicOffset = 257
+
+var zipMagic = []byte("PK\x03\x04")
+
+// MaxSpoolSize ограничивает файл, который сбрасывается во временный файл
+// (zip, parquet, xlsx), MaxArchiveEntrySize — распакованную запись
+// архива: сплиттеры держат запись в памяти целиком.
+var (
+	MaxSpoolSize        int64 = 4 << 30
+	MaxArchiveEntrySize int64 = 1 << 30
+)
+
+// ErrSpoolTooLarge — файл не уместился в MaxSpoolSize.
+var ErrSpoolTooLarge = errors.New("file exceeds spool size limit")
+
+// ErrArchiveEntryTooLarge — запись архива больше MaxArchiveEntrySize;
+// такая запись пропускается, а не валит всё задание.
+var ErrArchiveEntryTooLarge = errors.New("archive entry exceeds size limit")
+
+// DetectArchive определяет архив по расширению (после снятия сжатия)
+// или по сигнатуре. br должен быть тем же reader, из которого потом
+// будет читаться архив: используется только Peek.
+func DetectArchive(fileKey string, br *bufio.Reader) ArchiveFormat {
+	if format := ArchiveFromExt(fileKey); format != ArchiveNone {
+		return format
+	}
+
+	header, _ := br.Peek(tarMagicOffset + 5)
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return ArchiveZip
+	case len(header) == tarMagicOffset+5 && string(header[tarMagicOffset:]) == "ustar":
+		return ArchiveTar
+	}
+	return ArchiveNone
+}
+
+// ArchiveFromExt определяет архив только по расширению.
+func ArchiveFromExt(fileKey string) ArchiveFormat {
+	switch strings.ToLower(filepath.Ext(fileKey)) {
+	case ".zip":
+		return ArchiveZip
+	case ".tar":
+		return ArchiveTar
+	}
+	return ArchiveNone
+}
+
+// ArchiveEntryFunc вызывается для каждого обычного файла архива.
+// Reader действителен только до возврата из функции.
+type ArchiveEntryFunc func(name string, r io.Reader) error
+
+// SkippedEntryFunc вызывается для записей, которые не являются файлами
+// с данными (ссылки, служебные файлы macOS и т.п.) или не открываются.
+type SkippedEntryFunc func(name, reason string)
+
+func WalkArchive(format ArchiveFormat, r io.Reader, onEntry ArchiveEntryFunc, onSkip SkippedEntryFunc) error {
+	switch format {
+	case ArchiveZip:
+		return walkZip(r, onEntry, onSkip)
+	case ArchiveTar:
+		return walkTar(r, onEntry, onSkip)
+	default:
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+// walkZip сбрасывает архив во временный файл: zip читается с конца
+// (central directory), поэтому потоково его не разобрать.
+func walkZip(r io.Reader, onEntry ArchiveEntryFunc, onSkip SkippedEntryFunc) error {
+	tmp, size, err := spoolToTempFile(r, "chunker-*.zip")
+	if err != nil {
+		return fmt.Errorf("spool zip archive: %w", err)
+	}
+	defer removeTempFile(tmp)
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return fmt.Errorf("open zip archive: %w", err)
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			onSkip(f.Name, "not a regular file")
+			continue
+		}
+		if reason := archiveMetadataEntry(f.Name); reason != "" {
+			onSkip(f.Name, reason)
+			continue
+		}
+
+		if f.UncompressedSize64 > uint64(MaxArchiveEntrySize) {
+			onSkip(f.Name, ErrArchiveEntryTooLarge.Error())
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			onSkip(f.Name, fmt.Sprintf("open entry: %v", err))
+			continue
+		}
+		err = onEntry(f.Name, limitEntry(rc))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkTar(r io.Reader, onEntry ArchiveEntryFunc, onSkip SkippedEntryFunc) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar archive: %w", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeDir:
+			continue
+		default:
+			onSkip(hdr.Name, "not a regular file")
+			continue
+		}
+		if reason := archiveMetadataEntry(hdr.Name); reason != "" {
+			onSkip(hdr.Name, reason)
+			continue
+		}
+
+		if hdr.Size > MaxArchiveEntrySize {
+			onSkip(hdr.Name, ErrArchiveEntryTooLarge.Error())
+			continue
+		}
+		if err := onEntry(hdr.Name, limitEntry(tr)); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveMetadataEntry отсеивает служебные файлы, которые добавляют
+// архиваторы macOS: __MACOSX/... и AppleDouble-файлы ._name.
+func archiveMetadataEntry(name string) string {
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") {
+		return "macOS metadata entry"
+	}
+	return ""
+}
+
+// limitReader отдаёт не больше left байт; если вход длиннее, чтение
+// заканчивается ошибкой err. Размер в заголовке записи архива может не
+// соответствовать содержимому, поэтому предел проверяется при чтении.
+type limitReader struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func limitEntry(r io.Reader) io.Reader {
+	return &limitReader{r: r, left: MaxArchiveEntrySize, err: ErrArchiveEntryTooLarge}
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// вход ровно в предел допустим, если дальше пусто
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			return 0, l.err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// spoolToTempFile копирует поток во временный файл для форматов,
+// которым нужен произвольный доступ (zip, parquet), не больше
+// MaxSpoolSize.
+func spoolToTempFile(r io.Reader, pattern string) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create temp file: %w", err)
+	}
+
+	size, err := io.Copy(tmp, io.LimitReader(r, MaxSpoolSize+1))
+	if err == nil && size > MaxSpoolSize {
+		err = ErrSpoolTooLarge
+	}
+	if err != nil {
+		removeTempFile(tmp)
+		return nil, 0, err
+	}
+
+	return tmp, size, nil
+}
+
+func removeTempFile(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
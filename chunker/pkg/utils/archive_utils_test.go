@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var archiveFiles = []struct {
+	name, body string
+}{
+	{"device-1/readings.csv", "sensor_id,temperature\ns1,20.5\n"},
+	{"device-2/readings.ndjson", "{\"sensor_id\":\"s2\"}\n"},
+	{"__MACOSX/device-1/._readings.csv", "junk"},
+	{"notes.txt", "hello"},
+}
+
+func buildZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("device-1/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archiveFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "device-1/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, f := range archiveFiles {
+		tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f.body))})
+		tw.Write([]byte(f.body))
+	}
+	tw.WriteHeader(&tar.Header{Name: "latest.csv", Typeflag: tar.TypeSymlink, Linkname: "device-1/readings.csv"})
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func walkAll(t *testing.T, format ArchiveFormat, data []byte) (map[string]string, []string) {
+	t.Helper()
+	entries := map[string]string{}
+	var skipped []string
+
+	err := WalkArchive(format, bytes.NewReader(data), func(name string, r io.Reader) error {
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		entries[name] = string(body)
+		return nil
+	}, func(name, reason string) {
+		skipped = append(skipped, name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries, skipped
+}
+
+func TestWalkArchive(t *testing.T) {
+	want := map[string]string{
+		"device-1/readings.csv":    archiveFiles[0].body,
+		"device-2/readings.ndjson": archiveFiles[1].body,
+		"notes.txt":                archiveFiles[3].body,
+	}
+
+	entries, skipped := walkAll(t, ArchiveZip, buildZip(t))
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("zip entries = %v, want %v", entries, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"__MACOSX/device-1/._readings.csv"}) {
+		t.Errorf("zip skipped = %v", skipped)
+	}
+
+	entries, skipped = walkAll(t, ArchiveTar, buildTar(t))
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("tar entries = %v, want %v", entries, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"__MACOSX/device-1/._readings.csv", "latest.csv"}) {
+		t.Errorf("tar skipped = %v", skipped)
+	}
+}
+
+func TestDetectArchive(t *testing.T) {
+	cases := []struct {
+		name    string
+		fileKey string
+		data    []byte
+		want    ArchiveFormat
+	}{
+		{"zip by extension", "bundle.ZIP", nil, ArchiveZip},
+		{"tar by extension", "bundle.tar", nil, ArchiveTar},
+		{"zip by magic", "bundle.bin", buildZip(t), ArchiveZip},
+		{"tar by magic", "bundle.bin", buildTar(t), ArchiveTar},
+		{"plain csv", "readings.csv", []byte(sampleCSV), ArchiveNone},
+	}
+
+	for _, tc := range cases {
+		br := bufio.NewReader(bytes.NewReader(tc.data))
+		if got := DetectArchive(tc.fileKey, br); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWalkTarGz(t *testing.T) {
+	gz, err := Compress(buildTar(t), CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, inner, _, err := Decompress(bytes.NewReader(gz), "bundle.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	br := bufio.NewReader(rc)
+	format := DetectArchive(inner, br)
+	if format != ArchiveTar {
+		t.Fatalf("got %q, want tar", format)
+	}
+
+	count := 0
+	err = WalkArchive(format, br, func(string, io.Reader) error { count++; return nil }, func(string, string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("got %d entries, want 3", count)
+	}
+}
+
+func TestWalkArchiveLimits(t *testing.T) {
+	defer func(spool, entry int64) { MaxSpoolSize, MaxArchiveEntrySize = spool, entry }(MaxSpoolSize, MaxArchiveEntrySize)
+
+	// записи длиннее 20 байт пропускаются, остальные читаются
+	MaxArchiveEntrySize = 20
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar} {
+		data := buildZip(t)
+		if format == ArchiveTar {
+			data = buildTar(t)
+		}
+		entries, skipped := walkAll(t, format, data)
+		if _, ok := entries["device-1/readings.csv"]; ok || entries["notes.txt"] != "hello" {
+			t.Errorf("%s entries = %v", format, entries)
+		}
+		if !slices.Contains(skipped, "device-1/readings.csv") {
+			t.Errorf("%s skipped = %v", format, skipped)
+		}
+	}
+
+	MaxSpoolSize = 16
+	err := WalkArchive(ArchiveZip, bytes.NewReader(buildZip(t)), func(string, io.Reader) error { return nil }, func(string, string) {})
+	if !errors.Is(err, ErrSpoolTooLarge) {
+		t.Errorf("spool limit: %v", err)
+	}
+}
+
+func TestArchiveEntryReaderDetectsUnderstatedSize(t *testing.T) {
+	defer func(entry int64) { MaxArchiveEntrySize = entry }(MaxArchiveEntrySize)
+	MaxArchiveEntrySize = 4
+
+	if _, err := io.ReadAll(limitEntry(strings.NewReader("1234"))); err != nil {
+		t.Errorf("exact size: %v", err)
+	}
+	if _, err := io.ReadAll(limitEntry(strings.NewReader("12345"))); !errors.Is(err, ErrArchiveEntryTooLarge) {
+		t.Errorf("oversized: %v", err)
+	}
+}
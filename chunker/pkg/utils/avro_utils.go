@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// SplitAvroToChunks читает Avro Object Container File. Схема берётся
+// из заголовка файла, каждая запись проходит через convert.
+func SplitAvroToChunks(r io.Reader, policy ChunkPolicy, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	dec, err := ocf.NewDecoder(r)
+	if err != nil {
+		return nil, DecodeStats{}, fmt.Errorf("open avro container: %w", err)
+	}
+
+	next := func() (map[string]any, bool, error) {
+		if !dec.HasNext() {
+			return nil, false, dec.Error()
+		}
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			return nil, false, err
+		}
+		return rec, true, nil
+	}
+
+	return splitRecordsToChunks(next, policy, convert)
+}
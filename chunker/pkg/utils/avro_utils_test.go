@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2/ocf"
+)
+
+const readingAvroSchema = `{"type":"record","name":"Reading","fields":[
+	{"name":"ts","type":{"type":"long","logicalType":"timestamp-millis"}},
+	{"name":"sensor_id","type":"string"},
+	{"name":"temperature","type":["null","double"]}
+]}`
+
+type avroReading struct {
+	TS          time.Time `avro:"ts"`
+	SensorID    string    `avro:"sensor_id"`
+	Temperature *float64  `avro:"temperature"`
+}
+
+// requireSensorID — конвертер для тестов: пропускает записи без sensor_id.
+func requireSensorID(rec map[string]any) (any, error) {
+	if id, _ := rec["sensor_id"].(string); id == "" {
+		return nil, errors.New("missing sensor_id")
+	}
+	return map[string]any{"sensor_id": rec["sensor_id"], "temperature": rec["temperature"]}, nil
+}
+
+func TestSplitAvroToChunks(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := ocf.NewEncoder(readingAvroSchema, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := 21.5
+	for _, r := range []avroReading{
+		{time.Now(), "s1", &temp},
+		{time.Now(), "", nil},
+		{time.Now(), "s2", nil},
+		{time.Now(), "s3", &temp},
+	} {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, stats, err := SplitAvroToChunks(&buf, ChunkPolicy{MaxRecords: 2}, requireSensorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Records != 3 || stats.Malformed != 1 || len(stats.Samples) != 1 || stats.Samples[0].Line != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+
+	var first []map[string]any
+	if err := json.Unmarshal(chunks[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first[0]["sensor_id"] != "s1" || first[0]["temperature"] != 21.5 || first[1]["sensor_id"] != "s2" || first[1]["temperature"] != nil {
+		t.Fatalf("unexpected chunk: %s", chunks[0])
+	}
+}
+
+func TestSplitAvroToChunksRejectsNonContainer(t *testing.T) {
+	if _, _, err := SplitAvroToChunks(bytes.NewReader([]byte(sampleCSV)), ChunkPolicy{MaxRecords: 2}, requireSensorID); err == nil {
+		t.Fatal("expected error for non-avro input")
+	}
+}
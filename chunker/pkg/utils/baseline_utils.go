@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultBaselineWeight — сколько значений метрики помнит базовая линия.
+// При слиянии с новым заданием старые значения затухают так, чтобы общий
+// вес не превышал предел: история скользит вместе с датчиком.
+const DefaultBaselineWeight = 10000
+
+// Moments — вес, среднее и сумма квадратов отклонений выборки. Вес
+// дробный, потому что старые наблюдения затухают.
+type Moments struct {
+	N    float64 `json:"n"`
+	Mean float64 `json:"mean"`
+	M2   float64 `json:"m2"`
+}
+
+// Add учитывает значение по алгоритму Уэлфорда.
+func (m *Moments) Add(v float64) {
+	m.N++
+	delta := v - m.Mean
+	m.Mean += delta / m.N
+	m.M2 += delta * (v - m.Mean)
+}
+
+// Merge объединяет две выборки (формула Чана).
+func (m *Moments) Merge(o Moments) {
+	if o.N == 0 {
+		return
+	}
+	if m.N == 0 {
+		*m = o
+		return
+	}
+	n := m.N + o.N
+	delta := o.Mean - m.Mean
+	m.Mean += delta * o.N / n
+	m.M2 += o.M2 + delta*delta*m.N*o.N/n
+	m.N = n
+}
+
+// scale меняет вес выборки, не трогая среднее и дисперсию.
+func (m *Moments) scale(f float64) {
+	m.N *= f
+	m.M2 *= f
+}
+
+func (m Moments) Variance() float64 {
+	if m.N < 2 {
+		return 0
+	}
+	return m.M2 / (m.N - 1)
+}
+
+func (m Moments) Std() float64 {
+	return math.Sqrt(math.Max(m.Variance(), 0))
+}
+
+// MetricProfile — базовая линия метрики датчика: общие моменты и
+// сезонные профили по часу суток и дню недели (UTC).
+type MetricProfile struct {
+	Overall Moments     `json:"overall"`
+	Hourly  [24]Moments `json:"hourly"`
+	Weekday [7]Moments  `json:"weekday"`
+}
+
+func (p *MetricProfile) Add(t time.Time, v float64) {
+	t = t.UTC()
+	p.Overall.Add(v)
+	p.Hourly[t.Hour()].Add(v)
+	p.Weekday[t.Weekday()].Add(v)
+}
+
+// Merge добавляет к базовой линии наблюдения задания obs. Если общий
+// вес превысит maxWeight, прежняя история затухает; новые наблюдения
+// учитываются полностью.
+func (p *MetricProfile) Merge(obs *MetricProfile, maxWeight float64) {
+	if p.Overall.N > 0 && p.Overall.N+obs.Overall.N > maxWeight {
+		f := math.Max(maxWeight-obs.Overall.N, 0) / p.Overall.N
+		p.Overall.scale(f)
+		for i := range p.Hourly {
+			p.Hourly[i].scale(f)
+		}
+		for i := range p.Weekday {
+			p.Weekday[i].scale(f)
+		}
+	}
+	p.Overall.Merge(obs.Overall)
+	for i := range p.Hourly {
+		p.Hourly[i].Merge(obs.Hourly[i])
+	}
+	for i := range p.Weekday {
+		p.Weekday[i].Merge(obs.Weekday[i])
+	}
+}
+
+// Expected возвращает ожидаемые среднее и отклонение метрики в момент t:
+// по профилю часа суток, если в нём не меньше minSamples значений, иначе
+// по всей истории. ok == false — истории мало.
+func (p *MetricProfile) Expected(t time.Time, minSamples int) (mean, std float64, source string, ok bool) {
+	hour := t.UTC().Hour()
+	if m := p.Hourly[hour]; m.N >= float64(minSamples) && m.Std() > 0 {
+		return m.Mean, m.Std(), fmt.Sprintf("hour %d", hour), true
+	}
+	if m := p.Overall; m.N >= float64(minSamples) && m.Std() > 0 {
+		return m.Mean, m.Std(), "overall", true
+	}
+	return 0, 0, "", false
+}
+
+// BaselineLookup возвращает базовую линию метрики датчика или nil, если
+// истории нет.
+type BaselineLookup func(sensorID, metric string) *MetricProfile
+
+// baselineDetector сравнивает значение с историей датчика по прошлым
+// заданиям. Без истории (или без Baselines в конфигурации) молчит.
+type baselineDetector struct {
+	threshold  float64
+	minSamples int
+	baselines  BaselineLookup
+}
+
+func NewBaselineDetector(cfg DetectorConfig) (Detector, error) {
+	d := &baselineDetector{threshold: cfg.Threshold, minSamples: cfg.MinSamples, baselines: cfg.Baselines}
+	if d.threshold == 0 {
+		d.threshold = 3
+	}
+	if d.minSamples == 0 {
+		d.minSamples = 30
+	}
+	if d.threshold < 0 || d.minSamples < 2 {
+		return nil, fmt.Errorf("threshold must be positive and min_samples at least 2")
+	}
+	return d, nil
+}
+
+func (d *baselineDetector) Name() string { return "baseline" }
+
+func (d *baselineDetector) Check(s Sample) (Finding, bool) {
+	if d.baselines == nil {
+		return Finding{}, false
+	}
+	p := d.baselines(s.SensorID, s.Metric)
+	if p == nil {
+		return Finding{}, false
+	}
+	mean, std, source, ok := p.Expected(s.Time, d.minSamples)
+	if !ok {
+		return Finding{}, false
+	}
+	z := (s.Value - mean) / std
+	if math.Abs(z) < d.threshold {
+		return Finding{}, false
+	}
+	sev := SeverityWarning
+	if math.Abs(z) >= 2*d.threshold {
+		sev = SeverityCritical
+	}
+	return Finding{Detector: d.Name(), Severity: sev, Reason: fmt.Sprintf("%s %g is %.1f std from baseline %.3g (%s)", s.Metric, s.Value, z, mean, source)}, true
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMomentsMergeMatchesSequential(t *testing.T) {
+	var all, a, b Moments
+	for i, v := range []float64{3, 7, 1, 9, 4, 4, 12, 5} {
+		all.Add(v)
+		if i < 3 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	a.Merge(b)
+	if a.N != all.N || math.Abs(a.Mean-all.Mean) > 1e-9 || math.Abs(a.Variance()-all.Variance()) > 1e-9 {
+		t.Fatalf("merged %+v, sequential %+v", a, all)
+	}
+}
+
+func TestMetricProfileRollingWeight(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var base, old, fresh MetricProfile
+	for i := 0; i < 100; i++ {
+		old.Add(start.Add(time.Duration(i)*time.Minute), 10)
+		fresh.Add(start.Add(time.Duration(i)*time.Minute), 20)
+	}
+	base.Merge(&old, 150)
+	base.Merge(&fresh, 150)
+	// старая история затухает вдвое: вес 50 из 150
+	if base.Overall.N != 150 || math.Abs(base.Overall.Mean-50.0/3) > 1e-9 {
+		t.Fatalf("overall = %+v", base.Overall)
+	}
+	if base.Hourly[0].N != 90 || base.Hourly[1].N != 60 {
+		t.Fatalf("hourly = %+v %+v", base.Hourly[0], base.Hourly[1])
+	}
+}
+
+func TestBaselineDetector(t *testing.T) {
+	var p MetricProfile
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 48*60; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		// днём теплее: 30 ± 0.5, ночью 10 ± 0.5
+		v := 10.0
+		if ts.Hour() >= 12 {
+			v = 30
+		}
+		p.Add(ts, v+float64(i%3-1)*0.5)
+	}
+	lookup := func(sensorID, metric string) *MetricProfile {
+		if sensorID == "s1" && metric == "temperature" {
+			return &p
+		}
+		return nil
+	}
+	d, err := DefaultDetectorRegistry().Build(DetectorConfig{Name: "baseline", Baselines: lookup})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 1, 10, 14, 0, 0, 0, time.UTC)
+	if _, ok := d.Check(Sample{SensorID: "s1", Metric: "temperature", Time: day, Value: 30.2}); ok {
+		t.Fatal("usual daytime value flagged")
+	}
+	// 30 ночью — в пределах всей истории, но не часа суток
+	f, ok := d.Check(Sample{SensorID: "s1", Metric: "temperature", Time: day.Add(-12 * time.Hour), Value: 30})
+	if !ok || f.Severity != SeverityCritical {
+		t.Fatalf("night value: %+v %v", f, ok)
+	}
+	if _, ok := d.Check(Sample{SensorID: "s2", Metric: "temperature", Time: day, Value: 100}); ok {
+		t.Fatal("sensor without baseline flagged")
+	}
+}
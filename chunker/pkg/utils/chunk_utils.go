@@ -4,13 +4,85 @@ import (
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"io"
 )
 
-func SplitJSONToChunks(r io.Reader, chunkSize int) ([][]byte, error) {
+// ChunkPolicy задаёт, когда закрывать чанк: по числу записей, по размеру
+// в байтах или по тому пределу, что наступит раньше. Ноль — предел не задан.
+// Запись, которая одна больше MaxBytes, попадает в отдельный чанк.
+type ChunkPolicy struct {
+	MaxRecords int `json:"max_records,omitempty"`
+	MaxBytes   int `json:"max_bytes,omitempty"`
+}
+
+func (p ChunkPolicy) Validate() error {
+	if p.MaxRecords < 0 || p.MaxBytes < 0 || p.MaxRecords == 0 && p.MaxBytes == 0 {
+		return fmt.Errorf("invalid chunk policy: %s", p)
+	}
+	return nil
+}
+
+func (p ChunkPolicy) String() string {
+	return fmt.Sprintf("max_records=%d max_bytes=%d", p.MaxRecords, p.MaxBytes)
+}
+
+// fits сообщает, поместится ли в чанк из count записей размером size ещё
+// n байт. В пустой чанк помещается любая запись.
+func (p ChunkPolicy) fits(count, size, n int) bool {
+	if count == 0 {
+		return true
+	}
+	if p.MaxRecords > 0 && count >= p.MaxRecords {
+		return false
+	}
+	return p.MaxBytes <= 0 || size+n <= p.MaxBytes
+}
+
+// arrayChunker собирает элементы в чанки-массивы JSON по политике.
+type arrayChunker struct {
+	policy ChunkPolicy
+	chunks [][]byte
+	buf    *bytes.Buffer
+	count  int
+}
+
+func (c *arrayChunker) add(item []byte) {
+	// запятая перед элементом и закрывающая скобка
+	if c.buf != nil && !c.policy.fits(c.count, c.buf.Len(), len(item)+2) {
+		c.flush()
+	}
+	if c.buf == nil {
+		c.buf = new(bytes.Buffer)
+		c.buf.WriteByte('[')
+	} else {
+		c.buf.WriteByte(',')
+	}
+	c.buf.Write(item)
+	c.count++
+}
+
+func (c *arrayChunker) flush() {
+	if c.buf == nil {
+		return
+	}
+	c.buf.WriteByte(']')
+	c.chunks = append(c.chunks, c.buf.Bytes())
+	c.buf, c.count = nil, 0
+}
+
+func (c *arrayChunker) result() [][]byte {
+	c.flush()
+	return c.chunks
+}
+
+func SplitJSONToChunks(r io.Reader, policy ChunkPolicy) ([][]byte, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
 	dec := json.NewDecoder(r)
-	var chunks [][]byte
-	var buffer []json.RawMessage
+	chunker := &arrayChunker{policy: policy}
 
 	t, err := dec.Token()
 	if err != nil {
@@ -25,56 +97,61 @@ func SplitJSONToChunks(r io.Reader, chunkSize int) ([][]byte, error) {
 		if err := dec.Decode(&obj); err != nil {
 			return nil, err
 		}
-		buffer = append(buffer, obj)
-
-		if len(buffer) >= chunkSize {
-			chunkBytes, _ := json.Marshal(buffer)
-			chunks = append(chunks, chunkBytes)
-			buffer = buffer[:0]
-		}
+		// json.Marshal экранирует HTML-символы внутри RawMessage,
+		// поэтому массив собирается вручную из исходных байт элементов.
+		chunker.add(obj)
 	}
 
-	if len(buffer) > 0 {
-		chunkBytes, _ := json.Marshal(buffer)
-		chunks = append(chunks, chunkBytes)
+	if _, err := dec.Token(); err != nil {
+		return nil, err
 	}
 
-	return chunks, nil
+	return chunker.result(), nil
 }
 
-func SplitCSVToChunks(r io.Reader, chunkSize int) ([][]byte, error) {
-	csvReader := csv.NewReader(r)
+// SplitCSVToChunks режет CSV на чанки по политике policy.
+// В чанк копируются исходные байты записей: повторная сериализация через
+// csv.Writer теряет часть значений (\r\n внутри кавычек, запись из одного
+// пустого поля), а каждый чанк получает собственный буфер.
+func SplitCSVToChunks(r io.Reader, policy ChunkPolicy) ([][]byte, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	csvReader := csv.NewReader(io.TeeReader(r, &raw))
+
 	var chunks [][]byte
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
+	var buf *bytes.Buffer
+	var consumed int64
 
 	count := 0
-
 	for {
-		record, err := csvReader.Read()
+		_, err := csvReader.Read()
 		if err == io.EOF {
-			if count > 0 {
-				writer.Flush()
-				chunks = append(chunks, buf.Bytes())
-			}
 			break
 		}
 		if err != nil {
 			return nil, err
 		}
 
-		if err := writer.Write(record); err != nil {
-			return nil, err
-		}
+		offset := csvReader.InputOffset()
+		record := raw.Next(int(offset - consumed))
+		consumed = offset
 
-		count++
-		if count >= chunkSize {
-			writer.Flush()
+		if buf != nil && !policy.fits(count, buf.Len(), len(record)) {
 			chunks = append(chunks, buf.Bytes())
-			buf.Reset()
-			writer = csv.NewWriter(&buf)
-			count = 0
+			buf, count = nil, 0
+		}
+		if buf == nil {
+			buf = new(bytes.Buffer)
 		}
+		buf.Write(record)
+		count++
+	}
+
+	if buf != nil {
+		chunks = append(chunks, buf.Bytes())
 	}
 
 	return chunks, nil
@@ -0,0 +1,382 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func joinCSVChunks(t *testing.T, chunks [][]byte) [][]string {
+	t.Helper()
+	var records [][]string
+	for i, c := range chunks {
+		got, err := csv.NewReader(bytes.NewReader(c)).ReadAll()
+		if err != nil {
+			t.Fatalf("chunk %d is not valid csv: %v", i, err)
+		}
+		records = append(records, got...)
+	}
+	return records
+}
+
+func joinJSONChunks(t *testing.T, chunks [][]byte) []json.RawMessage {
+	t.Helper()
+	var items []json.RawMessage
+	for i, c := range chunks {
+		var got []json.RawMessage
+		if err := json.Unmarshal(c, &got); err != nil {
+			t.Fatalf("chunk %d is not a json array: %v", i, err)
+		}
+		items = append(items, got...)
+	}
+	return items
+}
+
+func compactJSON(t *testing.T, items []json.RawMessage) []string {
+	t.Helper()
+	out := make([]string, len(items))
+	for i, item := range items {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, item); err != nil {
+			t.Fatalf("item %d: %v", i, err)
+		}
+		out[i] = buf.String()
+	}
+	return out
+}
+
+func checkCSVChunks(t *testing.T, input []byte, chunkSize int) {
+	t.Helper()
+	want, err := csv.NewReader(bytes.NewReader(input)).ReadAll()
+	if err != nil {
+		return
+	}
+
+	chunks, err := SplitCSVToChunks(bytes.NewReader(input), ChunkPolicy{MaxRecords: chunkSize})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	for i, c := range chunks {
+		n, err := csv.NewReader(bytes.NewReader(c)).ReadAll()
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if len(n) == 0 || len(n) > chunkSize {
+			t.Fatalf("chunk %d has %d records, chunk size %d", i, len(n), chunkSize)
+		}
+		if i < len(chunks)-1 && len(n) != chunkSize {
+			t.Fatalf("non-final chunk %d has %d records, want %d", i, len(n), chunkSize)
+		}
+	}
+
+	got := joinCSVChunks(t, chunks)
+	if len(want) == 0 && len(got) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("records mismatch for chunk size %d:\n got %q\nwant %q", chunkSize, got, want)
+	}
+}
+
+func checkJSONChunks(t *testing.T, input []byte, chunkSize int) {
+	t.Helper()
+	var wantItems []json.RawMessage
+	if err := json.Unmarshal(input, &wantItems); err != nil || wantItems == nil {
+		return
+	}
+
+	chunks, err := SplitJSONToChunks(bytes.NewReader(input), ChunkPolicy{MaxRecords: chunkSize})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	for i, c := range chunks {
+		var n []json.RawMessage
+		if err := json.Unmarshal(c, &n); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if len(n) == 0 || len(n) > chunkSize {
+			t.Fatalf("chunk %d has %d items, chunk size %d", i, len(n), chunkSize)
+		}
+	}
+
+	got := compactJSON(t, joinJSONChunks(t, chunks))
+	want := compactJSON(t, wantItems)
+	if len(want) == 0 && len(got) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("items mismatch for chunk size %d:\n got %q\nwant %q", chunkSize, got, want)
+	}
+}
+
+func TestSplitCSVToChunksDoesNotAliasBuffers(t *testing.T) {
+	input := "a,1\nb,2\nc,3\nd,4\ne,5\n"
+
+	chunks, err := SplitCSVToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a,1\nb,2\n", "c,3\nd,4\n", "e,5\n"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for i := range want {
+		if string(chunks[i]) != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestSplitCSVToChunksKeepsQuotedValues(t *testing.T) {
+	input := "\"\"\n\"a\r\r\nb\"\nx\n"
+
+	chunks, err := SplitCSVToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := joinCSVChunks(t, chunks)
+	want := [][]string{{""}, {"a\r\nb"}, {"x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitRejectsInvalidChunkSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		if _, err := SplitCSVToChunks(strings.NewReader("a\n"), ChunkPolicy{MaxRecords: size}); err == nil {
+			t.Errorf("csv: expected error for chunk size %d", size)
+		}
+		if _, err := SplitJSONToChunks(strings.NewReader("[1]"), ChunkPolicy{MaxRecords: size}); err == nil {
+			t.Errorf("json: expected error for chunk size %d", size)
+		}
+	}
+}
+
+func TestSplitCSVToChunksByBytes(t *testing.T) {
+	input := "a,1\nb,2\nc,3\nlong,12345678\ne,5\n"
+
+	chunks, err := SplitCSVToChunks(strings.NewReader(input), ChunkPolicy{MaxBytes: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// запись длиннее предела уходит в отдельный чанк
+	want := []string{"a,1\nb,2\n", "c,3\n", "long,12345678\n", "e,5\n"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %q, want %q", chunks, want)
+	}
+	for i := range want {
+		if string(chunks[i]) != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestSplitJSONToChunksRecordsAndBytes(t *testing.T) {
+	input := `[{"a":1},{"a":2},{"a":3},{"long":"xxxxxxxxxxxx"},{"a":5}]`
+
+	chunks, err := SplitJSONToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 2, MaxBytes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{`[{"a":1},{"a":2}]`, `[{"a":3}]`, `[{"long":"xxxxxxxxxxxx"}]`, `[{"a":5}]`}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %q, want %q", chunks, want)
+	}
+	for i := range want {
+		if string(chunks[i]) != want[i] {
+			t.Errorf("chunk %d = %s, want %s", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestChunkPolicyValidate(t *testing.T) {
+	for _, p := range []ChunkPolicy{{}, {MaxRecords: -1}, {MaxBytes: -1}, {MaxRecords: 1, MaxBytes: -1}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("expected error for %s", p)
+		}
+	}
+	for _, p := range []ChunkPolicy{{MaxRecords: 1}, {MaxBytes: 1}, {MaxRecords: 1, MaxBytes: 1}} {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+}
+
+func TestSplitJSONToChunksRejectsTruncatedArray(t *testing.T) {
+	if _, err := SplitJSONToChunks(strings.NewReader(`[{"a":1},{"b":2}`), ChunkPolicy{MaxRecords: 1}); err == nil {
+		t.Fatal("expected error for unterminated array")
+	}
+}
+
+// csvTable генерирует прямоугольную таблицу произвольных строк для testing/quick.
+type csvTable struct {
+	Records   [][]string
+	ChunkSize int
+}
+
+func (csvTable) Generate(r *rand.Rand, size int) reflect.Value {
+	alphabet := []rune("abc,\"\n\r \tй0")
+	cols := 1 + r.Intn(4)
+	rows := r.Intn(size + 1)
+
+	records := make([][]string, rows)
+	for i := range records {
+		records[i] = make([]string, cols)
+		for j := range records[i] {
+			n := r.Intn(6)
+			field := make([]rune, n)
+			for k := range field {
+				field[k] = alphabet[r.Intn(len(alphabet))]
+			}
+			records[i][j] = string(field)
+		}
+	}
+
+	return reflect.ValueOf(csvTable{Records: records, ChunkSize: 1 + r.Intn(size+1)})
+}
+
+func TestSplitCSVToChunksProperty(t *testing.T) {
+	prop := func(tbl csvTable) bool {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		for _, rec := range tbl.Records {
+			if err := w.Write(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.Flush()
+
+		want, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+		if err != nil {
+			t.Fatalf("generated csv is invalid: %v", err)
+		}
+
+		chunks, err := SplitCSVToChunks(bytes.NewReader(buf.Bytes()), ChunkPolicy{MaxRecords: tbl.ChunkSize})
+		if err != nil {
+			t.Fatalf("split: %v", err)
+		}
+		got := joinCSVChunks(t, chunks)
+
+		return len(got) == len(want) && (len(got) == 0 || reflect.DeepEqual(got, want))
+	}
+
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// jsonItems генерирует массив разнородных JSON-значений для testing/quick.
+type jsonItems struct {
+	Items     []any
+	ChunkSize int
+}
+
+func (jsonItems) Generate(r *rand.Rand, size int) reflect.Value {
+	var gen func(depth int) any
+	gen = func(depth int) any {
+		switch k := r.Intn(6); {
+		case k == 0:
+			return nil
+		case k == 1:
+			return r.Intn(2) == 0
+		case k == 2:
+			return r.NormFloat64() * 100
+		case k == 3 || depth > 2:
+			return string(rune('a'+r.Intn(26))) + "\"\\\n"
+		case k == 4:
+			arr := make([]any, r.Intn(3))
+			for i := range arr {
+				arr[i] = gen(depth + 1)
+			}
+			return arr
+		default:
+			obj := map[string]any{}
+			for i := r.Intn(3); i > 0; i-- {
+				obj[string(rune('a'+r.Intn(26)))] = gen(depth + 1)
+			}
+			return obj
+		}
+	}
+
+	items := make([]any, r.Intn(size+1))
+	for i := range items {
+		items[i] = gen(0)
+	}
+
+	return reflect.ValueOf(jsonItems{Items: items, ChunkSize: 1 + r.Intn(size+1)})
+}
+
+func TestSplitJSONToChunksProperty(t *testing.T) {
+	prop := func(in jsonItems) bool {
+		input, err := json.MarshalIndent(in.Items, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if in.Items == nil {
+			input = []byte("[]")
+		}
+
+		chunks, err := SplitJSONToChunks(bytes.NewReader(input), ChunkPolicy{MaxRecords: in.ChunkSize})
+		if err != nil {
+			t.Fatalf("split: %v", err)
+		}
+
+		got := joinJSONChunks(t, chunks)
+		if len(got) != len(in.Items) {
+			return false
+		}
+		for i := range got {
+			want, _ := json.Marshal(in.Items[i])
+			var a, b any
+			if json.Unmarshal(got[i], &a) != nil || json.Unmarshal(want, &b) != nil {
+				return false
+			}
+			if !reflect.DeepEqual(a, b) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzSplitCSVToChunks(f *testing.F) {
+	f.Add([]byte("sensor_id,temperature\ns1,20.5\ns2,21\n"), 1)
+	f.Add([]byte("a,\"b\nc\",d\n\"\"\"q\"\"\",,\n"), 2)
+	f.Add([]byte("\"\"\n\"\"\nx\n"), 3)
+	f.Add([]byte(""), 5)
+
+	f.Fuzz(func(t *testing.T, input []byte, chunkSize int) {
+		if chunkSize <= 0 || chunkSize > 1<<10 {
+			t.Skip()
+		}
+		checkCSVChunks(t, input, chunkSize)
+	})
+}
+
+func FuzzSplitJSONToChunks(f *testing.F) {
+	f.Add([]byte(`[{"sensor_id":"s1","temperature":20.5},{"sensor_id":"s2"}]`), 1)
+	f.Add([]byte(`[1, "two", null, [3], {"x": {"y": []}}]`), 2)
+	f.Add([]byte(`[]`), 3)
+
+	f.Fuzz(func(t *testing.T, input []byte, chunkSize int) {
+		if chunkSize <= 0 || chunkSize > 1<<10 {
+			t.Skip()
+		}
+		checkJSONChunks(t, input, chunkSize)
+	})
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+type Compression string
+
+const (
+	CompressionNone  Compression = ""
+	CompressionGzip  Compression = "gzip"
+	CompressionZstd  Compression = "zstd"
+	CompressionBzip2 Compression = "bzip2"
+	CompressionXz    Compression = "xz"
+)
+
+var compressionExts = map[string]Compression{
+	".gz":   CompressionGzip,
+	".gzip": CompressionGzip,
+	".zst":  CompressionZstd,
+	".zstd": CompressionZstd,
+	".bz2":  CompressionBzip2,
+	".xz":   CompressionXz,
+}
+
+var compressionMagic = []struct {
+	magic []byte
+	c     Compression
+}{
+	{[]byte{0x1f, 0x8b}, CompressionGzip},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, CompressionZstd},
+	{[]byte("BZh"), CompressionBzip2},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, CompressionXz},
+}
+
+// zstdEncoder общий на процесс: EncodeAll безопасен для конкурентных вызовов.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+// compressionPeekSize — сколько байт нужно для распознавания любой сигнатуры.
+const compressionPeekSize = 6
+
+// ParseCompression разбирает название алгоритма из конфигурации.
+func ParseCompression(name string) (Compression, error) {
+	switch c := Compression(strings.ToLower(strings.TrimSpace(name))); c {
+	case CompressionNone, CompressionGzip, CompressionZstd, CompressionBzip2, CompressionXz:
+		return c, nil
+	case "none":
+		return CompressionNone, nil
+	default:
+		return CompressionNone, fmt.Errorf("unknown compression: %s", name)
+	}
+}
+
+// CompressionFromExt возвращает алгоритм по последнему расширению файла
+// и имя файла без этого расширения: readings.csv.gz -> gzip, readings.csv.
+func CompressionFromExt(fileKey string) (Compression, string) {
+	ext := strings.ToLower(filepath.Ext(fileKey))
+	if c, ok := compressionExts[ext]; ok {
+		return c, strings.TrimSuffix(fileKey, filepath.Ext(fileKey))
+	}
+	if ext == ".tgz" {
+		return CompressionGzip, strings.TrimSuffix(fileKey, filepath.Ext(fileKey)) + ".tar"
+	}
+	return CompressionNone, fileKey
+}
+
+// DetectCompression определяет алгоритм по сигнатуре в начале данных.
+func DetectCompression(header []byte) Compression {
+	for _, m := range compressionMagic {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.c
+		}
+	}
+	return CompressionNone
+}
+
+// Decompress распознаёт сжатие по сигнатуре и расширению и возвращает
+// потоковый распаковывающий reader вместе с именем внутреннего файла.
+// Сигнатура считается главной: расширение только подсказывает имя.
+func Decompress(r io.Reader, fileKey string) (io.ReadCloser, string, Compression, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(compressionPeekSize)
+	if err != nil && err != io.EOF {
+		return nil, "", CompressionNone, err
+	}
+
+	byMagic := DetectCompression(header)
+	byExt, innerKey := CompressionFromExt(fileKey)
+
+	if byMagic == CompressionNone && byExt != CompressionNone {
+		return nil, "", CompressionNone, fmt.Errorf("file %s has %s extension but is not %s-compressed", fileKey, byExt, byExt)
+	}
+
+	rc, err := NewDecompressReader(br, byMagic)
+	if err != nil {
+		return nil, "", CompressionNone, err
+	}
+
+	return rc, innerKey, byMagic, nil
+}
+
+func NewDecompressReader(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip reader: %w", err)
+		}
+		return zr, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case CompressionXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("xz reader: %w", err)
+		}
+		return io.NopCloser(xr), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", c)
+	}
+}
+
+// Compress сжимает чанк для хранения. Поддерживаются gzip и zstd.
+func Compress(data []byte, c Compression) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		zw, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return zw.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("compression %s is not supported for chunks", c)
+	}
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+const sampleCSV = "sensor_id,temperature\ns1,20.5\n"
+
+// bzip2Sample — sampleCSV, сжатый утилитой bzip2 (в stdlib нет энкодера).
+var bzip2Sample = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x63, 0xa4,
+	0xfc, 0xda, 0x00, 0x00, 0x0c, 0xdb, 0x80, 0x00, 0x10, 0x00, 0x05, 0x72,
+	0x00, 0x00, 0x00, 0xa6, 0x23, 0xde, 0x00, 0x20, 0x00, 0x22, 0x21, 0xa1,
+	0x93, 0x4c, 0x86, 0x21, 0x4c, 0x26, 0x9a, 0x03, 0x4c, 0x44, 0x2c, 0xa1,
+	0x1b, 0x9e, 0x9c, 0x1a, 0xb1, 0x88, 0xe3, 0x00, 0xfa, 0x06, 0x5c, 0x25,
+	0xa7, 0x7f, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x63, 0xa4, 0xfc, 0xda,
+}
+
+func compressedSample(t *testing.T, c Compression) []byte {
+	t.Helper()
+	switch c {
+	case CompressionBzip2:
+		return bzip2Sample
+	case CompressionXz:
+		var buf bytes.Buffer
+		w, err := xz.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(sampleCSV)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	default:
+		data, err := Compress([]byte(sampleCSV), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	cases := []struct {
+		name      string
+		fileKey   string
+		c         Compression
+		wantInner string
+	}{
+		{"plain", "jobs/1/readings.csv", CompressionNone, "jobs/1/readings.csv"},
+		{"gzip", "jobs/1/readings.csv.gz", CompressionGzip, "jobs/1/readings.csv"},
+		{"zstd", "jobs/1/dump.json.zst", CompressionZstd, "jobs/1/dump.json"},
+		{"bzip2", "jobs/1/readings.CSV.BZ2", CompressionBzip2, "jobs/1/readings.CSV"},
+		{"xz", "jobs/1/readings.csv.xz", CompressionXz, "jobs/1/readings.csv"},
+		{"magic without extension", "jobs/1/readings.csv", CompressionGzip, "jobs/1/readings.csv"},
+		{"tgz", "jobs/1/bundle.tgz", CompressionGzip, "jobs/1/bundle.tar"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, inner, c, err := Decompress(bytes.NewReader(compressedSample(t, tc.c)), tc.fileKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != sampleCSV {
+				t.Errorf("content = %q, want %q", got, sampleCSV)
+			}
+			if inner != tc.wantInner {
+				t.Errorf("inner key = %q, want %q", inner, tc.wantInner)
+			}
+			if c != tc.c {
+				t.Errorf("compression = %q, want %q", c, tc.c)
+			}
+		})
+	}
+}
+
+func TestDecompressRejectsMismatchedExtension(t *testing.T) {
+	if _, _, _, err := Decompress(bytes.NewReader([]byte(sampleCSV)), "readings.csv.gz"); err == nil {
+		t.Fatal("expected error for plain content with .gz extension")
+	}
+}
+
+func TestDecompressEmptyInput(t *testing.T) {
+	rc, inner, c, err := Decompress(bytes.NewReader(nil), "empty.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner != "empty.csv" || c != CompressionNone {
+		t.Fatalf("got %q, %q", inner, c)
+	}
+	if data, _ := io.ReadAll(rc); len(data) != 0 {
+		t.Fatalf("unexpected content %q", data)
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		data, err := Compress([]byte(sampleCSV), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := NewDecompressReader(bytes.NewReader(data), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != sampleCSV {
+			t.Errorf("%s: got %q", c, got)
+		}
+	}
+
+	if _, err := Compress([]byte(sampleCSV), CompressionBzip2); err == nil {
+		t.Error("expected error for bzip2 chunk compression")
+	}
+}
+
+func TestSplitCompressedCSV(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(sampleCSV))
+	zw.Close()
+
+	rc, _, _, err := Decompress(&buf, "readings.csv.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := SplitCSVToChunks(rc, ChunkPolicy{MaxRecords: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+}
@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Значения по умолчанию анализа совместных аномалий.
+const (
+	DefaultCorrelationInterval = time.Minute
+	DefaultCoAnomalyTolerance  = time.Minute
+	DefaultMinCorrelation      = 0.7
+	// DefaultMinOverlap — сколько общих интервалов нужно паре рядов,
+	// чтобы её корреляция что-то значила.
+	DefaultMinOverlap = 10
+	// MaxCorrelationSeries ограничивает число рядов, пары которых
+	// сравниваются: пар квадратично больше, чем рядов.
+	MaxCorrelationSeries = 500
+)
+
+// CorrelationConfig — параметры анализа. Ряды сводятся к средним по
+// интервалам Interval, аномалии разных датчиков, между которыми не больше
+// Tolerance, объединяются в инцидент.
+type CorrelationConfig struct {
+	Interval       time.Duration
+	Tolerance      time.Duration
+	MinCorrelation float64
+	MinOverlap     int
+}
+
+// ParseCorrelationConfig проверяет параметры: длительности — как в Go
+// (30s, 5m), пустые и нулевые значения заменяются значениями по
+// умолчанию.
+func ParseCorrelationConfig(interval, tolerance string, minCorrelation float64) (CorrelationConfig, error) {
+	cfg := CorrelationConfig{
+		Interval:       DefaultCorrelationInterval,
+		Tolerance:      DefaultCoAnomalyTolerance,
+		MinCorrelation: DefaultMinCorrelation,
+		MinOverlap:     DefaultMinOverlap,
+	}
+	if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid interval %q", interval)
+		}
+		cfg.Interval = d
+	}
+	if tolerance != "" {
+		d, err := time.ParseDuration(tolerance)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid tolerance %q", tolerance)
+		}
+		cfg.Tolerance = d
+	}
+	if minCorrelation != 0 {
+		if minCorrelation < 0 || minCorrelation > 1 {
+			return cfg, fmt.Errorf("min_correlation must be in 0..1")
+		}
+		cfg.MinCorrelation = minCorrelation
+	}
+	return cfg, nil
+}
+
+// SeriesKey — ряд метрики одного датчика.
+type SeriesKey struct {
+	SensorID string `json:"sensor_id"`
+	Metric   string `json:"metric"`
+}
+
+// TimedValue — значение ряда в момент Time.
+type TimedValue struct {
+	Time  time.Time
+	Value float64
+}
+
+// AnomalyEvent — аномалия метрики датчика, найденная детектором.
+type AnomalyEvent struct {
+	SeriesKey
+	Time     time.Time
+	Detector string
+	Severity Severity
+}
+
+// Correlation — коэффициент Пирсона пары рядов по Samples общим
+// интервалам.
+type Correlation struct {
+	A           SeriesKey `json:"a"`
+	B           SeriesKey `json:"b"`
+	Coefficient float64   `json:"coefficient"`
+	Samples     int       `json:"samples"`
+}
+
+// Incident — аномалии нескольких датчиков, случившиеся почти
+// одновременно: скорее реальное событие, чем сбой одного датчика.
+type Incident struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Sensors   []string  `json:"sensors"`
+	Metrics   []string  `json:"metrics"`
+	Anomalies int       `json:"anomalies"`
+	Severity  Severity  `json:"severity"`
+	// Correlation — средний модуль корреляции пар рядов инцидента из
+	// разных датчиков; 0, если ни одна пара не набрала общих интервалов.
+	Correlation float64 `json:"correlation"`
+}
+
+// CorrelationReport — результат анализа задания. Pairs — пары с модулем
+// корреляции не ниже порога, от сильных к слабым. Truncated — рядов было
+// больше MaxCorrelationSeries, сравнивались только первые по ключу.
+type CorrelationReport struct {
+	Pairs     []Correlation `json:"pairs,omitempty"`
+	Incidents []Incident    `json:"incidents,omitempty"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// AnalyzeCorrelations считает попарные корреляции рядов и собирает
+// совместные аномалии в инциденты.
+func AnalyzeCorrelations(series map[SeriesKey][]TimedValue, events []AnomalyEvent, cfg CorrelationConfig) CorrelationReport {
+	acc := NewCorrelationSeries(cfg.Interval)
+	for k, values := range series {
+		for _, v := range values {
+			acc.Add(k, v.Time, v.Value)
+		}
+	}
+	return acc.Analyze(events, cfg)
+}
+
+// CorrelationSeries накапливает средние рядов по интервалам по мере
+// поступления значений: корреляция считается по средним, поэтому сами
+// значения хранить не нужно.
+type CorrelationSeries struct {
+	interval time.Duration
+	buckets  map[SeriesKey]map[int64]*meanAcc
+}
+
+type meanAcc struct {
+	sum float64
+	n   int
+}
+
+func NewCorrelationSeries(interval time.Duration) *CorrelationSeries {
+	return &CorrelationSeries{interval: interval, buckets: map[SeriesKey]map[int64]*meanAcc{}}
+}
+
+// Add учитывает значение ряда key в момент t.
+func (s *CorrelationSeries) Add(key SeriesKey, t time.Time, v float64) {
+	series, ok := s.buckets[key]
+	if !ok {
+		series = map[int64]*meanAcc{}
+		s.buckets[key] = series
+	}
+	b := t.Truncate(s.interval).UnixNano()
+	acc, ok := series[b]
+	if !ok {
+		acc = &meanAcc{}
+		series[b] = acc
+	}
+	acc.sum += v
+	acc.n++
+}
+
+// Analyze считает попарные корреляции накопленных рядов и собирает
+// совместные аномалии в инциденты. Интервал рядов задан при создании,
+// cfg.Interval не используется.
+func (s *CorrelationSeries) Analyze(events []AnomalyEvent, cfg CorrelationConfig) CorrelationReport {
+	keys := make([]SeriesKey, 0, len(s.buckets))
+	for k := range s.buckets {
+		keys = append(keys, k)
+	}
+	sortSeriesKeys(keys)
+
+	var rep CorrelationReport
+	if len(keys) > MaxCorrelationSeries {
+		keys = keys[:MaxCorrelationSeries]
+		rep.Truncated = true
+	}
+	buckets := make([]map[int64]float64, len(keys))
+	for i, k := range keys {
+		buckets[i] = s.means(k)
+	}
+
+	all := map[[2]SeriesKey]Correlation{}
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			r, n := pearson(buckets[i], buckets[j])
+			if n < cfg.MinOverlap || math.IsNaN(r) {
+				continue
+			}
+			c := Correlation{A: keys[i], B: keys[j], Coefficient: round3(r), Samples: n}
+			all[[2]SeriesKey{keys[i], keys[j]}] = c
+			if math.Abs(r) >= cfg.MinCorrelation {
+				rep.Pairs = append(rep.Pairs, c)
+			}
+		}
+	}
+	sort.SliceStable(rep.Pairs, func(i, j int) bool {
+		return math.Abs(rep.Pairs[i].Coefficient) > math.Abs(rep.Pairs[j].Coefficient)
+	})
+
+	rep.Incidents = ClusterAnomalies(events, cfg.Tolerance)
+	for i := range rep.Incidents {
+		rep.Incidents[i].Correlation = incidentCorrelation(events, rep.Incidents[i], all)
+	}
+	return rep
+}
+
+// ClusterAnomalies объединяет аномалии, между соседними по времени
+// которыми не больше tolerance, и оставляет группы хотя бы из двух
+// датчиков. Инциденты упорядочены по началу.
+func ClusterAnomalies(events []AnomalyEvent, tolerance time.Duration) []Incident {
+	ordered := append([]AnomalyEvent(nil), events...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Time.Before(ordered[j].Time) })
+
+	var out []Incident
+	for start := 0; start < len(ordered); {
+		end := start + 1
+		for end < len(ordered) && ordered[end].Time.Sub(ordered[end-1].Time) <= tolerance {
+			end++
+		}
+		if inc, ok := newIncident(ordered[start:end]); ok {
+			out = append(out, inc)
+		}
+		start = end
+	}
+	return out
+}
+
+func newIncident(group []AnomalyEvent) (Incident, bool) {
+	sensors, metrics := map[string]bool{}, map[string]bool{}
+	inc := Incident{Start: group[0].Time, End: group[len(group)-1].Time, Anomalies: len(group), Severity: SeverityWarning}
+	for _, e := range group {
+		sensors[e.SensorID] = true
+		metrics[e.Metric] = true
+		if e.Severity == SeverityCritical {
+			inc.Severity = SeverityCritical
+		}
+	}
+	if len(sensors) < 2 {
+		return Incident{}, false
+	}
+	inc.Sensors = sortedKeys(sensors)
+	inc.Metrics = sortedKeys(metrics)
+	return inc, true
+}
+
+// incidentCorrelation — средний модуль корреляции пар рядов инцидента,
+// принадлежащих разным датчикам.
+func incidentCorrelation(events []AnomalyEvent, inc Incident, all map[[2]SeriesKey]Correlation) float64 {
+	seen := map[SeriesKey]bool{}
+	var keys []SeriesKey
+	for _, e := range events {
+		if e.Time.Before(inc.Start) || e.Time.After(inc.End) || seen[e.SeriesKey] {
+			continue
+		}
+		seen[e.SeriesKey] = true
+		keys = append(keys, e.SeriesKey)
+	}
+	sortSeriesKeys(keys)
+
+	var sum float64
+	var n int
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			if keys[i].SensorID == keys[j].SensorID {
+				continue
+			}
+			if c, ok := all[[2]SeriesKey{keys[i], keys[j]}]; ok {
+				sum += math.Abs(c.Coefficient)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return round3(sum / float64(n))
+}
+
+// means — средние ряда по интервалам.
+func (s *CorrelationSeries) means(key SeriesKey) map[int64]float64 {
+	out := make(map[int64]float64, len(s.buckets[key]))
+	for b, acc := range s.buckets[key] {
+		out[b] = acc.sum / float64(acc.n)
+	}
+	return out
+}
+
+// pearson — коэффициент корреляции по общим интервалам двух рядов и их
+// число. Для постоянного ряда коэффициент не определён (NaN).
+func pearson(a, b map[int64]float64) (float64, int) {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var n, sx, sy, sxx, syy, sxy float64
+	for t, x := range a {
+		y, ok := b[t]
+		if !ok {
+			continue
+		}
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		syy += y * y
+		sxy += x * y
+	}
+	if n < 2 {
+		return math.NaN(), int(n)
+	}
+	cov := sxy - sx*sy/n
+	vx := sxx - sx*sx/n
+	vy := syy - sy*sy/n
+	if vx <= 0 || vy <= 0 {
+		return math.NaN(), int(n)
+	}
+	return math.Max(-1, math.Min(1, cov/math.Sqrt(vx*vy))), int(n)
+}
+
+func sortSeriesKeys(keys []SeriesKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].SensorID != keys[j].SensorID {
+			return keys[i].SensorID < keys[j].SensorID
+		}
+		return keys[i].Metric < keys[j].Metric
+	})
+}
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
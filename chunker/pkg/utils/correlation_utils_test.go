@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func series(start time.Time, f func(i int) float64, n int) []TimedValue {
+	out := make([]TimedValue, n)
+	for i := range out {
+		out[i] = TimedValue{Time: start.Add(time.Duration(i) * time.Minute), Value: f(i)}
+	}
+	return out
+}
+
+func TestAnalyzeCorrelationsPairs(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := SeriesKey{SensorID: "a", Metric: "temperature"}
+	b := SeriesKey{SensorID: "b", Metric: "temperature"}
+	c := SeriesKey{SensorID: "c", Metric: "humidity"}
+	in := map[SeriesKey][]TimedValue{
+		a: series(start, func(i int) float64 { return float64(i) }, 30),
+		b: series(start, func(i int) float64 { return 2*float64(i) + 5 }, 30),
+		// убывает, пока растут a и b
+		c: series(start, func(i int) float64 { return 100 - float64(i) }, 30),
+	}
+	cfg, err := ParseCorrelationConfig("", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := AnalyzeCorrelations(in, nil, cfg)
+	if len(rep.Pairs) != 3 {
+		t.Fatalf("pairs: %+v", rep.Pairs)
+	}
+	for _, p := range rep.Pairs {
+		want := 1.0
+		if p.A == c || p.B == c {
+			want = -1
+		}
+		if p.Coefficient != want || p.Samples != 30 {
+			t.Errorf("%+v: want %g", p, want)
+		}
+	}
+}
+
+func TestAnalyzeCorrelationsSkipsShortOverlap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := map[SeriesKey][]TimedValue{
+		{SensorID: "a", Metric: "temperature"}: series(start, func(i int) float64 { return float64(i) }, 5),
+		{SensorID: "b", Metric: "temperature"}: series(start, func(i int) float64 { return float64(i) }, 5),
+		// постоянный ряд: корреляция не определена
+		{SensorID: "c", Metric: "temperature"}: series(start, func(int) float64 { return 1 }, 5),
+	}
+	cfg, _ := ParseCorrelationConfig("", "", 0)
+	if rep := AnalyzeCorrelations(in, nil, cfg); len(rep.Pairs) != 0 {
+		t.Errorf("short overlap: %+v", rep.Pairs)
+	}
+	cfg.MinOverlap = 3
+	if rep := AnalyzeCorrelations(in, nil, cfg); len(rep.Pairs) != 1 {
+		t.Errorf("min overlap 3: %+v", rep.Pairs)
+	}
+}
+
+func TestClusterAnomalies(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ev := func(sensor string, sec int, sev Severity) AnomalyEvent {
+		return AnomalyEvent{SeriesKey: SeriesKey{SensorID: sensor, Metric: "temperature"}, Time: start.Add(time.Duration(sec) * time.Second), Severity: sev}
+	}
+	events := []AnomalyEvent{
+		ev("b", 30, SeverityCritical),
+		ev("a", 0, SeverityWarning),
+		ev("c", 80, SeverityWarning),
+		// один датчик — не инцидент
+		ev("a", 600, SeverityWarning),
+		ev("a", 620, SeverityWarning),
+	}
+	got := ClusterAnomalies(events, time.Minute)
+	if len(got) != 1 {
+		t.Fatalf("incidents: %+v", got)
+	}
+	inc := got[0]
+	if !inc.Start.Equal(start) || inc.End.Sub(inc.Start) != 80*time.Second || inc.Anomalies != 3 ||
+		len(inc.Sensors) != 3 || inc.Sensors[0] != "a" || inc.Severity != SeverityCritical {
+		t.Errorf("incident: %+v", inc)
+	}
+}
+
+func TestIncidentCorrelation(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := SeriesKey{SensorID: "a", Metric: "temperature"}
+	b := SeriesKey{SensorID: "b", Metric: "temperature"}
+	in := map[SeriesKey][]TimedValue{
+		a: series(start, func(i int) float64 { return math.Sin(float64(i)) }, 30),
+		b: series(start, func(i int) float64 { return math.Sin(float64(i)) * 3 }, 30),
+	}
+	events := []AnomalyEvent{
+		{SeriesKey: a, Time: start.Add(10 * time.Minute)},
+		{SeriesKey: b, Time: start.Add(10*time.Minute + 20*time.Second)},
+	}
+	cfg, _ := ParseCorrelationConfig("1m", "30s", 0.9)
+	rep := AnalyzeCorrelations(in, events, cfg)
+	if len(rep.Incidents) != 1 || rep.Incidents[0].Correlation != 1 {
+		t.Errorf("incidents: %+v", rep.Incidents)
+	}
+}
+
+func TestParseCorrelationConfigErrors(t *testing.T) {
+	for _, tc := range []struct {
+		interval, tolerance string
+		min                 float64
+	}{
+		{"0s", "", 0},
+		{"", "soon", 0},
+		{"", "", 1.5},
+	} {
+		if _, err := ParseCorrelationConfig(tc.interval, tc.tolerance, tc.min); err == nil {
+			t.Errorf("%+v: expected error", tc)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// csvCell — ячейка строки для transformCSVCells. fn меняет Value или
+// ставит Drop, чтобы удалить колонку.
+type csvCell struct {
+	Value string
+	Drop  bool
+	raw   []byte
+	orig  string
+}
+
+// transformCSVCells применяет fn к строкам CSV-чанка. Байты чанка
+// сохраняются везде, кроме изменённых ячеек: строки без изменений,
+// неизменённые ячейки, кавычки, переводы строк (в том числе CRLF внутри
+// кавычек) и пустые строки копируются как есть. Изменённая ячейка
+// записывается заново и берётся в кавычки, если нужно.
+func transformCSVCells(chunk []byte, fn func(row []csvCell) (int, error)) ([]byte, int, error) {
+	var out bytes.Buffer
+	total, changed := 0, false
+	for pos := 0; pos < len(chunk); {
+		row, end, next, err := scanCSVRecord(chunk, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		// пустые строки csv.Reader пропускает — здесь они тоже не записи
+		if end == pos {
+			out.Write(chunk[pos:next])
+			pos = next
+			continue
+		}
+
+		n, err := fn(row)
+		if err != nil {
+			return nil, 0, err
+		}
+		total += n
+
+		dirty := false
+		for _, c := range row {
+			dirty = dirty || c.Drop || c.Value != c.orig
+		}
+		if !dirty {
+			out.Write(chunk[pos:next])
+			pos = next
+			continue
+		}
+
+		changed = true
+		first := true
+		for _, c := range row {
+			if c.Drop {
+				continue
+			}
+			if !first {
+				out.WriteByte(',')
+			}
+			first = false
+			if c.Value == c.orig {
+				out.Write(c.raw)
+			} else {
+				writeCSVField(&out, c.Value)
+			}
+		}
+		out.Write(chunk[end:next])
+		pos = next
+	}
+	if !changed {
+		return chunk, total, nil
+	}
+	return out.Bytes(), total, nil
+}
+
+// scanCSVRecord разбирает запись CSV с позиции pos: ячейки, конец записи
+// без перевода строки и начало следующей. Кавычки — по RFC 4180, как
+// в encoding/csv без LazyQuotes.
+func scanCSVRecord(data []byte, pos int) (row []csvCell, end, next int, err error) {
+	atEOL := func(i int) bool {
+		return i >= len(data) || data[i] == '\n' || data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n'
+	}
+	if atEOL(pos) {
+		return nil, pos, skipEOL(data, pos), nil
+	}
+
+	for {
+		start := pos
+		var value string
+		if pos < len(data) && data[pos] == '"' {
+			var b strings.Builder
+			for pos++; ; pos++ {
+				if pos >= len(data) {
+					return nil, 0, 0, fmt.Errorf("offset %d: extraneous or missing \" in quoted-field", start)
+				}
+				if data[pos] == '"' {
+					if pos+1 < len(data) && data[pos+1] == '"' {
+						b.WriteByte('"')
+						pos++
+						continue
+					}
+					pos++
+					break
+				}
+				b.WriteByte(data[pos])
+			}
+			if !atEOL(pos) && data[pos] != ',' {
+				return nil, 0, 0, fmt.Errorf("offset %d: extraneous or missing \" in quoted-field", start)
+			}
+			value = b.String()
+		} else {
+			for !atEOL(pos) && data[pos] != ',' {
+				if data[pos] == '"' {
+					return nil, 0, 0, fmt.Errorf("offset %d: bare \" in non-quoted-field", pos)
+				}
+				pos++
+			}
+			value = string(data[start:pos])
+		}
+		row = append(row, csvCell{Value: value, raw: data[start:pos], orig: value})
+
+		if pos < len(data) && data[pos] == ',' {
+			pos++
+			continue
+		}
+		return row, pos, skipEOL(data, pos), nil
+	}
+}
+
+func skipEOL(data []byte, pos int) int {
+	switch {
+	case pos < len(data) && data[pos] == '\n':
+		return pos + 1
+	case pos+1 < len(data) && data[pos] == '\r' && data[pos+1] == '\n':
+		return pos + 2
+	default:
+		return pos
+	}
+}
+
+// writeCSVField записывает значение по правилам csv.Writer.
+func writeCSVField(w *bytes.Buffer, v string) {
+	if v == "" || !strings.ContainsAny(v, ",\"\r\n") && v[0] != ' ' && v[0] != '\t' {
+		w.WriteString(v)
+		return
+	}
+	w.WriteByte('"')
+	w.WriteString(strings.ReplaceAll(v, `"`, `""`))
+	w.WriteByte('"')
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTransformCSVCellsPreservesBytes(t *testing.T) {
+	chunk := []byte("id,note,v\r\n1,\"a\r\nb\",x\r\n\n2,  c ,y\r\n3,,\"z\"\"\"")
+	upper := func(row []csvCell) (int, error) {
+		if row[0].Value == "id" {
+			return 0, nil
+		}
+		row[2].Value = strings.ToUpper(row[2].Value)
+		return 1, nil
+	}
+	out, n, err := transformCSVCells(chunk, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id,note,v\r\n1,\"a\r\nb\",X\r\n\n2,  c ,Y\r\n3,,\"Z\"\"\""
+	if n != 3 || string(out) != want {
+		t.Fatalf("got %q (%d), want %q", out, n, want)
+	}
+
+	same, _, err := transformCSVCells(chunk, func([]csvCell) (int, error) { return 0, nil })
+	if err != nil || !bytes.Equal(same, chunk) {
+		t.Fatalf("unchanged chunk: %q, %v", same, err)
+	}
+
+	drop := func(row []csvCell) (int, error) {
+		row[1].Drop = true
+		return 0, nil
+	}
+	out, _, err = transformCSVCells(chunk, drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,v\r\n1,x\r\n\n2,y\r\n3,\"z\"\"\""; string(out) != want {
+		t.Fatalf("drop: got %q, want %q", out, want)
+	}
+	if _, _, err := transformCSVCells([]byte("a,\"b\n"), drop); err == nil {
+		t.Fatal("expected error for unterminated quote")
+	}
+}
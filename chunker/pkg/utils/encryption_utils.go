@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DataKeySize — размер ключа данных AES-256.
+const DataKeySize = 32
+
+// EncryptedPrefix отмечает зашифрованное значение поля:
+// "enc:v1:" + base64(nonce || ciphertext).
+const EncryptedPrefix = "enc:v1:"
+
+// GenerateDataKey создаёт случайный ключ данных.
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// ReadKeyFile читает 256-битный ключ из файла: 32 байта как есть,
+// в hex или в base64.
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseKey разбирает 256-битный ключ: 32 байта как есть, в hex или в base64.
+func ParseKey(data []byte) ([]byte, error) {
+	if len(data) == DataKeySize {
+		return data, nil
+	}
+	s := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(s); err == nil && len(key) == DataKeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == DataKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("want %d bytes raw, hex or base64", DataKeySize)
+}
+
+// SealAESGCM шифрует plaintext ключом AES-GCM; nonce идёт в начале результата.
+func SealAESGCM(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// OpenAESGCM расшифровывает результат SealAESGCM.
+func OpenAESGCM(key, sealed, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ct := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ct, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ChunkAAD — связанные данные шифрования полей чанка: задание и номер
+// чанка в нём.
+func ChunkAAD(jobID string, chunkID int) []byte {
+	return []byte(jobID + "/" + strconv.Itoa(chunkID))
+}
+
+// fieldAAD дополняет aad чанка позицией записи и именем поля, чтобы
+// шифротекст нельзя было переставить в другую запись или другое поле.
+func fieldAAD(aad []byte, item int, field string) []byte {
+	out := append([]byte(nil), aad...)
+	out = append(out, 0)
+	out = strconv.AppendInt(out, int64(item), 10)
+	out = append(out, 0)
+	return append(out, field...)
+}
+
+// EncryptChunkFields шифрует значения полей fields в чанке — JSON-массиве
+// объектов. Поле — ключ верхнего уровня или путь "<объект>.<ключ>"
+// к вложенному значению (metrics.co2 канонической записи, см.
+// lookupField). Шифруется JSON-представление значения, null остаётся как
+// есть. aad привязывает шифротекст к чанку (см. ChunkAAD), к нему
+// добавляются номер записи и имя поля. Возвращает число зашифрованных
+// значений.
+func EncryptChunkFields(chunk []byte, fields []string, key, aad []byte) ([]byte, int, error) {
+	if len(fields) == 0 {
+		return chunk, 0, nil
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	fields = dedupFields(fields)
+
+	return transformJSONItems(chunk, func(i int, obj map[string]json.RawMessage) (int, error) {
+		n := 0
+		for _, name := range fields {
+			v, set, ok := lookupField(obj, name)
+			if !ok || isJSONNull(v) {
+				continue
+			}
+			nonce := make([]byte, aead.NonceSize())
+			if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+				return 0, err
+			}
+			sealed := aead.Seal(nonce, nonce, v, fieldAAD(aad, i, name))
+			enc, _ := json.Marshal(EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed))
+			if err := set(enc); err != nil {
+				return 0, fmt.Errorf("field %s: %w", name, err)
+			}
+			n++
+		}
+		return n, nil
+	})
+}
+
+// DecryptChunkFields расшифровывает поля fields чанка, зашифрованного
+// EncryptChunkFields с тем же aad, и возвращает им исходный тип. Значения
+// других полей не трогаются, даже если похожи на шифротекст; значение
+// поля из fields без шифрования — ошибка.
+func DecryptChunkFields(chunk []byte, fields []string, key, aad []byte) ([]byte, error) {
+	if len(fields) == 0 {
+		return chunk, nil
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	fields = dedupFields(fields)
+
+	out, _, err := transformJSONItems(chunk, func(i int, obj map[string]json.RawMessage) (int, error) {
+		n := 0
+		for _, name := range fields {
+			v, set, ok := lookupField(obj, name)
+			if !ok || isJSONNull(v) {
+				continue
+			}
+			var s string
+			if json.Unmarshal(v, &s) != nil || !strings.HasPrefix(s, EncryptedPrefix) {
+				return 0, fmt.Errorf("field %s is not encrypted", name)
+			}
+			sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, EncryptedPrefix))
+			if err != nil {
+				return 0, fmt.Errorf("field %s: %w", name, err)
+			}
+			if len(sealed) < aead.NonceSize() {
+				return 0, fmt.Errorf("field %s: ciphertext is too short", name)
+			}
+			plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], fieldAAD(aad, i, name))
+			if err != nil {
+				return 0, fmt.Errorf("field %s: %w", name, err)
+			}
+			if err := set(plain); err != nil {
+				return 0, fmt.Errorf("field %s: %w", name, err)
+			}
+			n++
+		}
+		return n, nil
+	})
+	return out, err
+}
+
+// lookupField находит значение поля записи: сначала ключ верхнего уровня
+// целиком, затем путь "<объект>.<ключ>" — ключ вложенного объекта.
+// set заменяет найденное значение на месте.
+func lookupField(obj map[string]json.RawMessage, field string) (json.RawMessage, func(json.RawMessage) error, bool) {
+	if v, ok := obj[field]; ok {
+		return v, func(nv json.RawMessage) error {
+			obj[field] = nv
+			return nil
+		}, true
+	}
+
+	parent, name, ok := strings.Cut(field, ".")
+	if !ok {
+		return nil, nil, false
+	}
+	var nested map[string]json.RawMessage
+	if json.Unmarshal(obj[parent], &nested) != nil {
+		return nil, nil, false
+	}
+	v, ok := nested[name]
+	if !ok {
+		return nil, nil, false
+	}
+	return v, func(nv json.RawMessage) error {
+		nested[name] = nv
+		data, err := marshalJSON(nested)
+		if err != nil {
+			return err
+		}
+		obj[parent] = data
+		return nil
+	}, true
+}
+
+func dedupFields(fields []string) []string {
+	seen := make(map[string]bool, len(fields))
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// marshalJSON кодирует значение без экранирования HTML, как остальные
+// записи чанков.
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// transformJSONItems применяет fn к объектам JSON-массива; i — позиция
+// элемента в массиве. Если fn ничего не изменил, чанк возвращается как есть.
+func transformJSONItems(chunk []byte, fn func(i int, obj map[string]json.RawMessage) (int, error)) ([]byte, int, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(chunk, &items); err != nil {
+		return nil, 0, err
+	}
+
+	total := 0
+	for i, item := range items {
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(item, &obj) != nil || obj == nil {
+			continue
+		}
+		n, err := fn(i, obj)
+		if err != nil {
+			return nil, 0, fmt.Errorf("item %d: %w", i, err)
+		}
+		if n == 0 {
+			continue
+		}
+		data, err := marshalJSON(obj)
+		if err != nil {
+			return nil, 0, err
+		}
+		items[i] = data
+		total += n
+	}
+	if total == 0 {
+		return chunk, 0, nil
+	}
+
+	c := &arrayChunker{}
+	for _, item := range items {
+		c.add(item)
+	}
+	return c.result()[0], total, nil
+}
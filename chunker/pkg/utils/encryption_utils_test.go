@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncryptChunkFieldsJSON(t *testing.T) {
+	key, err := GenerateDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := ChunkAAD("job-1", 0)
+	fields := []string{"temperature", "humidity"}
+	chunk := []byte(`[{"sensor_id":"s1","temperature":20.5,"humidity":null},{"sensor_id":"s2","temperature":"<hot>"},1]`)
+
+	enc, n, err := EncryptChunkFields(chunk, fields, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("encrypted %d values, want 2", n)
+	}
+	if strings.Contains(string(enc), "20.5") || strings.Contains(string(enc), "hot") {
+		t.Fatalf("plaintext leaked: %s", enc)
+	}
+
+	dec, err := DecryptChunkFields(enc, fields, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want []any
+	if err := json.Unmarshal(dec, &got); err != nil {
+		t.Fatal(err)
+	}
+	_ = json.Unmarshal(chunk, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %s, want %s", dec, chunk)
+	}
+
+	for _, other := range [][]byte{ChunkAAD("job-2", 0), ChunkAAD("job-1", 1)} {
+		if _, err := DecryptChunkFields(enc, fields, key, other); err == nil {
+			t.Fatalf("expected error for aad %s", other)
+		}
+	}
+}
+
+func TestDecryptChunkFieldsRejectsMovedCiphertext(t *testing.T) {
+	key, _ := GenerateDataKey()
+	aad := ChunkAAD("job-1", 0)
+	fields := []string{"temperature", "humidity"}
+	chunk := []byte(`[{"temperature":20,"humidity":40},{"temperature":21,"humidity":41}]`)
+	enc, _, err := EncryptChunkFields(chunk, fields, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(enc, &items); err != nil {
+		t.Fatal(err)
+	}
+
+	swap := func(mutate func(a, b map[string]json.RawMessage)) []byte {
+		var copied []map[string]json.RawMessage
+		_ = json.Unmarshal(enc, &copied)
+		mutate(copied[0], copied[1])
+		data, _ := json.Marshal(copied)
+		return data
+	}
+	for name, data := range map[string][]byte{
+		"fields": swap(func(a, _ map[string]json.RawMessage) {
+			a["temperature"], a["humidity"] = a["humidity"], a["temperature"]
+		}),
+		"records": swap(func(a, b map[string]json.RawMessage) {
+			a["temperature"], b["temperature"] = b["temperature"], a["temperature"]
+		}),
+		"plain": swap(func(a, _ map[string]json.RawMessage) { a["humidity"] = json.RawMessage("40") }),
+	} {
+		if _, err := DecryptChunkFields(data, fields, key, aad); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDecryptChunkFieldsOnlyListedFields(t *testing.T) {
+	key, _ := GenerateDataKey()
+	aad := ChunkAAD("job-1", 0)
+	enc, _, err := EncryptChunkFields([]byte(`[{"temperature":20,"note":"x"}]`), []string{"temperature"}, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]any
+	_ = json.Unmarshal(enc, &items)
+	// значение из файла, похожее на шифротекст, остаётся как есть
+	items[0]["note"] = EncryptedPrefix + "AAAA"
+	data, _ := json.Marshal(items)
+
+	dec, err := DecryptChunkFields(data, []string{"temperature"}, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	_ = json.Unmarshal(dec, &got)
+	if got[0]["temperature"] != 20.0 || got[0]["note"] != EncryptedPrefix+"AAAA" {
+		t.Fatalf("got %s", dec)
+	}
+}
+
+func TestEncryptChunkFieldsWithoutMatches(t *testing.T) {
+	key, _ := GenerateDataKey()
+	chunk := []byte(`[{"a": 1}]`)
+
+	enc, n, err := EncryptChunkFields(chunk, []string{"temperature"}, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || string(enc) != string(chunk) {
+		t.Fatalf("chunk changed: %s", enc)
+	}
+}
+
+func TestEncryptChunkFieldsNestedMetrics(t *testing.T) {
+	key, _ := GenerateDataKey()
+	aad := ChunkAAD("job-1", 0)
+	chunk := []byte(`[{"sensor_id":"s1","temperature":20.5,"metrics":{"co2":412,"rssi":-70}},{"sensor_id":"s2","metrics":{"rssi":-60}}]`)
+
+	enc, n, err := EncryptChunkFields(chunk, []string{"metrics.co2", "temperature"}, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || bytes.Contains(enc, []byte("412")) || !bytes.Contains(enc, []byte(`"rssi":-70`)) {
+		t.Fatalf("encrypted %d: %s", n, enc)
+	}
+
+	// шифротекст метрики привязан к её пути
+	if _, err := DecryptChunkFields(bytes.Replace(enc, []byte(`"co2"`), []byte(`"o2"`), 1), []string{"metrics.o2", "temperature"}, key, aad); err == nil {
+		t.Error("ciphertext moved to another metric was accepted")
+	}
+
+	dec, err := DecryptChunkFields(enc, []string{"metrics.co2", "temperature"}, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want any
+	json.Unmarshal(dec, &got)
+	json.Unmarshal(chunk, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip: %s", dec)
+	}
+}
+
+func TestSealAESGCM(t *testing.T) {
+	key, _ := GenerateDataKey()
+	sealed, err := SealAESGCM(key, []byte("secret"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := OpenAESGCM(key, sealed, []byte("aad"))
+	if err != nil || string(plain) != "secret" {
+		t.Fatalf("got %q, %v", plain, err)
+	}
+	if _, err := OpenAESGCM(key, sealed, []byte("other")); err == nil {
+		t.Fatal("expected error for wrong aad")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key, _ := GenerateDataKey()
+	for _, data := range [][]byte{
+		key,
+		[]byte(hex.EncodeToString(key) + "\n"),
+		[]byte(base64.StdEncoding.EncodeToString(key)),
+	} {
+		got, err := ParseKey(data)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Fatalf("ParseKey(%q) = %x, %v", data, got, err)
+		}
+	}
+	if _, err := ParseKey([]byte("short")); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FormatSniffSize — сколько байт начала файла получают сниффер-функции.
+const FormatSniffSize = 512
+
+// Capabilities описывает свойства формата, важные для конвейера.
+type Capabilities struct {
+	// Streaming — Split отдаёт чанки по мере разбора и не держит вход
+	// в памяти; вход остальных сплиттеров ограничен MaxSplitInputSize.
+	// Встроенные сплиттеры так не умеют: все возвращают SplitResult
+	// целиком.
+	Streaming bool
+	// Header — первая строка первого чанка содержит имена колонок.
+	Header bool
+	// EmbeddedSchema — схема записей хранится в самом файле (parquet,
+	// avro): колонки и типы одинаковы у всех записей, а не только у
+	// выборки проверки схемы.
+	EmbeddedSchema bool
+	// RequiresSchema — для разбора нужна схема тенанта (SplitOptions.Descriptor).
+	RequiresSchema bool
+	// Converts — записи проходят через SplitOptions.Convert, чанки содержат
+	// уже сконвертированные записи.
+	Converts bool
+}
+
+// SplitOptions — параметры задания, которые могут понадобиться сплиттеру.
+type SplitOptions struct {
+	Chunking     ChunkPolicy
+	ParquetSplit ParquetSplitMode
+	Sheet        string
+	Precision    string
+	Descriptor   protoreflect.MessageDescriptor
+	Convert      RecordConverter
+}
+
+// SplitResult — чанки и статистика разбора. Построчные форматы заполняют
+// Lines, форматы с конвертацией записей — Records.
+type SplitResult struct {
+	Chunks  [][]byte
+	Lines   NDJSONStats
+	Records DecodeStats
+}
+
+// MaxSplitInputSize ограничивает распакованный вход сплиттера без
+// Capabilities.Streaming: Split возвращает все чанки источника сразу, так
+// что вход целиком оседает в памяти в виде чанков (а xlsx и parquet ещё и
+// во временном файле).
+// Вход больше предела отклоняется с ErrInputTooLarge, а не исчерпывает
+// память процесса.
+var MaxSplitInputSize int64 = 1 << 30
+
+// ErrInputTooLarge — вход сплиттера больше MaxSplitInputSize.
+var ErrInputTooLarge = errors.New("input exceeds split size limit")
+
+// LimitSplitInput ограничивает вход сплиттера MaxSplitInputSize.
+func LimitSplitInput(r io.Reader) io.Reader {
+	return &limitReader{r: r, left: MaxSplitInputSize, err: ErrInputTooLarge}
+}
+
+// Splitter режет вход формата на чанки. Если в Capabilities нет
+// Streaming, результат собирается в памяти целиком, см. MaxSplitInputSize.
+type Splitter interface {
+	Name() string
+	Capabilities() Capabilities
+	Split(r io.Reader, opts SplitOptions) (SplitResult, error)
+}
+
+type SplitFunc func(r io.Reader, opts SplitOptions) (SplitResult, error)
+
+type funcSplitter struct {
+	name  string
+	caps  Capabilities
+	split SplitFunc
+}
+
+// NewSplitter собирает Splitter из функции разбора.
+func NewSplitter(name string, caps Capabilities, split SplitFunc) Splitter {
+	return &funcSplitter{name: name, caps: caps, split: split}
+}
+
+func (s *funcSplitter) Name() string               { return s.name }
+func (s *funcSplitter) Capabilities() Capabilities { return s.caps }
+func (s *funcSplitter) Split(r io.Reader, opts SplitOptions) (SplitResult, error) {
+	return s.split(r, opts)
+}
+
+// Format связывает сплиттер со способами его распознать.
+type Format struct {
+	Splitter   Splitter
+	Extensions []string // с точкой: ".csv"
+	MIMETypes  []string
+	// Sniff распознаёт формат по первым FormatSniffSize байтам,
+	// если не помогли ни расширение, ни MIME-тип.
+	Sniff func(header []byte) bool
+}
+
+type FormatRegistry struct {
+	formats []Format // в порядке регистрации, он же порядок сниффинга
+	byName  map[string]Splitter
+	byExt   map[string]Splitter
+	byMIME  map[string]Splitter
+}
+
+func NewFormatRegistry() *FormatRegistry {
+	return &FormatRegistry{
+		byName: map[string]Splitter{},
+		byExt:  map[string]Splitter{},
+		byMIME: map[string]Splitter{},
+	}
+}
+
+// Register добавляет формат. Имя, расширения и MIME-типы не должны
+// пересекаться с уже зарегистрированными.
+func (r *FormatRegistry) Register(f Format) error {
+	if f.Splitter == nil {
+		return fmt.Errorf("format has no splitter")
+	}
+	name := strings.ToLower(f.Splitter.Name())
+	if name == "" {
+		return fmt.Errorf("splitter has empty name")
+	}
+	if _, ok := r.byName[name]; ok {
+		return fmt.Errorf("format %s is already registered", name)
+	}
+
+	exts := make([]string, len(f.Extensions))
+	for i, ext := range f.Extensions {
+		exts[i] = strings.ToLower(ext)
+		if !strings.HasPrefix(exts[i], ".") {
+			return fmt.Errorf("format %s: extension %q must start with a dot", name, ext)
+		}
+		if s, ok := r.byExt[exts[i]]; ok {
+			return fmt.Errorf("format %s: extension %s is already registered for %s", name, ext, s.Name())
+		}
+	}
+	mimes := make([]string, len(f.MIMETypes))
+	for i, mt := range f.MIMETypes {
+		mimes[i] = strings.ToLower(mt)
+		if s, ok := r.byMIME[mimes[i]]; ok {
+			return fmt.Errorf("format %s: mime type %s is already registered for %s", name, mt, s.Name())
+		}
+	}
+
+	r.byName[name] = f.Splitter
+	for _, ext := range exts {
+		r.byExt[ext] = f.Splitter
+	}
+	for _, mt := range mimes {
+		r.byMIME[mt] = f.Splitter
+	}
+	r.formats = append(r.formats, f)
+	return nil
+}
+
+// Lookup ищет формат по имени или MIME-типу — так задаётся явный формат
+// при загрузке.
+func (r *FormatRegistry) Lookup(name string) (Splitter, bool) {
+	if s, ok := r.byName[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return s, true
+	}
+	return r.ByMIME(name)
+}
+
+func (r *FormatRegistry) ByExtension(fileKey string) (Splitter, bool) {
+	s, ok := r.byExt[strings.ToLower(filepath.Ext(fileKey))]
+	return s, ok
+}
+
+// ByMIME принимает значение Content-Type целиком, параметры игнорируются.
+func (r *FormatRegistry) ByMIME(contentType string) (Splitter, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	s, ok := r.byMIME[mt]
+	return s, ok
+}
+
+func (r *FormatRegistry) Sniff(header []byte) (Splitter, bool) {
+	for _, f := range r.formats {
+		if f.Sniff != nil && f.Sniff(header) {
+			return f.Splitter, true
+		}
+	}
+	return nil, false
+}
+
+// Names возвращает имена зарегистрированных форматов по алфавиту.
+func (r *FormatRegistry) Names() []string {
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultFormatRegistry возвращает реестр со всеми встроенными форматами.
+func DefaultFormatRegistry() *FormatRegistry {
+	r := NewFormatRegistry()
+	for _, f := range builtinFormats() {
+		if err := r.Register(f); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+func builtinFormats() []Format {
+	return []Format{
+		{
+			Splitter: NewSplitter("csv", Capabilities{Header: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, err := SplitCSVToChunks(r, o.Chunking)
+				return SplitResult{Chunks: chunks}, err
+			}),
+			Extensions: []string{".csv"},
+			MIMETypes:  []string{"text/csv", "application/csv"},
+		},
+		{
+			Splitter: NewSplitter("json", Capabilities{}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, err := SplitJSONToChunks(r, o.Chunking)
+				return SplitResult{Chunks: chunks}, err
+			}),
+			Extensions: []string{".json"},
+			MIMETypes:  []string{"application/json"},
+			Sniff:      func(h []byte) bool { return firstJSONByte(h) == '[' },
+		},
+		{
+			Splitter: NewSplitter("ndjson", Capabilities{}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, stats, err := SplitNDJSONToChunks(r, o.Chunking)
+				return SplitResult{Chunks: chunks, Lines: stats}, err
+			}),
+			Extensions: []string{".ndjson", ".jsonl"},
+			MIMETypes:  []string{"application/x-ndjson", "application/jsonl", "application/jsonlines"},
+			Sniff:      func(h []byte) bool { return firstJSONByte(h) == '{' },
+		},
+		{
+			Splitter: NewSplitter("parquet", Capabilities{EmbeddedSchema: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, err := SplitParquetToChunks(r, o.Chunking, o.ParquetSplit)
+				return SplitResult{Chunks: chunks}, err
+			}),
+			Extensions: []string{".parquet", ".pq"},
+			MIMETypes:  []string{"application/vnd.apache.parquet", "application/x-parquet"},
+			Sniff:      func(h []byte) bool { return bytes.HasPrefix(h, []byte("PAR1")) },
+		},
+		{
+			Splitter: NewSplitter("xlsx", Capabilities{Header: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, err := SplitXLSXToChunks(r, o.Chunking, o.Sheet)
+				return SplitResult{Chunks: chunks}, err
+			}),
+			Extensions: []string{".xlsx", ".xlsm"},
+			MIMETypes: []string{
+				"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+				"application/vnd.ms-excel.sheet.macroenabled.12",
+			},
+			Sniff: sniffXLSX,
+		},
+		{
+			Splitter: NewSplitter("avro", Capabilities{EmbeddedSchema: true, Converts: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, stats, err := SplitAvroToChunks(r, o.Chunking, o.Convert)
+				return SplitResult{Chunks: chunks, Records: stats}, err
+			}),
+			Extensions: []string{".avro"},
+			MIMETypes:  []string{"application/avro", "avro/binary"},
+			Sniff:      func(h []byte) bool { return bytes.HasPrefix(h, []byte("Obj\x01")) },
+		},
+		{
+			Splitter: NewSplitter("protobuf", Capabilities{RequiresSchema: true, Converts: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				if o.Descriptor == nil {
+					return SplitResult{}, fmt.Errorf("protobuf input requires a message descriptor")
+				}
+				chunks, stats, err := SplitProtobufToChunks(r, o.Chunking, o.Descriptor, o.Convert)
+				return SplitResult{Chunks: chunks, Records: stats}, err
+			}),
+			Extensions: []string{".pb", ".binpb", ".protobuf"},
+			MIMETypes:  []string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"},
+		},
+		{
+			Splitter: NewSplitter("lineprotocol", Capabilities{Converts: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				precision, err := ParsePrecision(o.Precision)
+				if err != nil {
+					return SplitResult{}, err
+				}
+				chunks, stats, err := SplitLineProtocolToChunks(r, o.Chunking, precision, o.Convert)
+				return SplitResult{Chunks: chunks, Records: stats}, err
+			}),
+			Extensions: []string{".lp", ".line", ".influx"},
+		},
+		{
+			Splitter: NewSplitter("senml", Capabilities{Converts: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, stats, err := SplitSenMLJSONToChunks(r, o.Chunking, o.Convert)
+				return SplitResult{Chunks: chunks, Records: stats}, err
+			}),
+			Extensions: []string{".senml"},
+			MIMETypes:  []string{"application/senml+json"},
+		},
+		{
+			Splitter: NewSplitter("senmlc", Capabilities{Converts: true}, func(r io.Reader, o SplitOptions) (SplitResult, error) {
+				chunks, stats, err := SplitSenMLCBORToChunks(r, o.Chunking, o.Convert)
+				return SplitResult{Chunks: chunks, Records: stats}, err
+			}),
+			Extensions: []string{".senmlc"},
+			MIMETypes:  []string{"application/senml+cbor"},
+			Sniff:      sniffSenMLCBOR,
+		},
+	}
+}
+
+// firstJSONByte пропускает BOM и пробельные символы.
+func firstJSONByte(h []byte) byte {
+	h = bytes.TrimLeft(bytes.TrimPrefix(h, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(h) == 0 {
+		return 0
+	}
+	return h[0]
+}
+
+// sniffXLSX отличает книгу Excel от обычного zip: первой записью
+// OOXML-пакета пишется [Content_Types].xml.
+func sniffXLSX(h []byte) bool {
+	return bytes.HasPrefix(h, zipMagic) && bytes.Contains(h, []byte("[Content_Types].xml"))
+}
+
+// sniffSenMLCBOR — CBOR-массив (major type 4), первый элемент которого — map
+// (major type 5).
+func sniffSenMLCBOR(h []byte) bool {
+	if len(h) < 2 || h[0]>>5 != 4 {
+		return false
+	}
+	first := 1
+	switch h[0] & 0x1f {
+	case 24:
+		first = 2
+	case 25:
+		first = 3
+	case 26:
+		first = 5
+	}
+	return len(h) > first && h[first]>>5 == 5
+}
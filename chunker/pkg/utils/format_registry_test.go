@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestFormatRegistryResolve(t *testing.T) {
+	reg := DefaultFormatRegistry()
+
+	pack, err := cbor.Marshal([]map[int]any{{0: "temp", 2: 1.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		resolve func() (Splitter, bool)
+		want    string
+	}{
+		{"ext csv", func() (Splitter, bool) { return reg.ByExtension("a/b/Readings.CSV") }, "csv"},
+		{"ext jsonl", func() (Splitter, bool) { return reg.ByExtension("x.jsonl") }, "ndjson"},
+		{"ext line protocol", func() (Splitter, bool) { return reg.ByExtension("x.lp") }, "lineprotocol"},
+		{"mime with params", func() (Splitter, bool) { return reg.ByMIME("text/csv; charset=utf-8") }, "csv"},
+		{"mime senml", func() (Splitter, bool) { return reg.ByMIME("application/senml+cbor") }, "senmlc"},
+		{"lookup by name", func() (Splitter, bool) { return reg.Lookup(" Parquet ") }, "parquet"},
+		{"lookup by mime", func() (Splitter, bool) { return reg.Lookup("application/x-ndjson") }, "ndjson"},
+		{"sniff json", func() (Splitter, bool) { return reg.Sniff([]byte("\xef\xbb\xbf\n  [{\"a\":1}]")) }, "json"},
+		{"sniff ndjson", func() (Splitter, bool) { return reg.Sniff([]byte("{\"a\":1}\n")) }, "ndjson"},
+		{"sniff parquet", func() (Splitter, bool) { return reg.Sniff(parquetSample(t, 1, 1)) }, "parquet"},
+		{"sniff xlsx", func() (Splitter, bool) { return reg.Sniff(xlsxSample(t)) }, "xlsx"},
+		{"sniff senml cbor", func() (Splitter, bool) { return reg.Sniff(pack) }, "senmlc"},
+	}
+	for _, c := range cases {
+		s, ok := c.resolve()
+		if !ok {
+			t.Errorf("%s: not resolved", c.name)
+			continue
+		}
+		if s.Name() != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, s.Name(), c.want)
+		}
+	}
+
+	if _, ok := reg.ByExtension("notes.txt"); ok {
+		t.Error("txt must not resolve")
+	}
+	if _, ok := reg.Sniff(buildZip(t)); ok {
+		t.Error("plain zip must not be sniffed as a data format")
+	}
+	if _, ok := reg.Lookup("yaml"); ok {
+		t.Error("unknown format must not resolve")
+	}
+}
+
+func TestFormatRegistryCapabilities(t *testing.T) {
+	reg := DefaultFormatRegistry()
+
+	for name, want := range map[string]Capabilities{
+		"csv":      {Header: true},
+		"parquet":  {EmbeddedSchema: true},
+		"avro":     {EmbeddedSchema: true, Converts: true},
+		"protobuf": {RequiresSchema: true, Converts: true},
+	} {
+		s, ok := reg.Lookup(name)
+		if !ok {
+			t.Fatalf("%s is not registered", name)
+		}
+		if s.Capabilities() != want {
+			t.Errorf("%s: capabilities %+v, want %+v", name, s.Capabilities(), want)
+		}
+	}
+
+	for _, name := range reg.Names() {
+		if s, _ := reg.Lookup(name); s.Capabilities().Streaming {
+			t.Errorf("%s claims streaming but returns all chunks at once", name)
+		}
+	}
+
+	want := []string{"avro", "csv", "json", "lineprotocol", "ndjson", "parquet", "protobuf", "senml", "senmlc", "xlsx"}
+	if got := reg.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+}
+
+func TestFormatRegistryRejectsConflicts(t *testing.T) {
+	split := func(io.Reader, SplitOptions) (SplitResult, error) { return SplitResult{}, nil }
+	reg := NewFormatRegistry()
+	if err := reg.Register(Format{Splitter: NewSplitter("a", Capabilities{}, split), Extensions: []string{".a"}, MIMETypes: []string{"x/a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, f := range map[string]Format{
+		"same name":   {Splitter: NewSplitter("A", Capabilities{}, split)},
+		"same ext":    {Splitter: NewSplitter("b", Capabilities{}, split), Extensions: []string{".A"}},
+		"same mime":   {Splitter: NewSplitter("c", Capabilities{}, split), MIMETypes: []string{"X/A"}},
+		"ext no dot":  {Splitter: NewSplitter("d", Capabilities{}, split), Extensions: []string{"d"}},
+		"no splitter": {},
+	} {
+		if err := reg.Register(f); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestBuiltinSplitters прогоняет каждый текстовый формат через реестр,
+// как это делает ChunkerUseCase.
+func TestBuiltinSplitters(t *testing.T) {
+	reg := DefaultFormatRegistry()
+	opts := SplitOptions{Chunking: ChunkPolicy{MaxRecords: 2}, Precision: "s", Convert: passRecord}
+
+	cases := []struct {
+		format, input   string
+		chunks, records int
+	}{
+		{"csv", "sensor_id,temperature\ns1,20\ns2,21\n", 2, 0},
+		{"json", `[{"a":1},{"a":2},{"a":3}]`, 2, 0},
+		{"ndjson", "{\"a\":1}\nbad\n{\"a\":2}\n", 1, 0},
+		{"lineprotocol", "m,sensor_id=s1 t=1 1735787045\nm,sensor_id=s2 t=2 1735787045\n", 1, 2},
+		{"senml", `[{"bn":"s1:","bt":1.7e9,"n":"t","v":1},{"n":"t","v":2,"t":60}]`, 1, 2},
+	}
+	for _, c := range cases {
+		s, ok := reg.Lookup(c.format)
+		if !ok {
+			t.Fatalf("%s is not registered", c.format)
+		}
+		res, err := s.Split(strings.NewReader(c.input), opts)
+		if err != nil {
+			t.Errorf("%s: %v", c.format, err)
+			continue
+		}
+		if len(res.Chunks) != c.chunks || res.Records.Records != c.records {
+			t.Errorf("%s: got %d chunks, %d records", c.format, len(res.Chunks), res.Records.Records)
+		}
+	}
+
+	s, _ := reg.Lookup("ndjson")
+	res, _ := s.Split(strings.NewReader("{\"a\":1}\nbad\n"), opts)
+	if res.Lines.Malformed != 1 {
+		t.Errorf("ndjson stats not propagated: %+v", res.Lines)
+	}
+
+	s, _ = reg.Lookup("protobuf")
+	if _, err := s.Split(bytes.NewReader(nil), opts); err == nil {
+		t.Error("protobuf without descriptor must fail")
+	}
+}
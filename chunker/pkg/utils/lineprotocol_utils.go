@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MeasurementTag — ключ, под которым имя измерения InfluxDB попадает в теги.
+const MeasurementTag = "_measurement"
+
+// ParsePrecision разбирает точность отметок времени line protocol
+// (как параметр precision в InfluxDB API). Пусто = наносекунды.
+func ParsePrecision(name string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "ns", "n":
+		return time.Nanosecond, nil
+	case "us", "u", "µs":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("unknown precision: %s", name)
+	}
+}
+
+// SplitLineProtocolToChunks читает InfluxDB line protocol. Каждая точка
+// превращается в запись: поля — на верхнем уровне, теги (и имя измерения
+// под MeasurementTag) — в "tags", тег sensor_id поднимается в "sensor_id".
+// Строки с синтаксическими ошибками пропускаются и учитываются в статистике.
+func SplitLineProtocolToChunks(r io.Reader, policy ChunkPolicy, precision time.Duration, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	if precision <= 0 {
+		precision = time.Nanosecond
+	}
+	br := bufio.NewReader(r)
+
+	next := func() (map[string]any, bool, error) {
+		for {
+			line, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, false, err
+			}
+			if len(line) == 0 && err == io.EOF {
+				return nil, false, nil
+			}
+
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 || line[0] == '#' {
+				if err == io.EOF {
+					return nil, false, nil
+				}
+				continue
+			}
+
+			p, perr := parseLineProtocol(string(line))
+			if perr != nil {
+				return nil, true, malformedRecord("%v", perr)
+			}
+			if p.timestamp == nil {
+				return nil, true, malformedRecord("missing timestamp")
+			}
+
+			rec := make(map[string]any, len(p.fields)+3)
+			for k, v := range p.fields {
+				rec[k] = v
+			}
+			tags := make(map[string]any, len(p.tags)+1)
+			tags[MeasurementTag] = p.measurement
+			for k, v := range p.tags {
+				if k == "sensor_id" {
+					rec["sensor_id"] = v
+					continue
+				}
+				tags[k] = v
+			}
+			rec["tags"] = tags
+			rec["timestamp"] = time.Unix(0, *p.timestamp*int64(precision)).UTC()
+
+			return rec, true, nil
+		}
+	}
+
+	return splitRecordsToChunks(next, policy, convert)
+}
+
+type linePoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]any
+	timestamp   *int64
+}
+
+// lineScanner разбирает одну строку line protocol с учётом экранирования.
+type lineScanner struct {
+	s   string
+	pos int
+}
+
+func (l *lineScanner) eof() bool { return l.pos >= len(l.s) }
+
+// token читает до первого неэкранированного символа из stops.
+func (l *lineScanner) token(stops string) string {
+	var b strings.Builder
+	for l.pos < len(l.s) {
+		c := l.s[l.pos]
+		if c == '\\' && l.pos+1 < len(l.s) && strings.IndexByte(stops+"\\", l.s[l.pos+1]) >= 0 {
+			b.WriteByte(l.s[l.pos+1])
+			l.pos += 2
+			continue
+		}
+		if strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return b.String()
+}
+
+func (l *lineScanner) skipSpaces() {
+	for l.pos < len(l.s) && (l.s[l.pos] == ' ' || l.s[l.pos] == '\t') {
+		l.pos++
+	}
+}
+
+func (l *lineScanner) expect(c byte) bool {
+	if l.pos < len(l.s) && l.s[l.pos] == c {
+		l.pos++
+		return true
+	}
+	return false
+}
+
+func parseLineProtocol(line string) (*linePoint, error) {
+	l := &lineScanner{s: line}
+	p := &linePoint{tags: map[string]string{}, fields: map[string]any{}}
+
+	p.measurement = l.token(", ")
+	if p.measurement == "" {
+		return nil, errors.New("empty measurement")
+	}
+
+	for l.expect(',') {
+		key := l.token("=, ")
+		if key == "" || !l.expect('=') {
+			return nil, fmt.Errorf("invalid tag at column %d", l.pos+1)
+		}
+		val := l.token(", ")
+		if val == "" {
+			return nil, fmt.Errorf("empty value for tag %s", key)
+		}
+		p.tags[key] = val
+	}
+
+	l.skipSpaces()
+	if l.eof() {
+		return nil, errors.New("missing fields")
+	}
+
+	for {
+		key := l.token("=, ")
+		if key == "" || !l.expect('=') {
+			return nil, fmt.Errorf("invalid field at column %d", l.pos+1)
+		}
+		val, err := l.fieldValue()
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", key, err)
+		}
+		p.fields[key] = val
+		if !l.expect(',') {
+			break
+		}
+	}
+
+	l.skipSpaces()
+	if !l.eof() {
+		raw := l.token(" ")
+		ts, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", raw)
+		}
+		p.timestamp = &ts
+		l.skipSpaces()
+		if !l.eof() {
+			return nil, fmt.Errorf("unexpected data at column %d", l.pos+1)
+		}
+	}
+
+	return p, nil
+}
+
+func (l *lineScanner) fieldValue() (any, error) {
+	if l.expect('"') {
+		var b strings.Builder
+		for l.pos < len(l.s) {
+			c := l.s[l.pos]
+			if c == '\\' && l.pos+1 < len(l.s) && (l.s[l.pos+1] == '"' || l.s[l.pos+1] == '\\') {
+				b.WriteByte(l.s[l.pos+1])
+				l.pos += 2
+				continue
+			}
+			l.pos++
+			if c == '"' {
+				return b.String(), nil
+			}
+			b.WriteByte(c)
+		}
+		return nil, errors.New("unterminated string")
+	}
+
+	raw := l.token(", ")
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	case "":
+		return nil, errors.New("empty value")
+	}
+
+	switch raw[len(raw)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", raw)
+		}
+		return v, nil
+	case 'u':
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		return v, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid float %q", raw)
+	}
+	return v, nil
+}
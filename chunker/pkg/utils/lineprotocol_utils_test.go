@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// passRecord — конвертер для тестов, оставляющий запись как есть.
+func passRecord(rec map[string]any) (any, error) { return rec, nil }
+
+func TestParseLineProtocol(t *testing.T) {
+	p, err := parseLineProtocol(`weather\ station,sensor_id=s1,room=lab\,1 temperature=21.5,count=3i,ok=t,note="say \"hi\", ok",big=7u 1735787045000000000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.measurement != "weather station" {
+		t.Errorf("measurement = %q", p.measurement)
+	}
+	if !reflect.DeepEqual(p.tags, map[string]string{"sensor_id": "s1", "room": "lab,1"}) {
+		t.Errorf("tags = %v", p.tags)
+	}
+	want := map[string]any{"temperature": 21.5, "count": int64(3), "ok": true, "note": `say "hi", ok`, "big": uint64(7)}
+	if !reflect.DeepEqual(p.fields, want) {
+		t.Errorf("fields = %#v", p.fields)
+	}
+	if p.timestamp == nil || *p.timestamp != 1735787045000000000 {
+		t.Errorf("timestamp = %v", p.timestamp)
+	}
+}
+
+func TestParseLineProtocolErrors(t *testing.T) {
+	for _, line := range []string{
+		`m`,
+		`m,tag temperature=1`,
+		`m temperature=`,
+		`m temperature="open`,
+		`m temperature=abc`,
+		`m temperature=1 notatime`,
+		`m temperature=1 1 extra`,
+	} {
+		if _, err := parseLineProtocol(line); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
+
+func TestSplitLineProtocolToChunks(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"env,sensor_id=s1 temperature=20.5,humidity=40 1735787045",
+		"",
+		"env,sensor_id=s2 temperature=oops 1735787045",
+		"env,sensor_id=s3 temperature=22",
+		"env,sensor_id=s4,floor=2 temperature=23,co2=410i 1735787105",
+	}, "\n")
+
+	chunks, stats, err := SplitLineProtocolToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 10}, time.Second, passRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 2 || stats.Malformed != 2 || len(chunks) != 1 {
+		t.Fatalf("got %d chunks, stats %+v", len(chunks), stats)
+	}
+
+	var recs []map[string]any
+	if err := json.Unmarshal(chunks[0], &recs); err != nil {
+		t.Fatal(err)
+	}
+	if recs[0]["sensor_id"] != "s1" || recs[0]["timestamp"] != "2025-01-02T03:04:05Z" || recs[0]["humidity"] != 40.0 {
+		t.Errorf("unexpected first record: %v", recs[0])
+	}
+	tags := recs[1]["tags"].(map[string]any)
+	if tags[MeasurementTag] != "env" || tags["floor"] != "2" || recs[1]["co2"] != 410.0 {
+		t.Errorf("unexpected second record: %v", recs[1])
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	cases := map[string]time.Duration{"": time.Nanosecond, "us": time.Microsecond, "MS": time.Millisecond, "s": time.Second}
+	for in, want := range cases {
+		if got, err := ParsePrecision(in); err != nil || got != want {
+			t.Errorf("ParsePrecision(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParsePrecision("h"); err == nil {
+		t.Error("expected error for unknown precision")
+	}
+}
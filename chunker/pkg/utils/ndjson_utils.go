@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// MaxMalformedSamples ограничивает число битых строк, сохраняемых в отчёте.
+const MaxMalformedSamples = 20
+
+type MalformedLine struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+type NDJSONStats struct {
+	Lines     int
+	Records   int
+	Malformed int
+	Samples   []MalformedLine
+}
+
+// SplitNDJSONToChunks построчно читает NDJSON / JSON Lines и собирает
+// валидные объекты в чанки-массивы того же вида, что и SplitJSONToChunks.
+// Пустые строки игнорируются, битые пропускаются и учитываются в статистике.
+// Строки читаются по одной, но чанки возвращаются все сразу: объём входа
+// ограничивает вызывающий (LimitSplitInput).
+func SplitNDJSONToChunks(r io.Reader, policy ChunkPolicy) ([][]byte, NDJSONStats, error) {
+	var stats NDJSONStats
+	if err := policy.Validate(); err != nil {
+		return nil, stats, err
+	}
+
+	br := bufio.NewReader(r)
+	chunker := &arrayChunker{policy: policy}
+
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, stats, readErr
+		}
+
+		if len(line) > 0 {
+			stats.Lines++
+		}
+		if stats.Lines == 1 {
+			line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+		}
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			if reason := validateNDJSONLine(line); reason != "" {
+				stats.Malformed++
+				if len(stats.Samples) < MaxMalformedSamples {
+					stats.Samples = append(stats.Samples, MalformedLine{Line: stats.Lines, Reason: reason})
+				}
+			} else {
+				chunker.add(line)
+				stats.Records++
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return chunker.result(), stats, nil
+}
+
+func validateNDJSONLine(line []byte) string {
+	if !json.Valid(line) {
+		var v any
+		if err := json.Unmarshal(line, &v); err != nil {
+			return err.Error()
+		}
+		return "invalid json"
+	}
+	if line[0] != '{' {
+		return "record is not a json object"
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitNDJSONToChunksSkipsMalformedLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"sensor_id":"s1","temperature":20.5}`,
+		``,
+		`{"sensor_id":"s2",`,
+		`[1,2]`,
+		`{"sensor_id":"s3","temperature":21}`,
+		`{"sensor_id":"s4","temperature":22}`,
+	}, "\r\n")
+
+	chunks, stats, err := SplitNDJSONToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`[{"sensor_id":"s1","temperature":20.5},{"sensor_id":"s3","temperature":21}]`,
+		`[{"sensor_id":"s4","temperature":22}]`,
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for i := range want {
+		if string(chunks[i]) != want[i] {
+			t.Errorf("chunk %d = %s, want %s", i, chunks[i], want[i])
+		}
+	}
+
+	if stats.Lines != 6 || stats.Records != 3 || stats.Malformed != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(stats.Samples) != 2 || stats.Samples[0].Line != 3 || stats.Samples[1].Line != 4 {
+		t.Fatalf("unexpected samples: %+v", stats.Samples)
+	}
+}
+
+func TestSplitNDJSONToChunksLongLines(t *testing.T) {
+	long := `{"payload":"` + strings.Repeat("x", 1<<20) + `"}`
+
+	chunks, stats, err := SplitNDJSONToChunks(strings.NewReader("\xef\xbb\xbf"+long+"\n"+long), ChunkPolicy{MaxRecords: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || stats.Records != 2 || stats.Malformed != 0 {
+		t.Fatalf("got %d chunks, stats %+v", len(chunks), stats)
+	}
+}
+
+func FuzzSplitNDJSONToChunks(f *testing.F) {
+	f.Add([]byte("{\"a\":1}\n{\"b\":2}\nnot json\n"), 1)
+	f.Add([]byte("\n\n{}\r\n"), 3)
+
+	f.Fuzz(func(t *testing.T, input []byte, chunkSize int) {
+		if chunkSize <= 0 || chunkSize > 1<<10 {
+			t.Skip()
+		}
+
+		chunks, stats, err := SplitNDJSONToChunks(strings.NewReader(string(input)), ChunkPolicy{MaxRecords: chunkSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items := joinJSONChunks(t, chunks)
+		if len(items) != stats.Records {
+			t.Fatalf("chunks hold %d records, stats report %d", len(items), stats.Records)
+		}
+		if stats.Records+stats.Malformed > stats.Lines {
+			t.Fatalf("inconsistent stats: %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+type ParquetSplitMode string
+
+const (
+	// ParquetSplitRows режет файл по политике чанков независимо от row group.
+	ParquetSplitRows ParquetSplitMode = "rows"
+	// ParquetSplitRowGroups превращает каждую row group в отдельный чанк.
+	ParquetSplitRowGroups ParquetSplitMode = "rowgroups"
+)
+
+func ParseParquetSplitMode(name string) (ParquetSplitMode, error) {
+	switch m := ParquetSplitMode(strings.ToLower(strings.TrimSpace(name))); m {
+	case "", ParquetSplitRows:
+		return ParquetSplitRows, nil
+	case ParquetSplitRowGroups, "rowgroup", "row_groups":
+		return ParquetSplitRowGroups, nil
+	default:
+		return "", fmt.Errorf("unknown parquet split mode: %s", name)
+	}
+}
+
+// SplitParquetToChunks читает Parquet-файл и собирает строки в JSON-массивы
+// того же вида, что и SplitJSONToChunks. Колонки с логическим типом
+// TIMESTAMP выводятся в RFC 3339, а не сырым int64.
+//
+// Файл сбрасывается во временный (не больше MaxSpoolSize), row group
+// читаются по очереди, но чанки возвращаются все сразу, поэтому
+// несжатый размер данных из метаданных файла сравнивается с
+// MaxSplitInputSize.
+func SplitParquetToChunks(r io.Reader, policy ChunkPolicy, mode ParquetSplitMode) ([][]byte, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	tmp, size, err := spoolToTempFile(r, "chunker-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("spool parquet file: %w", err)
+	}
+	defer removeTempFile(tmp)
+
+	f, err := parquet.OpenFile(tmp, size)
+	if err != nil {
+		return nil, fmt.Errorf("open parquet file: %w", err)
+	}
+	var unpacked int64
+	for _, rg := range f.Metadata().RowGroups {
+		unpacked += rg.TotalByteSize
+	}
+	if unpacked > MaxSplitInputSize {
+		return nil, ErrInputTooLarge
+	}
+	timestamps := parquetTimestampColumns(f.Schema())
+
+	// в режиме row group чанк закрывается только на границе группы
+	if mode == ParquetSplitRowGroups {
+		policy = ChunkPolicy{}
+	}
+	chunker := &arrayChunker{policy: policy}
+	var row bytes.Buffer
+	enc := json.NewEncoder(&row)
+	enc.SetEscapeHTML(false)
+
+	for _, rg := range f.RowGroups() {
+		rows := parquet.NewRowGroupReader(rg)
+		for {
+			values := map[string]any{}
+			if err := rows.Read(&values); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("read parquet row: %w", err)
+			}
+			for col, unit := range timestamps {
+				if v, ok := values[col].(int64); ok {
+					values[col] = time.Unix(0, v*int64(unit)).UTC().Format(time.RFC3339Nano)
+				}
+			}
+
+			row.Reset()
+			if err := enc.Encode(values); err != nil {
+				return nil, err
+			}
+			// хвостовой перевод строки от Encoder
+			chunker.add(row.Bytes()[:row.Len()-1])
+		}
+
+		if mode == ParquetSplitRowGroups {
+			chunker.flush()
+		}
+	}
+
+	return chunker.result(), nil
+}
+
+func parquetTimestampColumns(schema *parquet.Schema) map[string]time.Duration {
+	cols := map[string]time.Duration{}
+	for _, field := range schema.Fields() {
+		if !field.Leaf() {
+			continue
+		}
+		lt := field.Type().LogicalType()
+		if lt == nil {
+			continue
+		}
+		ts, ok := lt.Value.(*format.TimestampType)
+		if !ok || ts.Unit.Value == nil {
+			continue
+		}
+		cols[field.Name()] = ts.Unit.Value.Duration()
+	}
+	return cols
+}
+
+// ParquetFileWriter пишет Parquet во временный файл: каждый вызов Write —
+// отдельная row group, так что в памяти держится только текущая порция
+// строк. Схема берётся из тегов `parquet` типа T.
+type ParquetFileWriter[T any] struct {
+	f *os.File
+	w *parquet.GenericWriter[T]
+}
+
+func NewParquetFileWriter[T any]() (*ParquetFileWriter[T], error) {
+	f, err := os.CreateTemp("", "chunker-result-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	return &ParquetFileWriter[T]{f: f, w: parquet.NewGenericWriter[T](f, parquet.Compression(&parquet.Zstd))}, nil
+}
+
+// Write дописывает строки отдельной row group.
+func (p *ParquetFileWriter[T]) Write(rows []T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if _, err := p.w.Write(rows); err != nil {
+		return fmt.Errorf("write parquet: %w", err)
+	}
+	if err := p.w.Flush(); err != nil {
+		return fmt.Errorf("write parquet: %w", err)
+	}
+	return nil
+}
+
+// Finish дописывает метаданные и возвращает готовый файл с начала и его
+// размер. Файл остаётся во владении writer: после выгрузки нужен Remove.
+func (p *ParquetFileWriter[T]) Finish() (io.Reader, int64, error) {
+	if err := p.w.Close(); err != nil {
+		return nil, 0, fmt.Errorf("write parquet: %w", err)
+	}
+	size, err := p.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	return p.f, size, nil
+}
+
+// Remove удаляет временный файл; вызывается и после ошибки.
+func (p *ParquetFileWriter[T]) Remove() {
+	removeTempFile(p.f)
+}
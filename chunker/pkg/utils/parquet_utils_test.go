@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetReading struct {
+	Timestamp   time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	SensorID    string    `parquet:"sensor_id"`
+	Temperature float64   `parquet:"temperature"`
+}
+
+func parquetSample(t *testing.T, rows, rowsPerGroup int) []byte {
+	t.Helper()
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[parquetReading](&buf, parquet.MaxRowsPerRowGroup(int64(rowsPerGroup)))
+	for i := 0; i < rows; i++ {
+		r := parquetReading{Timestamp: base.Add(time.Duration(i) * time.Minute), SensorID: "s1", Temperature: float64(i)}
+		if _, err := w.Write([]parquetReading{r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func chunkLens(t *testing.T, chunks [][]byte) []int {
+	t.Helper()
+	lens := make([]int, len(chunks))
+	for i, c := range chunks {
+		var items []map[string]any
+		if err := json.Unmarshal(c, &items); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		lens[i] = len(items)
+	}
+	return lens
+}
+
+func TestSplitParquetToChunks(t *testing.T) {
+	data := parquetSample(t, 10, 4)
+
+	chunks, err := SplitParquetToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 3}, ParquetSplitRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkLens(t, chunks); len(got) != 4 || got[0] != 3 || got[3] != 1 {
+		t.Fatalf("rows mode chunk sizes = %v", got)
+	}
+
+	chunks, err = SplitParquetToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 3}, ParquetSplitRowGroups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkLens(t, chunks); len(got) != 3 || got[0] != 4 || got[1] != 4 || got[2] != 2 {
+		t.Fatalf("row group mode chunk sizes = %v", got)
+	}
+
+	var first []map[string]any
+	if err := json.Unmarshal(chunks[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first[1]["timestamp"] != "2025-01-02T03:05:05Z" || first[1]["sensor_id"] != "s1" || first[1]["temperature"] != 1.0 {
+		t.Fatalf("unexpected row: %v", first[1])
+	}
+}
+
+func TestSplitParquetToChunksSizeLimit(t *testing.T) {
+	defer func(limit int64) { MaxSplitInputSize = limit }(MaxSplitInputSize)
+	data := parquetSample(t, 10, 4)
+
+	MaxSplitInputSize = 16
+	if _, err := SplitParquetToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 3}, ParquetSplitRows); !errors.Is(err, ErrInputTooLarge) {
+		t.Fatalf("err = %v, want ErrInputTooLarge", err)
+	}
+}
+
+func TestParquetFileWriterRoundTrip(t *testing.T) {
+	rows := []parquetReading{
+		{Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), SensorID: "s1", Temperature: 20.5},
+		{Timestamp: time.Date(2025, 1, 2, 3, 5, 5, 0, time.UTC), SensorID: "s2", Temperature: -3},
+	}
+
+	w, err := NewParquetFileWriter[parquetReading]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Remove()
+	for _, r := range rows {
+		if err := w.Write([]parquetReading{r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, size, err := w.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || int64(len(data)) != size {
+		t.Fatalf("read %d of %d bytes: %v", len(data), size, err)
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(data), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.RowGroups()); n != 2 {
+		t.Errorf("row groups: %d, want one per Write", n)
+	}
+	got, err := parquet.Read[parquetReading](bytes.NewReader(data), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Timestamp.Equal(rows[0].Timestamp) || got[1].SensorID != "s2" || got[1].Temperature != -3 {
+		t.Fatalf("round trip mismatch: %+v", got)
+	}
+}
+
+func TestParseParquetSplitMode(t *testing.T) {
+	if m, err := ParseParquetSplitMode(""); err != nil || m != ParquetSplitRows {
+		t.Errorf("default mode = %q, %v", m, err)
+	}
+	if m, err := ParseParquetSplitMode("RowGroups"); err != nil || m != ParquetSplitRowGroups {
+		t.Errorf("rowgroups mode = %q, %v", m, err)
+	}
+	if _, err := ParseParquetSplitMode("pages"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
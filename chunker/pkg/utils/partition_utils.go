@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// PartitionedChunk — все записи задания, попавшие в один раздел.
+type PartitionedChunk struct {
+	Key   string
+	Chunk []byte
+}
+
+// PartitionKeyFunc возвращает ключ раздела записи. Ошибка означает, что
+// запись нельзя отнести ни к одному разделу: она пропускается.
+type PartitionKeyFunc func(rec map[string]any) (string, error)
+
+// Partitioner раскладывает записи всех источников задания по разделам.
+// Раздел становится ровно одним чанком независимо от политики нарезки:
+// статистика датчика или окна считается по нему без слияния чанков.
+type Partitioner struct {
+	keyOf      PartitionKeyFunc
+	partitions map[string]*arrayChunker
+}
+
+func NewPartitioner(keyOf PartitionKeyFunc) *Partitioner {
+	return &Partitioner{keyOf: keyOf, partitions: map[string]*arrayChunker{}}
+}
+
+// Add добавляет записи готовых чанков одного источника (CSV или JSON);
+// номера записей в статистике — в пределах источника.
+func (p *Partitioner) Add(chunks [][]byte, header bool) (DecodeStats, error) {
+	var stats DecodeStats
+	next := chunkRecords(chunks, header)
+	for n := 1; ; n++ {
+		rec, ok, err := next()
+		if err != nil {
+			return stats, err
+		}
+		if !ok {
+			return stats, nil
+		}
+
+		var key string
+		var data []byte
+		if rec == nil {
+			err = errors.New("record is not an object")
+		} else {
+			key, err = p.keyOf(rec)
+		}
+		if err == nil {
+			data, err = json.Marshal(rec)
+		}
+		if err != nil {
+			stats.Malformed++
+			if len(stats.Samples) < MaxMalformedSamples {
+				stats.Samples = append(stats.Samples, MalformedLine{Line: n, Reason: err.Error()})
+			}
+			continue
+		}
+
+		c, ok := p.partitions[key]
+		if !ok {
+			// без пределов: раздел не режется
+			c = &arrayChunker{}
+			p.partitions[key] = c
+		}
+		c.add(data)
+		stats.Records++
+	}
+}
+
+// Result отдаёт разделы в порядке ключей, записи внутри раздела — в
+// порядке добавления.
+func (p *Partitioner) Result() []PartitionedChunk {
+	keys := make([]string, 0, len(p.partitions))
+	for k := range p.partitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]PartitionedChunk, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, PartitionedChunk{Key: k, Chunk: p.partitions[k].result()[0]})
+	}
+	return out
+}
+
+// SensorBucket распределяет датчики по n корзинам по хэшу FNV-1a,
+// одинаково для всех заданий.
+func SensorBucket(sensorID string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(sensorID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// TimeWindow возвращает границы окна длины d, в которое попадает t.
+// Окна выровнены по UTC: часовые — по началу часа, суточные — по полуночи.
+func TimeWindow(t time.Time, d time.Duration) (time.Time, time.Time) {
+	start := t.UTC().Truncate(d)
+	return start, start.Add(d)
+}
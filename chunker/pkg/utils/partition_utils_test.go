@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func keyBySensor(rec map[string]any) (string, error) {
+	id, _ := rec["sensor_id"].(string)
+	if id == "" {
+		return "", errors.New("sensor_id is missing")
+	}
+	return id, nil
+}
+
+func TestPartitionerJoinsSources(t *testing.T) {
+	p := NewPartitioner(keyBySensor)
+
+	// первый источник — CSV в нескольких чанках, второй — JSON
+	chunks, err := SplitCSVToChunks(strings.NewReader("sensor_id,temperature\ns2,1\ns1,2\ns2,3\n,4\n"), ChunkPolicy{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := p.Add(chunks, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 3 || stats.Malformed != 1 || stats.Samples[0].Line != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if _, err := p.Add([][]byte{[]byte(`[{"sensor_id":"s1","temperature":5},{"sensor_id":"s2","temperature":6}]`)}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []PartitionedChunk{
+		{Key: "s1", Chunk: []byte(`[{"sensor_id":"s1","temperature":2},{"sensor_id":"s1","temperature":5}]`)},
+		{Key: "s2", Chunk: []byte(`[{"sensor_id":"s2","temperature":1},{"sensor_id":"s2","temperature":3},{"sensor_id":"s2","temperature":6}]`)},
+	}
+	got := p.Result()
+	if len(got) != len(want) {
+		t.Fatalf("got %d partitions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Key != want[i].Key || string(got[i].Chunk) != string(want[i].Chunk) {
+			t.Errorf("partition %d = %s %s, want %s %s", i, got[i].Key, got[i].Chunk, want[i].Key, want[i].Chunk)
+		}
+	}
+}
+
+func TestSensorBucketIsStable(t *testing.T) {
+	for _, id := range []string{"s1", "s2", "boiler-7"} {
+		b := SensorBucket(id, 16)
+		if b < 0 || b >= 16 {
+			t.Fatalf("%s: bucket %d out of range", id, b)
+		}
+		if SensorBucket(id, 16) != b {
+			t.Fatalf("%s: bucket is not stable", id)
+		}
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 14, 35, 10, 0, time.FixedZone("MSK", 3*3600))
+
+	start, end := TimeWindow(ts, time.Hour)
+	if want := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.Add(time.Hour)) {
+		t.Fatalf("hour window: got %v..%v", start, end)
+	}
+	start, _ = TimeWindow(ts, 24*time.Hour)
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Fatalf("day window: got %v", start)
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Размеры страницы A4 и поля в пунктах PDF.
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+)
+
+// PDFDocument — текстовый PDF из заголовков и строк шрифтами Helvetica.
+// Стандартные шрифты PDF не требуют встраивания, но знают только
+// WinAnsi: остальные символы выводятся как «?».
+type PDFDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{}
+}
+
+// Heading добавляет заголовок раздела.
+func (d *PDFDocument) Heading(text string) {
+	d.line("F2", 13, 24, text)
+}
+
+// Text добавляет строку текста; длинные строки обрезаются по ширине
+// страницы.
+func (d *PDFDocument) Text(format string, args ...any) {
+	d.line("F1", 10, 14, fmt.Sprintf(format, args...))
+}
+
+func (d *PDFDocument) line(font string, size, leading float64, text string) {
+	if len(d.pages) == 0 || d.y-leading < pdfMargin {
+		d.pages = append(d.pages, &bytes.Buffer{})
+		d.y = pdfPageHeight - pdfMargin
+	}
+	d.y -= leading
+	// средняя ширина символа Helvetica — около половины кегля
+	if limit := int((pdfPageWidth - 2*pdfMargin) / (size * 0.5)); len(text) > limit {
+		text = text[:limit-3] + "..."
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %d %g Td (%s) Tj ET\n", font, size, pdfMargin, d.y, pdfString(text))
+}
+
+// Bytes собирает документ: каталог, дерево страниц, два шрифта, затем
+// страница и её поток содержимого на каждую страницу, таблица xref.
+func (d *PDFDocument) Bytes() []byte {
+	pages := d.pages
+	if len(pages) == 0 {
+		pages = []*bytes.Buffer{{}}
+	}
+
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfString экранирует строку PDF и заменяет символы вне ASCII.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPDFDocumentLayout(t *testing.T) {
+	doc := NewPDFDocument()
+	doc.Heading("Job report")
+	doc.Text("sensor (s1): %d records, датчик", 150)
+	for i := 0; i < 60; i++ {
+		doc.Text("line %d", i)
+	}
+	data := doc.Bytes()
+
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q...", data[:20])
+	}
+	if !bytes.Contains(data, []byte("(sensor \\(s1\\): 150 records, ??????) Tj")) {
+		t.Error("text is not escaped")
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) {
+		t.Error("expected the text to overflow onto a second page")
+	}
+
+	// каждая запись xref указывает на начало своего объекта
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	if len(entries) != 8 {
+		t.Fatalf("xref has %d objects, want 8", len(entries))
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, data[off:off+10])
+		}
+	}
+}
+
+func TestPDFDocumentTruncatesLongLines(t *testing.T) {
+	doc := NewPDFDocument()
+	doc.Text("%s", bytes.Repeat([]byte("x"), 500))
+	if data := doc.Bytes(); bytes.Contains(data, bytes.Repeat([]byte("x"), 200)) || !bytes.Contains(data, []byte("...) Tj")) {
+		t.Error("long line was not truncated")
+	}
+}
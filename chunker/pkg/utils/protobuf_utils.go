@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxProtobufMessageSize защищает от мусорного varint-префикса длины.
+const maxProtobufMessageSize = 16 << 20
+
+// ParseMessageDescriptor достаёт описание сообщения из сериализованного
+// FileDescriptorSet (protoc --descriptor_set_out --include_imports).
+func ParseMessageDescriptor(descriptorSet []byte, messageName string) (protoreflect.MessageDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(descriptorSet, &set); err != nil {
+		return nil, fmt.Errorf("parse descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("build descriptor registry: %w", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(messageName))
+	if err != nil {
+		return nil, fmt.Errorf("find message %s: %w", messageName, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", messageName)
+	}
+
+	return md, nil
+}
+
+// SplitProtobufToChunks читает поток сообщений с varint-префиксом длины
+// (protodelim, writeDelimitedTo в Java) и декодирует их по дескриптору md.
+func SplitProtobufToChunks(r io.Reader, policy ChunkPolicy, md protoreflect.MessageDescriptor, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	br := bufio.NewReader(r)
+	opts := protodelim.UnmarshalOptions{MaxSize: maxProtobufMessageSize}
+	toJSON := protojson.MarshalOptions{UseProtoNames: true}
+
+	next := func() (map[string]any, bool, error) {
+		msg := dynamicpb.NewMessage(md)
+		if err := opts.UnmarshalFrom(br, msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+
+		data, err := toJSON.Marshal(msg)
+		if err != nil {
+			return nil, false, err
+		}
+		var rec map[string]any
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, false, err
+		}
+		return rec, true, nil
+	}
+
+	return splitRecordsToChunks(next, policy, convert)
+}
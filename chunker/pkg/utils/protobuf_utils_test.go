@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// readingDescriptorSet описывает
+//
+//	package telemetry; message Reading { string sensor_id = 1; double temperature = 2; int64 ts = 3; }
+func readingDescriptorSet(t *testing.T) []byte {
+	t.Helper()
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("telemetry.proto"),
+		Package: proto.String("telemetry"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Reading"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("sensor_id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("temperature", 2, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE),
+				field("ts", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			},
+		}},
+	}}}
+
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSplitProtobufToChunks(t *testing.T) {
+	md, err := ParseMessageDescriptor(readingDescriptorSet(t), "telemetry.Reading")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, r := range []struct {
+		id   string
+		temp float64
+	}{{"s1", 20.5}, {"", 1}, {"s2", -4}} {
+		msg := dynamicpb.NewMessage(md)
+		msg.Set(md.Fields().ByName("sensor_id"), protoreflect.ValueOfString(r.id))
+		msg.Set(md.Fields().ByName("temperature"), protoreflect.ValueOfFloat64(r.temp))
+		msg.Set(md.Fields().ByName("ts"), protoreflect.ValueOfInt64(1735787045000))
+		if _, err := protodelim.MarshalTo(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chunks, stats, err := SplitProtobufToChunks(&buf, ChunkPolicy{MaxRecords: 10}, md, requireSensorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 2 || stats.Malformed != 1 || len(chunks) != 1 {
+		t.Fatalf("got %d chunks, stats %+v", len(chunks), stats)
+	}
+
+	var items []map[string]any
+	if err := json.Unmarshal(chunks[0], &items); err != nil {
+		t.Fatal(err)
+	}
+	if items[0]["sensor_id"] != "s1" || items[1]["temperature"] != -4.0 {
+		t.Fatalf("unexpected chunk: %s", chunks[0])
+	}
+}
+
+func TestSplitProtobufToChunksTruncatedStream(t *testing.T) {
+	md, err := ParseMessageDescriptor(readingDescriptorSet(t), "telemetry.Reading")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := SplitProtobufToChunks(bytes.NewReader([]byte{0x10, 0x0a, 0x02}), ChunkPolicy{MaxRecords: 10}, md, requireSensorID); err == nil {
+		t.Fatal("expected error for truncated message")
+	}
+}
+
+func TestParseMessageDescriptorUnknownMessage(t *testing.T) {
+	if _, err := ParseMessageDescriptor(readingDescriptorSet(t), "telemetry.Missing"); err == nil {
+		t.Fatal("expected error for unknown message")
+	}
+	if _, err := ParseMessageDescriptor([]byte("garbage"), "telemetry.Reading"); err == nil {
+		t.Fatal("expected error for invalid descriptor set")
+	}
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// QualityRange — правдоподобные значения метрики в канонических единицах.
+type QualityRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// DefaultQualityRanges — рабочие диапазоны типовых датчиков: °C, %, гПа.
+var DefaultQualityRanges = map[string]QualityRange{
+	"temperature": {Min: -60, Max: 85},
+	"humidity":    {Min: 0, Max: 100},
+	"pressure":    {Min: 300, Max: 1100},
+}
+
+// DefaultClockSkewTolerance — насколько отметка времени может опережать
+// время загрузки, прежде чем часы датчика считаются сбитыми.
+const DefaultClockSkewTolerance = 5 * time.Minute
+
+// minPlausibleTime — раньше этой даты отметки ставят датчики с
+// несброшенными часами (отсчёт от эпохи).
+var minPlausibleTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// QualityConfig — параметры оценки качества. Reference — время загрузки
+// файла, с ним сравниваются отметки времени.
+type QualityConfig struct {
+	Ranges        map[string]QualityRange
+	Reference     time.Time
+	SkewTolerance time.Duration
+}
+
+// SensorQuality — качество ряда одного датчика.
+type SensorQuality struct {
+	SensorID string `json:"sensor_id"`
+	Records  int    `json:"records"`
+	// Expected — сколько показаний ожидалось за период при медианном шаге.
+	Expected     int     `json:"expected"`
+	Completeness float64 `json:"completeness"`
+	Duplicates   int     `json:"duplicates"`
+	OutOfOrder   int     `json:"out_of_order"`
+	OutOfRange   int     `json:"out_of_range"`
+	// ClockSkewed — отметки из будущего относительно загрузки или
+	// до 2000 года.
+	ClockSkewed    int     `json:"clock_skewed"`
+	MaxSkewSeconds float64 `json:"max_skew_seconds,omitempty"`
+	ParseFailures  int     `json:"parse_failures"`
+	Score          float64 `json:"score"`
+}
+
+// QualityReport — качество задания: оценка 0–100 и счётчики по всем
+// датчикам. ParseFailures включает записи без датчика.
+type QualityReport struct {
+	Score         float64         `json:"score"`
+	Records       int             `json:"records"`
+	Completeness  float64         `json:"completeness"`
+	Duplicates    int             `json:"duplicates"`
+	OutOfOrder    int             `json:"out_of_order"`
+	OutOfRange    int             `json:"out_of_range"`
+	ClockSkewed   int             `json:"clock_skewed"`
+	ParseFailures int             `json:"parse_failures"`
+	Sensors       []SensorQuality `json:"sensors,omitempty"`
+}
+
+// QualityTracker накапливает показания в порядке поступления.
+type QualityTracker struct {
+	cfg     QualityConfig
+	sensors map[string]*sensorTrack
+	// битые записи, датчик которых не удалось определить
+	orphanFailures int
+}
+
+type sensorTrack struct {
+	q     SensorQuality
+	times []int64
+	last  time.Time
+}
+
+func NewQualityTracker(cfg QualityConfig) *QualityTracker {
+	if cfg.Ranges == nil {
+		cfg.Ranges = DefaultQualityRanges
+	}
+	if cfg.SkewTolerance == 0 {
+		cfg.SkewTolerance = DefaultClockSkewTolerance
+	}
+	return &QualityTracker{cfg: cfg, sensors: map[string]*sensorTrack{}}
+}
+
+func (t *QualityTracker) sensor(id string) *sensorTrack {
+	s := t.sensors[id]
+	if s == nil {
+		s = &sensorTrack{q: SensorQuality{SensorID: id}}
+		t.sensors[id] = s
+	}
+	return s
+}
+
+// Add учитывает показание; metrics — только метрики, которые в нём есть.
+func (t *QualityTracker) Add(sensorID string, ts time.Time, metrics map[string]float64) {
+	s := t.sensor(sensorID)
+	s.q.Records++
+	s.times = append(s.times, ts.UnixNano())
+
+	if ts.Before(s.last) {
+		s.q.OutOfOrder++
+	} else {
+		s.last = ts
+	}
+
+	switch skew := ts.Sub(t.cfg.Reference); {
+	case !t.cfg.Reference.IsZero() && skew > t.cfg.SkewTolerance:
+		s.q.ClockSkewed++
+		s.q.MaxSkewSeconds = math.Max(s.q.MaxSkewSeconds, skew.Seconds())
+	case ts.Before(minPlausibleTime):
+		s.q.ClockSkewed++
+	}
+
+	for metric, v := range metrics {
+		r, ok := t.cfg.Ranges[metric]
+		if !ok || v >= r.Min && v <= r.Max {
+			continue
+		}
+		s.q.OutOfRange++
+	}
+}
+
+// QualityParser собирает показание из записи чанка; sensorID заполняется
+// и при ошибке, если датчик удалось определить.
+type QualityParser func(rec map[string]any) (sensorID string, ts time.Time, metrics map[string]float64, err error)
+
+// AddChunks учитывает все записи готовых чанков (CSV или JSON).
+func (t *QualityTracker) AddChunks(chunks [][]byte, header bool, parse QualityParser) error {
+	next := chunkRecords(chunks, header)
+	for {
+		rec, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if rec == nil {
+			t.AddParseFailure("")
+			continue
+		}
+		sensorID, ts, metrics, err := parse(rec)
+		if err != nil {
+			t.AddParseFailure(sensorID)
+			continue
+		}
+		t.Add(sensorID, ts, metrics)
+	}
+}
+
+// AddParseFailure учитывает запись, из которой не удалось собрать
+// показание; sensorID пуст, если датчик неизвестен.
+func (t *QualityTracker) AddParseFailure(sensorID string) {
+	if sensorID == "" {
+		t.orphanFailures++
+		return
+	}
+	t.sensor(sensorID).q.ParseFailures++
+}
+
+// Report считает оценки. malformed — записи, отброшенные ещё при разборе
+// файла; они снижают оценку задания.
+func (t *QualityTracker) Report(malformed int) QualityReport {
+	rep := QualityReport{ParseFailures: malformed + t.orphanFailures}
+	ids := make([]string, 0, len(t.sensors))
+	for id := range t.sensors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var weighted, expected float64
+	for _, id := range ids {
+		s := t.sensors[id]
+		q := s.q
+		q.Duplicates, q.Expected = timeStats(s.times)
+		q.Completeness = 1
+		if q.Expected > 0 {
+			q.Completeness = round3(math.Min(float64(q.Records-q.Duplicates)/float64(q.Expected), 1))
+		}
+		q.Score = qualityScore(q.Completeness, q.Duplicates+q.OutOfOrder+q.OutOfRange+q.ClockSkewed+q.ParseFailures, q.Records+q.ParseFailures)
+		rep.Sensors = append(rep.Sensors, q)
+
+		rep.Records += q.Records
+		rep.Duplicates += q.Duplicates
+		rep.OutOfOrder += q.OutOfOrder
+		rep.OutOfRange += q.OutOfRange
+		rep.ClockSkewed += q.ClockSkewed
+		rep.ParseFailures += q.ParseFailures
+		weighted += q.Score * float64(q.Records)
+		expected += float64(q.Expected)
+	}
+
+	if rep.Records == 0 {
+		return rep
+	}
+	rep.Completeness = round3(math.Min(float64(rep.Records-rep.Duplicates)/math.Max(expected, 1), 1))
+	parsed := float64(rep.Records) / float64(rep.Records+rep.ParseFailures)
+	rep.Score = round1(weighted / float64(rep.Records) * parsed)
+	return rep
+}
+
+// Summary — копия отчёта без разбивки по датчикам: её отдаёт статус
+// задания.
+func (r *QualityReport) Summary() *QualityReport {
+	if r == nil {
+		return nil
+	}
+	summary := *r
+	summary.Sensors = nil
+	return &summary
+}
+
+// timeStats возвращает число повторов отметок времени и ожидаемое число
+// показаний за период ряда при медианном шаге между соседними отметками.
+func timeStats(times []int64) (duplicates, expected int) {
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	var steps []int64
+	for i := 1; i < len(sorted); i++ {
+		if d := sorted[i] - sorted[i-1]; d == 0 {
+			duplicates++
+		} else {
+			steps = append(steps, d)
+		}
+	}
+	if len(steps) == 0 {
+		return duplicates, len(sorted) - duplicates
+	}
+	slices.Sort(steps)
+	median := steps[len(steps)/2]
+	span := sorted[len(sorted)-1] - sorted[0]
+	return duplicates, int(span/median) + 1
+}
+
+// qualityScore: 40 баллов за полноту ряда, 60 — за долю записей без
+// проблем.
+func qualityScore(completeness float64, issues, records int) float64 {
+	if records == 0 {
+		return 0
+	}
+	clean := 1 - math.Min(float64(issues)/float64(records), 1)
+	return round1(100 * (0.4*completeness + 0.6*clean))
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
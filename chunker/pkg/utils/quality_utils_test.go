@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQualityReportCounters(t *testing.T) {
+	upload := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := NewQualityTracker(QualityConfig{Reference: upload})
+	at := func(min int) time.Time { return upload.Add(time.Duration(min-60) * time.Minute) }
+	temp := func(v float64) map[string]float64 { return map[string]float64{"temperature": v} }
+
+	// минуты 0..9 с шагом в минуту: пропуск 4 и 5, повтор 2, 7 пришла раньше 6
+	for _, m := range []int{0, 1, 2, 2, 3, 7, 6, 8, 9} {
+		tr.Add("s1", at(m), temp(20))
+	}
+	tr.Add("s1", at(10), temp(150))
+	tr.Add("s1", upload.Add(time.Hour), temp(20))
+	tr.AddParseFailure("s1")
+	// настоящие 0 °C и 0 % — значения в диапазоне
+	tr.Add("s2", at(0), map[string]float64{"temperature": 0, "humidity": 0})
+	tr.Add("s2", at(1), temp(21))
+	tr.AddParseFailure("")
+
+	rep := tr.Report(2)
+	s1 := rep.Sensors[0]
+	if s1.SensorID != "s1" || s1.Records != 11 || s1.Duplicates != 1 || s1.OutOfOrder != 1 || s1.OutOfRange != 1 || s1.ClockSkewed != 1 || s1.ParseFailures != 1 {
+		t.Fatalf("s1 = %+v", s1)
+	}
+	if s1.MaxSkewSeconds != 3600 {
+		t.Fatalf("skew = %v", s1.MaxSkewSeconds)
+	}
+	if rep.Sensors[1].Score != 100 {
+		t.Fatalf("s2 = %+v", rep.Sensors[1])
+	}
+	// 4 сбоя разбора на 17 записей снижают оценку задания
+	if rep.Records != 13 || rep.ParseFailures != 4 || rep.Score >= (s1.Score*11+100*2)/13 || rep.Score <= 0 {
+		t.Fatalf("report = %+v", rep)
+	}
+}
+
+func TestQualityCountsZeroOutOfRange(t *testing.T) {
+	tr := NewQualityTracker(QualityConfig{})
+	tr.Add("s1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), map[string]float64{"pressure": 0})
+	if rep := tr.Report(0); rep.OutOfRange != 1 {
+		t.Fatalf("0 hPa is not out of range: %+v", rep)
+	}
+}
+
+func TestQualityChunks(t *testing.T) {
+	tr := NewQualityTracker(QualityConfig{})
+	chunks := [][]byte{[]byte("sensor_id,ts\na,1\nb,bad\n")}
+	err := tr.AddChunks(chunks, true, func(rec map[string]any) (string, time.Time, map[string]float64, error) {
+		id, _ := rec["sensor_id"].(string)
+		n, ok := rec["ts"].(json.Number)
+		if !ok {
+			return id, time.Time{}, nil, fmt.Errorf("bad ts")
+		}
+		sec, _ := n.Int64()
+		return id, time.Date(2024, 1, 1, 0, 0, int(sec), 0, time.UTC), nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := tr.Report(0)
+	if rep.Records != 1 || rep.ParseFailures != 1 || len(rep.Sensors) != 2 {
+		t.Fatalf("report = %+v", rep)
+	}
+}
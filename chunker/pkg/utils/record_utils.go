@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// RecordConverter приводит декодированную запись бинарного формата к модели,
+// которая попадёт в чанк. Ошибка означает битую запись: она пропускается.
+type RecordConverter func(rec map[string]any) (any, error)
+
+type DecodeStats struct {
+	Records   int
+	Malformed int
+	Samples   []MalformedLine // Line — порядковый номер записи
+}
+
+// recordIterator возвращает следующую запись; ok == false — записи кончились.
+// Ошибка типа *recordError означает битую запись, после которой чтение
+// можно продолжить, остальные ошибки прерывают разбор.
+type recordIterator func() (rec map[string]any, ok bool, err error)
+
+type recordError struct {
+	reason string
+}
+
+func (e *recordError) Error() string { return e.reason }
+
+func malformedRecord(format string, args ...any) error {
+	return &recordError{reason: fmt.Sprintf(format, args...)}
+}
+
+// splitRecordsToChunks собирает сконвертированные записи в JSON-массивы
+// того же вида, что и SplitJSONToChunks.
+func splitRecordsToChunks(next recordIterator, policy ChunkPolicy, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	var stats DecodeStats
+	if err := policy.Validate(); err != nil {
+		return nil, stats, err
+	}
+
+	chunker := &arrayChunker{policy: policy}
+
+	for n := 1; ; n++ {
+		rec, ok, err := next()
+		var recErr *recordError
+		if err != nil && !errors.As(err, &recErr) {
+			return nil, stats, fmt.Errorf("record %d: %w", n, err)
+		}
+		if !ok {
+			break
+		}
+
+		var out any
+		var data []byte
+		if err == nil {
+			out, err = convert(rec)
+		}
+		if err == nil {
+			data, err = json.Marshal(out)
+		}
+		if err != nil {
+			stats.Malformed++
+			if len(stats.Samples) < MaxMalformedSamples {
+				stats.Samples = append(stats.Samples, MalformedLine{Line: n, Reason: err.Error()})
+			}
+			continue
+		}
+
+		chunker.add(data)
+		stats.Records++
+	}
+
+	return chunker.result(), stats, nil
+}
+
+// MapChunks прогоняет записи готовых чанков (CSV или JSON) через convert
+// и собирает результат заново в JSON-массивы по политике policy.
+func MapChunks(chunks [][]byte, header bool, policy ChunkPolicy, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	records := chunkRecords(chunks, header)
+	next := func() (map[string]any, bool, error) {
+		rec, ok, err := records()
+		if ok && rec == nil {
+			return nil, true, malformedRecord("record is not an object")
+		}
+		return rec, ok, err
+	}
+	return splitRecordsToChunks(next, policy, convert)
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// renameTemp — конвертер для тестов: переносит temp_f в temperature.
+func renameTemp(rec map[string]any) (any, error) {
+	v, ok := rec["temp_f"]
+	if !ok {
+		return nil, errors.New("missing temp_f")
+	}
+	return map[string]any{"sensor_id": rec["sensor_id"], "temperature": v}, nil
+}
+
+func TestMapChunksCSV(t *testing.T) {
+	chunks, err := SplitCSVToChunks(strings.NewReader("sensor_id,temp_f\ns1,68\ns2,70\ns3,72\n"), ChunkPolicy{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, stats, err := MapChunks(chunks, true, ChunkPolicy{MaxRecords: 2}, renameTemp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 3 || stats.Malformed != 0 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	got := compactJSON(t, joinJSONChunks(t, mapped))
+	want := []string{
+		`{"sensor_id":"s1","temperature":68}`,
+		`{"sensor_id":"s2","temperature":70}`,
+		`{"sensor_id":"s3","temperature":72}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got %q", got)
+	}
+	if len(mapped) != 2 {
+		t.Fatalf("got %d chunks", len(mapped))
+	}
+}
+
+func TestMapChunksJSONCountsMalformed(t *testing.T) {
+	chunks := [][]byte{[]byte(`[{"sensor_id":"s1","temp_f":1},7]`), []byte(`[{"sensor_id":"s2"}]`)}
+
+	mapped, stats, err := MapChunks(chunks, false, ChunkPolicy{MaxRecords: 10}, renameTemp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 1 || stats.Malformed != 2 {
+		t.Fatalf("stats %+v", stats)
+	}
+	if stats.Samples[0].Line != 2 || stats.Samples[1].Reason != "missing temp_f" {
+		t.Errorf("samples %+v", stats.Samples)
+	}
+
+	var out []map[string]any
+	if err := json.Unmarshal(mapped[0], &out); err != nil || len(out) != 1 {
+		t.Fatalf("mapped %s: %v", mapped[0], err)
+	}
+}
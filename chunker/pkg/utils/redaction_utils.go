@@ -0,0 +1,472 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type RedactAction string
+
+const (
+	// RedactDrop удаляет поле (колонку CSV) целиком.
+	RedactDrop RedactAction = "drop"
+	// RedactHash заменяет значение на HMAC-SHA256 с солью тенанта.
+	RedactHash RedactAction = "hash"
+	// RedactTokenize заменяет значение детерминированным токеном того же
+	// вида: цифры на цифры, буквы на буквы того же регистра, разделители
+	// остаются на месте. Токен необратим.
+	RedactTokenize RedactAction = "tokenize"
+	// RedactCoarsen округляет число (координату) до Precision знаков.
+	RedactCoarsen RedactAction = "coarsen"
+)
+
+// MaxRedactPrecision — больше знаков координата уже не огрубляется
+// (1e-8 градуса — около миллиметра).
+const MaxRedactPrecision = 8
+
+// RedactRule — обработка одного поля: колонки CSV или пути в JSON-записи
+// через точку (owner.name, $.location.lat). Массивы на пути обходятся
+// поэлементно.
+type RedactRule struct {
+	Field     string
+	Action    RedactAction
+	Precision int
+}
+
+// RedactionCount — сколько значений поля обработано действием.
+type RedactionCount struct {
+	Field  string
+	Action RedactAction
+	Values int
+}
+
+// Redactor применяет правила к чанкам задания и ведёт счётчики для аудита.
+// Один Redactor обслуживает все источники задания.
+type Redactor struct {
+	rules  []RedactRule
+	paths  [][]string
+	salt   []byte
+	counts map[RedactRule]int
+}
+
+// NewRedactor проверяет правила; salt нужна для hash и tokenize.
+func NewRedactor(rules []RedactRule, salt []byte) (*Redactor, error) {
+	r := &Redactor{salt: salt, counts: map[RedactRule]int{}}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if rule.Field == "" {
+			return nil, errors.New("redaction rule without field")
+		}
+		if seen[rule.Field] {
+			return nil, fmt.Errorf("field %s: duplicate redaction rule", rule.Field)
+		}
+		seen[rule.Field] = true
+
+		switch rule.Action {
+		case RedactDrop:
+		case RedactHash, RedactTokenize:
+			if len(salt) == 0 {
+				return nil, fmt.Errorf("field %s: %s requires a tenant salt", rule.Field, rule.Action)
+			}
+		case RedactCoarsen:
+			if rule.Precision < 0 || rule.Precision > MaxRedactPrecision {
+				return nil, fmt.Errorf("field %s: precision must be in 0..%d", rule.Field, MaxRedactPrecision)
+			}
+		default:
+			return nil, fmt.Errorf("field %s: unknown redaction action %q", rule.Field, rule.Action)
+		}
+		r.rules = append(r.rules, rule)
+		r.paths = append(r.paths, strings.Split(strings.TrimPrefix(rule.Field, "$."), "."))
+	}
+	return r, nil
+}
+
+// TenantSalt выводит соль тенанта из секрета chunker, чтобы одинаковые
+// значения разных тенантов давали разные хэши и токены.
+func TenantSalt(secret []byte, tenantID string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("redaction:" + tenantID))
+	return mac.Sum(nil)
+}
+
+// Counts возвращает счётчики, упорядоченные по полю.
+func (r *Redactor) Counts() []RedactionCount {
+	out := make([]RedactionCount, 0, len(r.counts))
+	for rule, n := range r.counts {
+		out = append(out, RedactionCount{Field: rule.Field, Action: rule.Action, Values: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
+
+// RedactChunks применяет правила к чанкам одного источника. В CSV правило
+// ищется по имени колонки из заголовка первого чанка, в JSON — сначала как
+// ключ верхнего уровня, затем как путь. Значение, которое нельзя огрубить
+// (не число), удаляется.
+func (r *Redactor) RedactChunks(chunks [][]byte, header bool) ([][]byte, error) {
+	if len(r.rules) == 0 || len(chunks) == 0 {
+		return chunks, nil
+	}
+
+	out := make([][]byte, len(chunks))
+	var err error
+	if header {
+		var columns []int // индекс правила для колонки, -1 = без правила
+		for i, c := range chunks {
+			out[i], _, err = transformCSVCells(c, func(row []csvCell) (int, error) {
+				if columns == nil {
+					columns = r.csvColumns(row)
+					r.dropColumns(row, columns)
+					return 0, nil
+				}
+				return r.redactRow(row, columns), nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d: %w", i, err)
+			}
+		}
+		return out, nil
+	}
+
+	for i, c := range chunks {
+		out[i], _, err = transformJSONItems(c, func(_ int, obj map[string]json.RawMessage) (int, error) {
+			n := 0
+			for k, rule := range r.rules {
+				path := r.paths[k]
+				if _, ok := obj[rule.Field]; ok {
+					path = []string{rule.Field}
+				}
+				m, err := r.redactPath(obj, path, rule)
+				if err != nil {
+					return 0, err
+				}
+				n += m
+			}
+			return n, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// RedactRecord применяет правила к декодированной записи бинарного формата
+// до конвертации в модель (Avro, protobuf, line protocol, SenML), так что
+// правила задаются по именам полей источника. Правило ищется так же, как
+// в JSON-чанках. Возвращает число обработанных значений.
+func (r *Redactor) RedactRecord(rec map[string]any) int {
+	n := 0
+	for k, rule := range r.rules {
+		path := r.paths[k]
+		if _, ok := rec[rule.Field]; ok {
+			path = []string{rule.Field}
+		}
+		n += r.redactRecordPath(rec, path, rule)
+	}
+	return n
+}
+
+func (r *Redactor) redactRecordPath(obj map[string]any, path []string, rule RedactRule) int {
+	v, ok := obj[path[0]]
+	if !ok || v == nil {
+		return 0
+	}
+	if len(path) == 1 {
+		if rule.Action == RedactDrop {
+			delete(obj, path[0])
+		} else {
+			obj[path[0]] = r.redactAny(v, rule)
+		}
+		r.counts[rule]++
+		return 1
+	}
+
+	n := 0
+	var descend func(v any)
+	descend = func(v any) {
+		switch child := v.(type) {
+		case map[string]any:
+			n += r.redactRecordPath(child, path[1:], rule)
+		case []any:
+			for _, item := range child {
+				descend(item)
+			}
+		}
+	}
+	descend(v)
+	return n
+}
+
+// redactAny обрабатывает значение записи: строки остаются строками,
+// огрублённые числа — числами, значение, которое нельзя огрубить,
+// становится nil.
+func (r *Redactor) redactAny(v any, rule RedactRule) any {
+	text, quoted := v.(string)
+	if !quoted {
+		text = fmt.Sprint(v)
+	}
+	out, ok := r.redactText(text, rule)
+	if !ok {
+		return nil
+	}
+	if rule.Action == RedactCoarsen && !quoted {
+		f, _ := strconv.ParseFloat(out, 64)
+		return f
+	}
+	return out
+}
+
+func (r *Redactor) csvColumns(header []csvCell) []int {
+	columns := make([]int, len(header))
+	for j, name := range header {
+		columns[j] = -1
+		for k, rule := range r.rules {
+			if rule.Field == name.Value {
+				columns[j] = k
+			}
+		}
+	}
+	return columns
+}
+
+// dropColumns убирает из заголовка колонки с правилом drop.
+func (r *Redactor) dropColumns(header []csvCell, columns []int) {
+	for j := range header {
+		if columns[j] >= 0 && r.rules[columns[j]].Action == RedactDrop {
+			header[j].Drop = true
+		}
+	}
+}
+
+// redactRow обрабатывает строку данных CSV и возвращает число
+// обработанных значений.
+func (r *Redactor) redactRow(row []csvCell, columns []int) int {
+	n := 0
+	for j := range row {
+		if j >= len(columns) || columns[j] < 0 {
+			continue
+		}
+		rule := r.rules[columns[j]]
+		if row[j].Value != "" {
+			r.counts[rule]++
+			n++
+			if rule.Action != RedactDrop {
+				row[j].Value, _ = r.redactText(row[j].Value, rule)
+			}
+		}
+		row[j].Drop = rule.Action == RedactDrop
+	}
+	return n
+}
+
+// redactPath спускается по пути в объекте (и поэлементно в массивах)
+// и обрабатывает найденные значения. Возвращает число обработанных.
+func (r *Redactor) redactPath(obj map[string]json.RawMessage, path []string, rule RedactRule) (int, error) {
+	v, ok := obj[path[0]]
+	if !ok || isJSONNull(v) {
+		return 0, nil
+	}
+
+	if len(path) == 1 {
+		if rule.Action == RedactDrop {
+			delete(obj, path[0])
+			r.counts[rule]++
+			return 1, nil
+		}
+		out, err := r.redactJSONValue(v, rule)
+		if err != nil {
+			return 0, fmt.Errorf("field %s: %w", rule.Field, err)
+		}
+		obj[path[0]] = out
+		r.counts[rule]++
+		return 1, nil
+	}
+
+	var n int
+	out, err := r.descend(v, func(child map[string]json.RawMessage) (int, error) {
+		m, err := r.redactPath(child, path[1:], rule)
+		n += m
+		return m, err
+	})
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	obj[path[0]] = out
+	return n, nil
+}
+
+// descend применяет fn к вложенному объекту или к объектам массива
+// и возвращает перекодированное значение.
+func (r *Redactor) descend(v json.RawMessage, fn func(map[string]json.RawMessage) (int, error)) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(v)
+	if len(trimmed) == 0 {
+		return v, nil
+	}
+	switch trimmed[0] {
+	case '{':
+		var child map[string]json.RawMessage
+		if err := json.Unmarshal(v, &child); err != nil {
+			return nil, err
+		}
+		if _, err := fn(child); err != nil {
+			return nil, err
+		}
+		return marshalNoEscape(child)
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(v, &items); err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			out, err := r.descend(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = out
+		}
+		return marshalNoEscape(items)
+	default:
+		return v, nil
+	}
+}
+
+func (r *Redactor) redactJSONValue(v json.RawMessage, rule RedactRule) (json.RawMessage, error) {
+	var text string
+	quoted := json.Unmarshal(v, &text) == nil
+	if !quoted {
+		text = string(bytes.TrimSpace(v))
+	}
+
+	out, ok := r.redactText(text, rule)
+	if !ok {
+		return json.RawMessage("null"), nil
+	}
+	// огрублённое число остаётся числом, остальное становится строкой
+	if rule.Action == RedactCoarsen && !quoted {
+		return json.RawMessage(out), nil
+	}
+	return marshalNoEscape(out)
+}
+
+// redactText обрабатывает значение как текст. ok == false — значение
+// нельзя огрубить, его нужно удалить.
+func (r *Redactor) redactText(v string, rule RedactRule) (string, bool) {
+	switch rule.Action {
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.salt)
+		mac.Write([]byte(v))
+		return hex.EncodeToString(mac.Sum(nil)), true
+	case RedactTokenize:
+		return tokenize(r.salt, v), true
+	case RedactCoarsen:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		scale := math.Pow10(rule.Precision)
+		return strconv.FormatFloat(math.Round(f*scale)/scale, 'f', rule.Precision, 64), true
+	default:
+		return v, true
+	}
+}
+
+// tokenize заменяет символы значения, сохраняя их класс. Гамма —
+// HMAC-SHA256 от значения, так что одинаковые значения дают одинаковые
+// токены и записи остаются связуемыми. Шестнадцатеричные значения
+// (MAC-адреса) остаются шестнадцатеричными.
+func tokenize(salt []byte, v string) string {
+	hexOnly := isHexLike(v)
+	hexDigits := "0123456789abcdef"
+	if strings.ToLower(v) != v {
+		hexDigits = "0123456789ABCDEF"
+	}
+	stream := keystream(salt, v)
+
+	var b strings.Builder
+	for _, c := range v {
+		switch {
+		case hexOnly && c != ':' && c != '-' && c != '.':
+			b.WriteByte(hexDigits[stream()%16])
+		case c >= '0' && c <= '9':
+			b.WriteByte(byte('0' + stream()%10))
+		case c >= 'a' && c <= 'z':
+			b.WriteRune('a' + rune(stream()%26))
+		case c >= 'A' && c <= 'Z':
+			b.WriteRune('A' + rune(stream()%26))
+		case c >= 'а' && c <= 'я':
+			b.WriteRune('а' + rune(stream()%32))
+		case c >= 'А' && c <= 'Я':
+			b.WriteRune('А' + rune(stream()%32))
+		case unicode.IsLower(c):
+			b.WriteRune('a' + rune(stream()%26))
+		case unicode.IsUpper(c) || unicode.IsLetter(c):
+			b.WriteRune('A' + rune(stream()%26))
+		case unicode.IsDigit(c):
+			b.WriteByte(byte('0' + stream()%10))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// isHexLike — значение из шестнадцатеричных групп через разделители
+// (00:1A:2B:3C:4D:5E), хотя бы с одной буквой.
+func isHexLike(v string) bool {
+	letters := false
+	for _, c := range v {
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+			letters = true
+		case c == ':' || c == '-' || c == '.':
+		default:
+			return false
+		}
+	}
+	return letters
+}
+
+// keystream выдаёт псевдослучайные числа из HMAC(salt, v || counter).
+func keystream(salt []byte, v string) func() uint32 {
+	var block []byte
+	var counter uint64
+	return func() uint32 {
+		if len(block) < 4 {
+			mac := hmac.New(sha256.New, salt)
+			mac.Write([]byte(v))
+			_ = binary.Write(mac, binary.BigEndian, counter)
+			counter++
+			block = mac.Sum(nil)
+		}
+		n := binary.BigEndian.Uint32(block)
+		block = block[4:]
+		return n
+	}
+}
+
+func isJSONNull(v json.RawMessage) bool {
+	return string(bytes.TrimSpace(v)) == "null"
+}
+
+func marshalNoEscape(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactChunksJSON(t *testing.T) {
+	salt := TenantSalt([]byte("secret"), "tenant-1")
+	r, err := NewRedactor([]RedactRule{
+		{Field: "owner", Action: RedactDrop},
+		{Field: "device.mac", Action: RedactTokenize},
+		{Field: "$.location.lat", Action: RedactCoarsen, Precision: 2},
+		{Field: "location.lon", Action: RedactCoarsen, Precision: 1},
+		{Field: "serial", Action: RedactHash},
+	}, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := []byte(`[{"sensor_id":"s1","owner":"Иван Петров","device":{"mac":"00:1A:2B:3C:4D:5E"},"location":{"lat":55.755826,"lon":"37.6173"},"serial":"SN-001"},{"sensor_id":"s2","location":{"lat":"north"}}]`)
+	out, err := r.RedactChunks([][]byte{chunk}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal(out[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got[0]["owner"]; ok {
+		t.Fatalf("owner not dropped: %s", out[0])
+	}
+	mac := got[0]["device"].(map[string]any)["mac"].(string)
+	if mac == "00:1A:2B:3C:4D:5E" || !regexp.MustCompile(`^([0-9A-F]{2}:){5}[0-9A-F]{2}$`).MatchString(mac) {
+		t.Fatalf("mac token %q does not preserve format", mac)
+	}
+	loc := got[0]["location"].(map[string]any)
+	if loc["lat"] != 55.76 || loc["lon"] != "37.6" {
+		t.Fatalf("location = %v", loc)
+	}
+	if s := got[0]["serial"].(string); len(s) != 64 || strings.Contains(s, "SN") {
+		t.Fatalf("serial hash = %q", s)
+	}
+	if lat := got[1]["location"].(map[string]any)["lat"]; lat != nil {
+		t.Fatalf("non-numeric coordinate kept: %v", lat)
+	}
+
+	// токены детерминированы в пределах тенанта
+	again, _ := r.RedactChunks([][]byte{chunk}, false)
+	if string(again[0]) != string(out[0]) {
+		t.Fatalf("redaction is not deterministic:\n%s\n%s", out[0], again[0])
+	}
+
+	want := []RedactionCount{
+		{Field: "$.location.lat", Action: RedactCoarsen, Values: 4},
+		{Field: "device.mac", Action: RedactTokenize, Values: 2},
+		{Field: "location.lon", Action: RedactCoarsen, Values: 2},
+		{Field: "owner", Action: RedactDrop, Values: 2},
+		{Field: "serial", Action: RedactHash, Values: 2},
+	}
+	if got := r.Counts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("counts = %+v, want %+v", got, want)
+	}
+}
+
+func TestRedactChunksCSV(t *testing.T) {
+	r, err := NewRedactor([]RedactRule{
+		{Field: "owner", Action: RedactDrop},
+		{Field: "lat", Action: RedactCoarsen, Precision: 1},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := SplitCSVToChunks(strings.NewReader("sensor_id,owner,lat\ns1,Ann,55.7558\ns2,,\ns3,Bob,-33.86\n"), ChunkPolicy{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := r.RedactChunks(chunks, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range out {
+		got = append(got, string(c))
+	}
+	want := []string{"sensor_id,lat\ns1,55.8\n", "s2,\ns3,-33.9\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRedactChunksCSVKeepsRawBytes(t *testing.T) {
+	r, err := NewRedactor([]RedactRule{{Field: "owner", Action: RedactDrop}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := []byte("sensor_id,note,owner\r\ns1,\"a\r\nb\",Ann\r\n\ns2,\"\"\"q\"\"\",\r\n")
+	out, err := r.RedactChunks([][]byte{chunk}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sensor_id,note\r\ns1,\"a\r\nb\"\r\n\ns2,\"\"\"q\"\"\"\r\n"; string(out[0]) != want {
+		t.Fatalf("got %q, want %q", out[0], want)
+	}
+}
+
+func TestRedactRecord(t *testing.T) {
+	r, err := NewRedactor([]RedactRule{
+		{Field: "temp", Action: RedactCoarsen, Precision: 0},
+		{Field: "tags.owner", Action: RedactDrop},
+		{Field: "serial", Action: RedactHash},
+		{Field: "lat", Action: RedactCoarsen, Precision: 1},
+	}, []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := map[string]any{
+		"temp":   20.56,
+		"serial": "SN-1",
+		"lat":    "north",
+		"tags":   map[string]any{"owner": "Ann", "site": "a"},
+	}
+	if n := r.RedactRecord(rec); n != 4 {
+		t.Fatalf("redacted %d values, want 4", n)
+	}
+	tags := rec["tags"].(map[string]any)
+	if rec["temp"] != 21.0 || rec["lat"] != nil || rec["serial"] == "SN-1" || tags["owner"] != nil || tags["site"] != "a" {
+		t.Fatalf("got %+v", rec)
+	}
+}
+
+func TestTokenizePreservesFormat(t *testing.T) {
+	salt := []byte("salt")
+	for _, v := range []string{"John Smith", "+7 (912) 345-67-89", "aa-bb-cc-dd-ee-ff", "Мария"} {
+		tok := tokenize(salt, v)
+		if tok == v || len([]rune(tok)) != len([]rune(v)) {
+			t.Fatalf("tokenize(%q) = %q", v, tok)
+		}
+		for i, c := range []rune(v) {
+			if isSeparator := strings.ContainsRune(" +()-", c); isSeparator && []rune(tok)[i] != c {
+				t.Fatalf("tokenize(%q) = %q moved separator", v, tok)
+			}
+		}
+	}
+	if tokenize(salt, "John") == tokenize([]byte("other"), "John") {
+		t.Fatal("tokens must depend on the tenant salt")
+	}
+}
+
+func TestNewRedactorRejectsInvalidRules(t *testing.T) {
+	for _, rules := range [][]RedactRule{
+		{{Field: "a", Action: "mask"}},
+		{{Field: "a", Action: RedactHash}},
+		{{Field: "a", Action: RedactCoarsen, Precision: 12}},
+		{{Field: "a", Action: RedactDrop}, {Field: "a", Action: RedactDrop}},
+		{{Action: RedactDrop}},
+	} {
+		if _, err := NewRedactor(rules, nil); err == nil {
+			t.Fatalf("expected error for %+v", rules)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ResampleAggregate — как значения попавших в интервал показаний
+// сводятся в одно.
+type ResampleAggregate string
+
+const (
+	ResampleMean ResampleAggregate = "mean"
+	ResampleLast ResampleAggregate = "last"
+	ResampleMax  ResampleAggregate = "max"
+)
+
+// GapFill — чем заполнять интервалы без показаний.
+type GapFill string
+
+const (
+	GapFillNone     GapFill = ""
+	GapFillLinear   GapFill = "linear"
+	GapFillPrevious GapFill = "previous"
+)
+
+// DefaultResampleMaxFill — сколько пустых интервалов подряд заполняется
+// по умолчанию: сутки минутных интервалов.
+const DefaultResampleMaxFill = 1440
+
+// ResampleConfig — параметры передискретизации ряда.
+type ResampleConfig struct {
+	Interval  time.Duration
+	Aggregate ResampleAggregate
+	// GapThreshold — перерыв между соседними показаниями, начиная
+	// с которого он считается разрывом.
+	GapThreshold time.Duration
+	Fill         GapFill
+	// MaxFill — предел заполняемых интервалов одного разрыва; более
+	// длинные разрывы остаются пустыми (они всё равно попадают в отчёт
+	// о разрывах). Ноль — DefaultResampleMaxFill.
+	MaxFill int
+}
+
+// ParseResampleConfig проверяет параметры: длительности — как в Go
+// (30s, 5m, 1h). Пустой aggregate — mean, пустой gapThreshold — два
+// интервала; порог разрыва не может быть меньше интервала. MaxFill —
+// DefaultResampleMaxFill.
+func ParseResampleConfig(interval, aggregate, gapThreshold, fill string) (ResampleConfig, error) {
+	cfg := ResampleConfig{MaxFill: DefaultResampleMaxFill}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return cfg, fmt.Errorf("invalid interval %q", interval)
+	}
+	cfg.Interval = d
+
+	switch agg := ResampleAggregate(aggregate); agg {
+	case "":
+		cfg.Aggregate = ResampleMean
+	case ResampleMean, ResampleLast, ResampleMax:
+		cfg.Aggregate = agg
+	default:
+		return cfg, fmt.Errorf("unknown aggregate %s, supported: %s, %s, %s", aggregate, ResampleMean, ResampleLast, ResampleMax)
+	}
+
+	cfg.GapThreshold = 2 * cfg.Interval
+	if gapThreshold != "" {
+		d, err := time.ParseDuration(gapThreshold)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid gap threshold %q", gapThreshold)
+		}
+		if d < cfg.Interval {
+			return cfg, fmt.Errorf("gap threshold %s is shorter than interval %s", d, cfg.Interval)
+		}
+		cfg.GapThreshold = d
+	}
+
+	switch f := GapFill(fill); f {
+	case GapFillNone, GapFillLinear, GapFillPrevious:
+		cfg.Fill = f
+	default:
+		return cfg, fmt.Errorf("unknown fill %s, supported: %s, %s", fill, GapFillLinear, GapFillPrevious)
+	}
+	return cfg, nil
+}
+
+// SeriesPoint — показание ряда одного датчика. Метрики, которых нет
+// в показании, отсутствуют в Values.
+type SeriesPoint struct {
+	Time   time.Time
+	Values map[string]float64
+	// Samples — сколько исходных показаний попало в интервал; у
+	// заполненных интервалов 0.
+	Samples int
+}
+
+// Gap — разрыв ряда: между показаниями Start и End нет других.
+type Gap struct {
+	Start, End time.Time
+}
+
+// Resample сводит показания одного датчика в интервалы cfg.Interval
+// (начало интервала — его отметка времени) и находит разрывы. Если
+// задан Fill, пустые интервалы между первым и последним показанием
+// заполняются, иначе пропускаются.
+func Resample(points []SeriesPoint, cfg ResampleConfig) ([]SeriesPoint, []Gap) {
+	r := NewResampler(cfg)
+	for _, p := range points {
+		r.Add(p)
+	}
+	return r.Result()
+}
+
+// Resampler сводит показания одного датчика в интервалы по мере
+// поступления, в любом порядке. Хранятся только агрегаты интервалов и
+// времена крайних показаний в них, так что память зависит от охвата ряда
+// во времени, а не от числа показаний.
+//
+// Разрывы ищутся между соседними непустыми интервалами: порог разрыва не
+// меньше интервала (см. ParseResampleConfig), поэтому внутри одного
+// интервала разрыва быть не может.
+type Resampler struct {
+	cfg     ResampleConfig
+	buckets map[int64]*resampleBucket
+}
+
+type resampleBucket struct {
+	start       time.Time
+	first, last time.Time
+	samples     int
+	values      map[string]*bucketValue
+}
+
+type bucketValue struct {
+	value float64
+	count int
+	// at — время показания, давшего значение для ResampleLast
+	at time.Time
+}
+
+func NewResampler(cfg ResampleConfig) *Resampler {
+	return &Resampler{cfg: cfg, buckets: map[int64]*resampleBucket{}}
+}
+
+// Add добавляет показание в его интервал. При ResampleLast из
+// показаний с одинаковым временем побеждает добавленное позже.
+func (r *Resampler) Add(p SeriesPoint) {
+	start := p.Time.Truncate(r.cfg.Interval)
+	b, ok := r.buckets[start.UnixNano()]
+	if !ok {
+		b = &resampleBucket{start: start, first: p.Time, last: p.Time, values: map[string]*bucketValue{}}
+		r.buckets[start.UnixNano()] = b
+	}
+	b.samples++
+	if p.Time.Before(b.first) {
+		b.first = p.Time
+	}
+	if p.Time.After(b.last) {
+		b.last = p.Time
+	}
+
+	for metric, v := range p.Values {
+		bv, seen := b.values[metric]
+		if !seen {
+			b.values[metric] = &bucketValue{value: v, count: 1, at: p.Time}
+			continue
+		}
+		bv.count++
+		switch r.cfg.Aggregate {
+		case ResampleLast:
+			if !p.Time.Before(bv.at) {
+				bv.value, bv.at = v, p.Time
+			}
+		case ResampleMax:
+			bv.value = max(bv.value, v)
+		default:
+			bv.value += (v - bv.value) / float64(bv.count)
+		}
+	}
+}
+
+// Result возвращает интервалы ряда по времени и разрывы между ними.
+func (r *Resampler) Result() ([]SeriesPoint, []Gap) {
+	ordered := make([]*resampleBucket, 0, len(r.buckets))
+	for _, b := range r.buckets {
+		ordered = append(ordered, b)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].start.Before(ordered[j].start) })
+
+	var gaps []Gap
+	out := make([]SeriesPoint, 0, len(ordered))
+	for i, b := range ordered {
+		if i > 0 {
+			if prev := ordered[i-1]; b.first.Sub(prev.last) >= r.cfg.GapThreshold {
+				gaps = append(gaps, Gap{Start: prev.last, End: b.first})
+			}
+		}
+		p := SeriesPoint{Time: b.start, Values: make(map[string]float64, len(b.values)), Samples: b.samples}
+		for metric, bv := range b.values {
+			p.Values[metric] = bv.value
+		}
+		out = append(out, p)
+	}
+
+	if r.cfg.Fill == GapFillNone {
+		return out, gaps
+	}
+	return fillSeries(out, r.cfg), gaps
+}
+
+// fillSeries вставляет пустые интервалы между соседними непустыми.
+// Линейная интерполяция заполняет только метрики, которые есть на обоих
+// краях. Промежутки длиннее cfg.MaxFill интервалов не заполняются, чтобы
+// долгий простой датчика не раздувал ряд.
+func fillSeries(buckets []SeriesPoint, cfg ResampleConfig) []SeriesPoint {
+	maxFill := cfg.MaxFill
+	if maxFill <= 0 {
+		maxFill = DefaultResampleMaxFill
+	}
+	var out []SeriesPoint
+	for i, b := range buckets {
+		if i > 0 {
+			prev := buckets[i-1]
+			span := b.Time.Sub(prev.Time)
+			if span/cfg.Interval-1 > time.Duration(maxFill) {
+				out = append(out, b)
+				continue
+			}
+			for t := prev.Time.Add(cfg.Interval); t.Before(b.Time); t = t.Add(cfg.Interval) {
+				filled := SeriesPoint{Time: t, Values: map[string]float64{}}
+				frac := float64(t.Sub(prev.Time)) / float64(span)
+				for metric, v := range prev.Values {
+					if cfg.Fill == GapFillPrevious {
+						filled.Values[metric] = v
+					} else if next, ok := b.Values[metric]; ok {
+						filled.Values[metric] = v + (next-v)*frac
+					}
+				}
+				out = append(out, filled)
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func points(start time.Time, minutes []int, values []float64) []SeriesPoint {
+	out := make([]SeriesPoint, len(minutes))
+	for i, m := range minutes {
+		out[i] = SeriesPoint{Time: start.Add(time.Duration(m) * time.Minute), Values: map[string]float64{"temperature": values[i]}}
+	}
+	return out
+}
+
+func TestResampleAggregates(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := points(start, []int{0, 2, 4, 6, 9}, []float64{10, 30, 20, 5, 7})
+	for agg, want := range map[string][]float64{
+		"mean": {20, 6},
+		"last": {20, 7},
+		"max":  {30, 7},
+	} {
+		cfg, err := ParseResampleConfig("5m", agg, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _ := Resample(in, cfg)
+		if len(out) != 2 || out[0].Values["temperature"] != want[0] || out[1].Values["temperature"] != want[1] || out[0].Samples != 3 {
+			t.Errorf("%s: %+v", agg, out)
+		}
+	}
+}
+
+func TestResampleGapsAndFill(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// минуты 1..3 без показаний
+	in := points(start, []int{4, 0, 1}, []float64{40, 0, 10})
+
+	cfg, _ := ParseResampleConfig("1m", "", "3m", "")
+	out, gaps := Resample(in, cfg)
+	if len(out) != 3 {
+		t.Fatalf("without fill: %+v", out)
+	}
+	if len(gaps) != 1 || !gaps[0].Start.Equal(start.Add(time.Minute)) || !gaps[0].End.Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("gaps = %+v", gaps)
+	}
+
+	cfg.Fill = GapFillLinear
+	out, _ = Resample(in, cfg)
+	if len(out) != 5 || out[2].Values["temperature"] != 20 || out[3].Values["temperature"] != 30 || out[2].Samples != 0 {
+		t.Fatalf("linear: %+v", out)
+	}
+
+	cfg.Fill = GapFillPrevious
+	out, _ = Resample(in, cfg)
+	if len(out) != 5 || out[3].Values["temperature"] != 10 {
+		t.Fatalf("previous: %+v", out)
+	}
+}
+
+func TestResampleFillLimit(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// год простоя между показаниями
+	in := []SeriesPoint{
+		{Time: start, Values: map[string]float64{"temperature": 10}},
+		{Time: start.AddDate(1, 0, 0), Values: map[string]float64{"temperature": 20}},
+		{Time: start.AddDate(1, 0, 0).Add(3 * time.Second), Values: map[string]float64{"temperature": 23}},
+	}
+
+	cfg, _ := ParseResampleConfig("1s", "", "", "linear")
+	out, gaps := Resample(in, cfg)
+	if len(out) != 5 || len(gaps) != 2 {
+		t.Fatalf("got %d points, %d gaps", len(out), len(gaps))
+	}
+	if out[3].Values["temperature"] != 22 || out[3].Samples != 0 {
+		t.Fatalf("short gap not filled: %+v", out)
+	}
+
+	cfg.MaxFill = 1
+	if out, _ = Resample(in, cfg); len(out) != 3 {
+		t.Fatalf("MaxFill 1: %+v", out)
+	}
+}
+
+func TestResamplerOutOfOrder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg, _ := ParseResampleConfig("5m", "last", "5m", "")
+	r := NewResampler(cfg)
+	// показания приходят чанками не по порядку
+	for _, p := range points(start, []int{16, 9, 2}, []float64{5, 7, 1}) {
+		r.Add(p)
+	}
+	for _, p := range points(start, []int{0, 6}, []float64{3, 9}) {
+		r.Add(p)
+	}
+
+	out, gaps := r.Result()
+	if len(out) != 3 || out[0].Values["temperature"] != 1 || out[1].Values["temperature"] != 7 || out[1].Samples != 2 {
+		t.Fatalf("%+v", out)
+	}
+	if len(gaps) != 1 || !gaps[0].Start.Equal(start.Add(9*time.Minute)) || !gaps[0].End.Equal(start.Add(16*time.Minute)) {
+		t.Errorf("gaps: %+v", gaps)
+	}
+}
+
+func TestParseResampleConfigErrors(t *testing.T) {
+	for _, args := range [][4]string{
+		{"", "", "", ""},
+		{"-1m", "", "", ""},
+		{"5m", "median", "", ""},
+		{"5m", "", "soon", ""},
+		{"5m", "", "1m", ""},
+		{"5m", "", "", "spline"},
+	} {
+		if _, err := ParseResampleConfig(args[0], args[1], args[2], args[3]); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
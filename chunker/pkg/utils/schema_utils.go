@@ -0,0 +1,442 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"units"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// DefaultSchemaSample — сколько первых записей источника смотрит
+// вывод схемы, если размер выборки не задан.
+const DefaultSchemaSample = 1000
+
+// MaxValidationErrors ограничивает отчёт: дальше ошибки не собираются.
+const MaxValidationErrors = 100
+
+type ColumnType string
+
+const (
+	ColumnString    ColumnType = "string"
+	ColumnInteger   ColumnType = "integer"
+	ColumnNumber    ColumnType = "number"
+	ColumnBoolean   ColumnType = "boolean"
+	ColumnTimestamp ColumnType = "timestamp"
+	ColumnObject    ColumnType = "object"
+	ColumnArray     ColumnType = "array"
+	ColumnNull      ColumnType = "null"
+)
+
+type ColumnSchema struct {
+	Name     string     `json:"name"`
+	Type     ColumnType `json:"type"`
+	Nullable bool       `json:"nullable,omitempty"`
+	// TimeFormat — rfc3339, unix_s/unix_ms/unix_us/unix_ns или Go-раскладка.
+	TimeFormat string `json:"time_format,omitempty"`
+	Unit       string `json:"unit,omitempty"`
+}
+
+type InferredSchema struct {
+	Sampled int            `json:"sampled"`
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// ValidationError — нарушение схемы. Row — номер записи источника
+// с единицы, без строки заголовка.
+type ValidationError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// RecordValidator проверяет одну запись выборки. rec == nil означает,
+// что элемент чанка не является объектом.
+type RecordValidator func(rec map[string]any) []ValidationError
+
+// SampleRecords достаёт первые n записей из готовых чанков. Чанки
+// с заголовком (CSV) разбираются по первой строке первого чанка, значения
+// приводятся к числам и bool там, где это возможно.
+func SampleRecords(chunks [][]byte, header bool, n int) ([]map[string]any, error) {
+	var records []map[string]any
+	next := chunkRecords(chunks, header)
+	for len(records) < n {
+		rec, ok, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// chunkRecords перебирает записи чанков: JSON-массивов или CSV с заголовком
+// в первом чанке. Элемент JSON-массива, не являющийся объектом, отдаётся
+// как nil.
+func chunkRecords(chunks [][]byte, header bool) func() (map[string]any, bool, error) {
+	var columns []string
+	var pending []json.RawMessage
+	var cr *csv.Reader
+	i := -1
+
+	return func() (map[string]any, bool, error) {
+		for {
+			if !header && len(pending) > 0 {
+				item := pending[0]
+				pending = pending[1:]
+				var rec map[string]any
+				d := json.NewDecoder(bytes.NewReader(item))
+				d.UseNumber()
+				if err := d.Decode(&rec); err != nil {
+					rec = nil
+				}
+				return rec, true, nil
+			}
+
+			if header && cr != nil {
+				row, err := cr.Read()
+				if err != nil && err != io.EOF {
+					return nil, false, fmt.Errorf("chunk %d: %w", i, err)
+				}
+				if err == nil {
+					if columns == nil {
+						columns = row
+						continue
+					}
+					rec := make(map[string]any, len(columns))
+					for j, name := range columns {
+						if j < len(row) {
+							rec[name] = coerceCSVValue(row[j])
+						} else {
+							rec[name] = nil
+						}
+					}
+					return rec, true, nil
+				}
+			}
+
+			i++
+			if i >= len(chunks) {
+				return nil, false, nil
+			}
+			if header {
+				cr = csv.NewReader(bytes.NewReader(chunks[i]))
+				cr.FieldsPerRecord = -1
+				continue
+			}
+			if err := json.Unmarshal(chunks[i], &pending); err != nil {
+				return nil, false, fmt.Errorf("chunk %d: %w", i, err)
+			}
+		}
+	}
+}
+
+// coerceCSVValue угадывает тип текстового значения. Числа остаются
+// json.Number, чтобы не терять точность.
+func coerceCSVValue(s string) any {
+	v := strings.TrimSpace(s)
+	switch {
+	case v == "":
+		return nil
+	case strings.EqualFold(v, "true"):
+		return true
+	case strings.EqualFold(v, "false"):
+		return false
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil && json.Valid([]byte(v)) {
+		return json.Number(v)
+	}
+	return s
+}
+
+// unitsKey — объект с единицами метрик (SenML).
+const unitsKey = "units"
+
+// InferSchema выводит типы колонок, формат времени и единицы измерения.
+func InferSchema(records []map[string]any) InferredSchema {
+	type acc struct {
+		types      map[ColumnType]int
+		nulls      int
+		timeFormat string
+		timeMixed  bool
+		unit       string
+	}
+	cols := map[string]*acc{}
+	var order []string
+	col := func(name string) *acc {
+		a, ok := cols[name]
+		if !ok {
+			a = &acc{types: map[ColumnType]int{}}
+			cols[name] = a
+			order = append(order, name)
+		}
+		return a
+	}
+
+	for _, rec := range records {
+		for name, v := range rec {
+			a := col(name)
+			t, format := valueType(name, v)
+			if t == ColumnNull {
+				a.nulls++
+				continue
+			}
+			a.types[t]++
+			if format != "" {
+				if a.timeFormat != "" && a.timeFormat != format {
+					a.timeMixed = true
+				}
+				a.timeFormat = format
+			}
+		}
+		if units, ok := rec[unitsKey].(map[string]any); ok {
+			for metric, u := range units {
+				if s, ok := u.(string); ok && s != "" {
+					col(metric).unit = s
+				}
+			}
+		}
+	}
+
+	schema := InferredSchema{Sampled: len(records)}
+	// порядок ключей в записях случаен, колонки отдаём по алфавиту
+	sort.Strings(order)
+	for _, name := range order {
+		a := cols[name]
+		c := ColumnSchema{Name: name, Type: mergeTypes(a.types), Unit: a.unit}
+		c.Nullable = a.nulls > 0 || a.nulls+sumTypes(a.types) < len(records)
+		if c.Type == ColumnTimestamp {
+			c.TimeFormat = a.timeFormat
+			if a.timeMixed {
+				c.TimeFormat = "mixed"
+			}
+		}
+		if c.Unit == "" {
+			c.Unit = units.UnitFromColumnName(name)
+		}
+		schema.Columns = append(schema.Columns, c)
+	}
+	return schema
+}
+
+func sumTypes(types map[ColumnType]int) int {
+	n := 0
+	for _, c := range types {
+		n += c
+	}
+	return n
+}
+
+// mergeTypes сводит типы значений колонки: целые и дробные дают number,
+// любая другая смесь — string.
+func mergeTypes(types map[ColumnType]int) ColumnType {
+	switch len(types) {
+	case 0:
+		return ColumnNull
+	case 1:
+		for t := range types {
+			return t
+		}
+	}
+	if len(types) == 2 && types[ColumnInteger] > 0 && types[ColumnNumber] > 0 {
+		return ColumnNumber
+	}
+	return ColumnString
+}
+
+// timeColumnNames — колонки, числовые значения которых считаются
+// Unix-временем.
+var timeColumnNames = map[string]bool{"timestamp": true, "ts": true, "time": true}
+
+func valueType(name string, v any) (ColumnType, string) {
+	switch x := v.(type) {
+	case nil:
+		return ColumnNull, ""
+	case bool:
+		return ColumnBoolean, ""
+	case map[string]any:
+		return ColumnObject, ""
+	case []any:
+		return ColumnArray, ""
+	case json.Number:
+		isInt := !strings.ContainsAny(string(x), ".eE")
+		if timeColumnNames[strings.ToLower(name)] {
+			if f, err := x.Float64(); err == nil && f > 0 {
+				return ColumnTimestamp, epochFormat(f)
+			}
+		}
+		if isInt {
+			return ColumnInteger, ""
+		}
+		return ColumnNumber, ""
+	case float64:
+		if x == math.Trunc(x) {
+			return ColumnInteger, ""
+		}
+		return ColumnNumber, ""
+	case string:
+		if format, ok := DetectTimeFormat(x); ok {
+			return ColumnTimestamp, format
+		}
+		return ColumnString, ""
+	default:
+		return ColumnString, ""
+	}
+}
+
+// EpochToTime переводит Unix-время, единицы которого угадываются по
+// порядку величины (см. epochFormat).
+func EpochToTime(v float64) time.Time {
+	switch epochFormat(v) {
+	case "unix_s":
+		return time.Unix(0, int64(v*float64(time.Second))).UTC()
+	case "unix_ms":
+		return time.UnixMilli(int64(v)).UTC()
+	case "unix_us":
+		return time.UnixMicro(int64(v)).UTC()
+	default:
+		return time.Unix(0, int64(v)).UTC()
+	}
+}
+
+// epochFormat угадывает единицы Unix-времени по порядку величины.
+func epochFormat(v float64) string {
+	switch {
+	case v < 1e11:
+		return "unix_s"
+	case v < 1e14:
+		return "unix_ms"
+	case v < 1e17:
+		return "unix_us"
+	default:
+		return "unix_ns"
+	}
+}
+
+// timeLayouts — распознаваемые текстовые форматы времени помимо RFC 3339.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05Z07:00",
+	"02.01.2006 15:04:05",
+	"01/02/2006 15:04:05",
+	"2006-01-02",
+}
+
+// DetectTimeFormat возвращает "rfc3339" или раскладку, по которой
+// разобралось значение.
+func DetectTimeFormat(s string) (string, bool) {
+	_, format, ok := parseTimeText(s)
+	return format, ok
+}
+
+// ParseTimeText разбирает время в RFC 3339 или одной из timeLayouts и
+// приводит его к UTC.
+func ParseTimeText(s string) (time.Time, bool) {
+	t, _, ok := parseTimeText(s)
+	return t, ok
+}
+
+func parseTimeText(s string) (time.Time, string, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), "rfc3339", true
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), layout, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// ValidateRecords прогоняет выборку через валидатор и проставляет
+// номера записей. Собирается не больше MaxValidationErrors ошибок.
+func ValidateRecords(records []map[string]any, validate RecordValidator) []ValidationError {
+	var out []ValidationError
+	for i, rec := range records {
+		for _, e := range validate(rec) {
+			if len(out) >= MaxValidationErrors {
+				return out
+			}
+			e.Row = i + 1
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// CompileJSONSchema компилирует пользовательскую JSON Schema для проверки
+// отдельных записей.
+func CompileJSONSchema(schema []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("parse json schema: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("record.json", doc); err != nil {
+		return nil, fmt.Errorf("load json schema: %w", err)
+	}
+	sch, err := c.Compile("record.json")
+	if err != nil {
+		return nil, fmt.Errorf("compile json schema: %w", err)
+	}
+	return sch, nil
+}
+
+// NewJSONSchemaValidator возвращает валидатор записей по JSON Schema.
+// Каждое нарушение становится отдельной ошибкой с колонкой верхнего уровня.
+func NewJSONSchemaValidator(schema []byte) (RecordValidator, error) {
+	sch, err := CompileJSONSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rec map[string]any) []ValidationError {
+		if rec == nil {
+			return []ValidationError{{Reason: "record is not an object"}}
+		}
+		err := sch.Validate(rec)
+		var ve *jsonschema.ValidationError
+		if !errors.As(err, &ve) {
+			if err != nil {
+				return []ValidationError{{Reason: err.Error()}}
+			}
+			return nil
+		}
+
+		var out []ValidationError
+		var walk func(e *jsonschema.ValidationError)
+		walk = func(e *jsonschema.ValidationError) {
+			if len(e.Causes) > 0 {
+				for _, c := range e.Causes {
+					walk(c)
+				}
+				return
+			}
+			v := ValidationError{Reason: e.ErrorKind.LocalizedString(schemaPrinter)}
+			if len(e.InstanceLocation) > 0 {
+				v.Column = e.InstanceLocation[0]
+			}
+			out = append(out, v)
+		}
+		walk(ve)
+		return out
+	}, nil
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func columnsByName(s InferredSchema) map[string]ColumnSchema {
+	out := map[string]ColumnSchema{}
+	for _, c := range s.Columns {
+		out[c.Name] = c
+	}
+	return out
+}
+
+func TestSampleRecordsCSV(t *testing.T) {
+	chunks, err := SplitCSVToChunks(strings.NewReader("sensor_id,temperature_c,ok\ns1,20.5,true\ns2,,false\ns3,22,true\n"), ChunkPolicy{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := SampleRecords(chunks, true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"sensor_id": "s1", "temperature_c": json.Number("20.5"), "ok": true},
+		{"sensor_id": "s2", "temperature_c": nil, "ok": false},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("got %v, want %v", records, want)
+	}
+}
+
+func TestSampleRecordsJSON(t *testing.T) {
+	chunks := [][]byte{[]byte(`[{"a":1},2]`), []byte(`[{"a":3}]`)}
+
+	records, err := SampleRecords(chunks, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1] != nil || records[2]["a"] != json.Number("3") {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	input := "sensor_id,timestamp,temperature (°C),humidity_pct,note,ts\n" +
+		"s1,2025-01-02T03:04:05Z,20,40,a,1735787045000\n" +
+		"s2,2025-01-02T03:05:05Z,20.5,,b,1735787105000\n"
+	chunks, err := SplitCSVToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := SampleRecords(chunks, true, 10)
+
+	schema := InferSchema(records)
+	if schema.Sampled != 2 {
+		t.Fatalf("sampled = %d", schema.Sampled)
+	}
+	cols := columnsByName(schema)
+
+	want := map[string]ColumnSchema{
+		"sensor_id":        {Name: "sensor_id", Type: ColumnString},
+		"timestamp":        {Name: "timestamp", Type: ColumnTimestamp, TimeFormat: "rfc3339"},
+		"temperature (°C)": {Name: "temperature (°C)", Type: ColumnNumber, Unit: "Cel"},
+		"humidity_pct":     {Name: "humidity_pct", Type: ColumnInteger, Nullable: true, Unit: "%RH"},
+		"note":             {Name: "note", Type: ColumnString},
+		"ts":               {Name: "ts", Type: ColumnTimestamp, TimeFormat: "unix_ms"},
+	}
+	if !reflect.DeepEqual(cols, want) {
+		t.Fatalf("got %+v\nwant %+v", cols, want)
+	}
+}
+
+func TestInferSchemaSenMLUnits(t *testing.T) {
+	records := []map[string]any{
+		{"sensor_id": "s1", "timestamp": "2025-01-02 03:04:05", "temp": json.Number("21"), "units": map[string]any{"temp": "Cel"}},
+		{"sensor_id": "s1", "timestamp": "2025-01-02 03:05:05", "flag": true},
+	}
+
+	cols := columnsByName(InferSchema(records))
+	if cols["temp"].Unit != "Cel" || !cols["temp"].Nullable {
+		t.Errorf("temp: %+v", cols["temp"])
+	}
+	if cols["timestamp"].TimeFormat != "2006-01-02 15:04:05.999999999" {
+		t.Errorf("timestamp: %+v", cols["timestamp"])
+	}
+	if cols["units"].Type != ColumnObject || cols["flag"].Type != ColumnBoolean {
+		t.Errorf("unexpected types: %+v", cols)
+	}
+}
+
+func TestJSONSchemaValidator(t *testing.T) {
+	validate, err := NewJSONSchemaValidator([]byte(`{
+		"type": "object",
+		"required": ["sensor_id", "temperature"],
+		"properties": {
+			"sensor_id": {"type": "string"},
+			"temperature": {"type": "number", "minimum": -50, "maximum": 80}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []map[string]any{
+		{"sensor_id": "s1", "temperature": json.Number("20.5")},
+		{"sensor_id": "s2", "temperature": json.Number("120")},
+		{"temperature": "hot"},
+		nil,
+	}
+	errs := ValidateRecords(records, validate)
+
+	rows := map[int][]string{}
+	for _, e := range errs {
+		rows[e.Row] = append(rows[e.Row], e.Column)
+		if e.Reason == "" {
+			t.Errorf("row %d: empty reason", e.Row)
+		}
+	}
+	if _, ok := rows[1]; ok {
+		t.Errorf("valid record reported: %v", errs)
+	}
+	if !reflect.DeepEqual(rows[2], []string{"temperature"}) {
+		t.Errorf("row 2: %v", errs)
+	}
+	if len(rows[3]) != 2 || len(rows[4]) != 1 {
+		t.Errorf("rows 3-4: %v", errs)
+	}
+}
+
+func TestCompileJSONSchemaRejectsInvalid(t *testing.T) {
+	for _, s := range []string{`{`, `{"type": 5}`} {
+		if _, err := CompileJSONSchema([]byte(s)); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestValidateRecordsLimit(t *testing.T) {
+	records := make([]map[string]any, MaxValidationErrors+10)
+	errs := ValidateRecords(records, func(map[string]any) []ValidationError {
+		return []ValidationError{{Reason: "bad"}}
+	})
+	if len(errs) != MaxValidationErrors || errs[len(errs)-1].Row != MaxValidationErrors {
+		t.Fatalf("got %d errors", len(errs))
+	}
+}
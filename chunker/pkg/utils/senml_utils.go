@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// senmlRecord — запись SenML (RFC 8428). Теги cbor — целочисленные метки
+// из раздела 6 RFC.
+type senmlRecord struct {
+	BaseName  *string  `json:"bn,omitempty" cbor:"-2,keyasint,omitempty"`
+	BaseTime  *float64 `json:"bt,omitempty" cbor:"-3,keyasint,omitempty"`
+	BaseUnit  *string  `json:"bu,omitempty" cbor:"-4,keyasint,omitempty"`
+	BaseValue *float64 `json:"bv,omitempty" cbor:"-5,keyasint,omitempty"`
+	BaseSum   *float64 `json:"bs,omitempty" cbor:"-6,keyasint,omitempty"`
+
+	Name        string   `json:"n,omitempty" cbor:"0,keyasint,omitempty"`
+	Unit        string   `json:"u,omitempty" cbor:"1,keyasint,omitempty"`
+	Value       *float64 `json:"v,omitempty" cbor:"2,keyasint,omitempty"`
+	StringValue *string  `json:"vs,omitempty" cbor:"3,keyasint,omitempty"`
+	BoolValue   *bool    `json:"vb,omitempty" cbor:"4,keyasint,omitempty"`
+	Sum         *float64 `json:"s,omitempty" cbor:"5,keyasint,omitempty"`
+	Time        float64  `json:"t,omitempty" cbor:"6,keyasint,omitempty"`
+	DataValue   *string  `json:"vd,omitempty" cbor:"8,keyasint,omitempty"`
+}
+
+// senmlRelativeTimeLimit — значения времени меньше 2**28 по RFC 8428
+// считаются смещением относительно текущего момента.
+const senmlRelativeTimeLimit = 1 << 28
+
+// senmlMeasurement — одно измерение после разрешения базовых полей.
+type senmlMeasurement struct {
+	sensorID string
+	metric   string
+	unit     string
+	value    any
+	time     time.Time
+}
+
+type senmlResolver struct {
+	baseName  string
+	baseTime  float64
+	baseUnit  string
+	baseValue float64
+	baseSum   float64
+	now       time.Time
+}
+
+func (s *senmlResolver) resolve(rec senmlRecord) (senmlMeasurement, error) {
+	if rec.BaseName != nil {
+		s.baseName = *rec.BaseName
+	}
+	if rec.BaseTime != nil {
+		s.baseTime = *rec.BaseTime
+	}
+	if rec.BaseUnit != nil {
+		s.baseUnit = *rec.BaseUnit
+	}
+	if rec.BaseValue != nil {
+		s.baseValue = *rec.BaseValue
+	}
+	if rec.BaseSum != nil {
+		s.baseSum = *rec.BaseSum
+	}
+
+	var m senmlMeasurement
+
+	if s.baseName != "" && rec.Name != "" {
+		m.sensorID = strings.TrimRight(s.baseName, ":/")
+		m.metric = rec.Name
+	} else {
+		name := s.baseName + rec.Name
+		if name == "" {
+			return m, errors.New("record has no name")
+		}
+		if i := strings.LastIndexAny(name, ":/"); i > 0 && i < len(name)-1 {
+			m.sensorID, m.metric = name[:i], name[i+1:]
+		} else {
+			m.sensorID, m.metric = strings.TrimRight(name, ":/"), "value"
+		}
+	}
+
+	switch {
+	case rec.Value != nil:
+		m.value = s.baseValue + *rec.Value
+	case rec.StringValue != nil:
+		m.value = *rec.StringValue
+	case rec.BoolValue != nil:
+		m.value = *rec.BoolValue
+	case rec.DataValue != nil:
+		m.value = *rec.DataValue
+	case rec.Sum != nil:
+		m.value = s.baseSum + *rec.Sum
+	default:
+		return m, fmt.Errorf("record %s has no value", m.metric)
+	}
+
+	t := s.baseTime + rec.Time
+	if math.IsNaN(t) || math.IsInf(t, 0) {
+		return m, errors.New("invalid time")
+	}
+	if math.Abs(t) < senmlRelativeTimeLimit {
+		m.time = s.now.Add(time.Duration(t * float64(time.Second)))
+	} else {
+		m.time = time.Unix(0, int64(t*float64(time.Second))).UTC()
+	}
+
+	m.unit = rec.Unit
+	if m.unit == "" {
+		m.unit = s.baseUnit
+	}
+
+	return m, nil
+}
+
+// SplitSenMLJSONToChunks читает пакет SenML в JSON-представлении.
+func SplitSenMLJSONToChunks(r io.Reader, policy ChunkPolicy, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil {
+		return nil, DecodeStats{}, err
+	}
+	if t != json.Delim('[') {
+		return nil, DecodeStats{}, errors.New("senml pack must be a json array")
+	}
+
+	next := func() (*senmlRecord, error) {
+		if !dec.More() {
+			return nil, nil
+		}
+		var rec senmlRecord
+		if err := dec.Decode(&rec); err != nil {
+			return nil, err
+		}
+		return &rec, nil
+	}
+
+	return splitSenMLToChunks(next, policy, convert)
+}
+
+// SplitSenMLCBORToChunks читает пакет SenML в CBOR-представлении.
+func SplitSenMLCBORToChunks(r io.Reader, policy ChunkPolicy, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	var pack []senmlRecord
+	if err := cbor.NewDecoder(r).Decode(&pack); err != nil {
+		return nil, DecodeStats{}, fmt.Errorf("decode senml cbor: %w", err)
+	}
+
+	i := 0
+	next := func() (*senmlRecord, error) {
+		if i >= len(pack) {
+			return nil, nil
+		}
+		i++
+		return &pack[i-1], nil
+	}
+
+	return splitSenMLToChunks(next, policy, convert)
+}
+
+// splitSenMLToChunks склеивает подряд идущие измерения одного датчика
+// с одинаковым временем в одну запись: {"sensor_id", "timestamp",
+// <метрика>: значение, "units": {<метрика>: единица}}.
+func splitSenMLToChunks(nextRaw func() (*senmlRecord, error), policy ChunkPolicy, convert RecordConverter) ([][]byte, DecodeStats, error) {
+	res := &senmlResolver{now: time.Now().UTC()}
+
+	var current map[string]any
+	var currentKey string
+	var pending error
+	done := false
+
+	next := func() (map[string]any, bool, error) {
+		if pending != nil {
+			err := pending
+			pending = nil
+			return nil, true, err
+		}
+
+		for !done {
+			raw, err := nextRaw()
+			if err != nil {
+				return nil, false, err
+			}
+			if raw == nil {
+				done = true
+				break
+			}
+
+			m, err := res.resolve(*raw)
+			if err != nil {
+				if current != nil {
+					pending = malformedRecord("%v", err)
+					out := current
+					current = nil
+					return out, true, nil
+				}
+				return nil, true, malformedRecord("%v", err)
+			}
+
+			key := m.sensorID + "\x00" + m.time.Format(time.RFC3339Nano)
+			if current != nil && key != currentKey {
+				out := current
+				current, currentKey = newSenMLGroup(m), key
+				return out, true, nil
+			}
+			if current == nil {
+				current, currentKey = newSenMLGroup(m), key
+			}
+			current[m.metric] = m.value
+			if m.unit != "" {
+				current["units"].(map[string]any)[m.metric] = m.unit
+			}
+		}
+
+		if current != nil {
+			out := current
+			current = nil
+			return out, true, nil
+		}
+		return nil, false, nil
+	}
+
+	return splitRecordsToChunks(next, policy, convert)
+}
+
+func newSenMLGroup(m senmlMeasurement) map[string]any {
+	g := map[string]any{
+		"sensor_id": m.sensorID,
+		"timestamp": m.time,
+		"units":     map[string]any{},
+	}
+	g[m.metric] = m.value
+	if m.unit != "" {
+		g["units"].(map[string]any)[m.metric] = m.unit
+	}
+	return g
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// senmlSample — пример из RFC 8428 §5.1.4 плюс запись без значения.
+const senmlSample = `[
+	{"bn":"urn:dev:ow:10e2073a01080063:","bt":1.320078429e+09,"bu":"%RH","v":20,"n":"humidity"},
+	{"u":"Cel","v":23.1,"n":"temperature"},
+	{"n":"broken"},
+	{"u":"%RH","v":20.2,"n":"humidity","t":60},
+	{"u":"Cel","v":23.3,"n":"temperature","t":60}
+]`
+
+func checkSenMLChunks(t *testing.T, chunks [][]byte, stats DecodeStats) {
+	t.Helper()
+	if stats.Records != 2 || stats.Malformed != 1 || len(chunks) != 1 {
+		t.Fatalf("got %d chunks, stats %+v", len(chunks), stats)
+	}
+
+	var recs []map[string]any
+	if err := json.Unmarshal(chunks[0], &recs); err != nil {
+		t.Fatal(err)
+	}
+
+	first := recs[0]
+	if first["sensor_id"] != "urn:dev:ow:10e2073a01080063" || first["timestamp"] != "2011-10-31T16:27:09Z" {
+		t.Errorf("unexpected first record: %v", first)
+	}
+	if first["humidity"] != 20.0 || first["temperature"] != 23.1 {
+		t.Errorf("unexpected values: %v", first)
+	}
+	units := first["units"].(map[string]any)
+	if units["humidity"] != "%RH" || units["temperature"] != "Cel" {
+		t.Errorf("unexpected units: %v", units)
+	}
+	if recs[1]["timestamp"] != "2011-10-31T16:28:09Z" || recs[1]["temperature"] != 23.3 {
+		t.Errorf("unexpected second record: %v", recs[1])
+	}
+}
+
+func TestSplitSenMLJSONToChunks(t *testing.T) {
+	chunks, stats, err := SplitSenMLJSONToChunks(strings.NewReader(senmlSample), ChunkPolicy{MaxRecords: 10}, passRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSenMLChunks(t, chunks, stats)
+}
+
+func TestSplitSenMLCBORToChunks(t *testing.T) {
+	var pack []senmlRecord
+	if err := json.Unmarshal([]byte(senmlSample), &pack); err != nil {
+		t.Fatal(err)
+	}
+	data, err := cbor.Marshal(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, stats, err := SplitSenMLCBORToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 10}, passRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSenMLChunks(t, chunks, stats)
+}
+
+func TestSenMLNameWithoutBaseName(t *testing.T) {
+	input := `[{"n":"dev-7/pressure","v":1013.2,"t":1.7e9},{"n":"lonely","vb":true,"t":1.7e9}]`
+
+	chunks, stats, err := SplitSenMLJSONToChunks(strings.NewReader(input), ChunkPolicy{MaxRecords: 10}, passRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 2 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	var recs []map[string]any
+	json.Unmarshal(chunks[0], &recs)
+	if recs[0]["sensor_id"] != "dev-7" || recs[0]["pressure"] != 1013.2 {
+		t.Errorf("unexpected record: %v", recs[0])
+	}
+	if recs[1]["sensor_id"] != "lonely" || recs[1]["value"] != true {
+		t.Errorf("unexpected record: %v", recs[1])
+	}
+}
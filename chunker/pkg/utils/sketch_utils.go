@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// StatPercentiles — перцентили, которые попадают в статистику метрик.
+var StatPercentiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// Percentiles считает точные перцентили qs по значениям (линейная
+// интерполяция). values сортируется на месте.
+func Percentiles(values []float64, qs []float64) []float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	out := make([]float64, len(qs))
+	for i, q := range qs {
+		out[i] = quantile(values, q)
+	}
+	return out
+}
+
+// DefaultSketchAccuracy — относительная погрешность квантилей DDSketch.
+const DefaultSketchAccuracy = 0.01
+
+// MaxSketchBins ограничивает число корзин одного знака: при переполнении
+// сливаются корзины самых малых по модулю значений, точность хвостов
+// сохраняется.
+const MaxSketchBins = 2048
+
+// DDSketch — сливаемый скетч квантилей (DDSketch): значение v попадает
+// в корзину ceil(log_γ |v|), γ = (1+α)/(1−α), и любой квантиль
+// восстанавливается с относительной погрешностью α. Скетчи чанков
+// сливаются в скетч задания без потери точности.
+type DDSketch struct {
+	Alpha float64     `json:"alpha"`
+	Count float64     `json:"count"`
+	Zero  float64     `json:"zero,omitempty"`
+	Pos   SketchStore `json:"pos"`
+	Neg   SketchStore `json:"neg"`
+}
+
+// SketchStore — счётчики подряд идущих корзин начиная с индекса Offset.
+type SketchStore struct {
+	Offset int       `json:"offset"`
+	Counts []float64 `json:"counts,omitempty"`
+}
+
+// minSketchValue — меньшие по модулю значения считаются нулём.
+const minSketchValue = 1e-9
+
+func NewDDSketch(alpha float64) *DDSketch {
+	return &DDSketch{Alpha: alpha}
+}
+
+func (s *DDSketch) gamma() float64 { return (1 + s.Alpha) / (1 - s.Alpha) }
+
+// index возвращает корзину положительного значения v; у NaN и +Inf
+// корзины нет.
+func (s *DDSketch) index(v float64) (int, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) || v <= 0 {
+		return 0, false
+	}
+	return int(math.Ceil(math.Log(v) / math.Log(s.gamma()))), true
+}
+
+// value — оценка значений корзины i с относительной погрешностью α.
+func (s *DDSketch) value(i int) float64 {
+	g := s.gamma()
+	return 2 * math.Pow(g, float64(i)) / (g + 1)
+}
+
+// Add учитывает значение. NaN и ±Inf пропускаются и не входят в Count.
+func (s *DDSketch) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	s.Count++
+	if math.Abs(v) < minSketchValue {
+		s.Zero++
+		return
+	}
+	store := &s.Pos
+	if v < 0 {
+		store, v = &s.Neg, -v
+	}
+	i, _ := s.index(v)
+	store.add(i, 1)
+}
+
+// Merge добавляет к скетчу другой скетч с той же точностью.
+func (s *DDSketch) Merge(o *DDSketch) error {
+	if o == nil || o.Count == 0 {
+		return nil
+	}
+	if s.Alpha != o.Alpha {
+		return fmt.Errorf("cannot merge sketches with accuracy %g and %g", s.Alpha, o.Alpha)
+	}
+	s.Count += o.Count
+	s.Zero += o.Zero
+	for i, c := range o.Pos.Counts {
+		s.Pos.add(o.Pos.Offset+i, c)
+	}
+	for i, c := range o.Neg.Counts {
+		s.Neg.add(o.Neg.Offset+i, c)
+	}
+	return nil
+}
+
+// Quantile возвращает оценку квантиля q ∈ [0, 1]; для пустого скетча — 0.
+func (s *DDSketch) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := q * (s.Count - 1)
+
+	// отрицательные значения — от больших по модулю к меньшим
+	var seen float64
+	for i := len(s.Neg.Counts) - 1; i >= 0; i-- {
+		seen += s.Neg.Counts[i]
+		if seen > rank {
+			return -s.value(s.Neg.Offset + i)
+		}
+	}
+	seen += s.Zero
+	if seen > rank {
+		return 0
+	}
+	for i, c := range s.Pos.Counts {
+		seen += c
+		if seen > rank {
+			return s.value(s.Pos.Offset + i)
+		}
+	}
+	return s.value(s.Pos.Offset + len(s.Pos.Counts) - 1)
+}
+
+func (st *SketchStore) add(i int, c float64) {
+	if len(st.Counts) == 0 {
+		st.Offset = i
+		st.Counts = []float64{c}
+		return
+	}
+	if i < st.Offset {
+		grown := make([]float64, st.Offset-i+len(st.Counts))
+		copy(grown[st.Offset-i:], st.Counts)
+		st.Counts = grown
+		st.Offset = i
+	}
+	for i-st.Offset >= len(st.Counts) {
+		st.Counts = append(st.Counts, 0)
+	}
+	st.Counts[i-st.Offset] += c
+	st.collapse()
+}
+
+// collapse сливает младшие корзины, пока их не станет MaxSketchBins.
+func (st *SketchStore) collapse() {
+	extra := len(st.Counts) - MaxSketchBins
+	if extra <= 0 {
+		return
+	}
+	var sum float64
+	for _, c := range st.Counts[:extra+1] {
+		sum += c
+	}
+	st.Counts = append([]float64{sum}, st.Counts[extra+1:]...)
+	st.Offset += extra
+}
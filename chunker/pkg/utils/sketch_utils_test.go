@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDDSketchQuantilesWithinAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, 10000)
+	s := NewDDSketch(DefaultSketchAccuracy)
+	for i := range values {
+		values[i] = math.Exp(rng.NormFloat64()) * 20
+		s.Add(values[i])
+	}
+	exact := Percentiles(values, StatPercentiles)
+	for i, q := range StatPercentiles {
+		got := s.Quantile(q)
+		if math.Abs(got-exact[i])/exact[i] > 2*DefaultSketchAccuracy {
+			t.Errorf("p%g: sketch %g, exact %g", q*100, got, exact[i])
+		}
+	}
+}
+
+func TestDDSketchMerge(t *testing.T) {
+	whole, a, b := NewDDSketch(0.01), NewDDSketch(0.01), NewDDSketch(0.01)
+	for i := -500; i <= 1500; i++ {
+		v := float64(i) / 10
+		whole.Add(v)
+		if i%2 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []float64{0, 0.1, 0.5, 0.99, 1} {
+		if a.Quantile(q) != whole.Quantile(q) {
+			t.Errorf("q%g: merged %g, whole %g", q, a.Quantile(q), whole.Quantile(q))
+		}
+	}
+	if a.Quantile(0) > -49 || a.Quantile(1) < 149 {
+		t.Fatalf("range [%g, %g]", a.Quantile(0), a.Quantile(1))
+	}
+	if err := a.Merge(NewDDSketch(0.05)); err != nil {
+		t.Fatal("empty sketch must merge")
+	}
+	other := NewDDSketch(0.05)
+	other.Add(1)
+	if err := a.Merge(other); err == nil {
+		t.Fatal("expected accuracy mismatch error")
+	}
+}
+
+func TestDDSketchCollapseKeepsTail(t *testing.T) {
+	s := NewDDSketch(0.01)
+	for e := -300; e <= 300; e++ {
+		s.Add(math.Pow(10, float64(e)/10))
+	}
+	if len(s.Pos.Counts) > MaxSketchBins {
+		t.Fatalf("%d bins", len(s.Pos.Counts))
+	}
+	if got := s.Quantile(1); math.Abs(got-1e30)/1e30 > 0.01 {
+		t.Fatalf("max = %g", got)
+	}
+}
+
+func TestDDSketchSkipsNonFinite(t *testing.T) {
+	s := NewDDSketch(0.01)
+	for _, v := range []float64{math.Inf(1), math.NaN(), math.Inf(-1), 20} {
+		s.Add(v)
+	}
+	if s.Count != 1 || math.Abs(s.Quantile(0.5)-20)/20 > 0.01 {
+		t.Fatalf("count %g, median %g", s.Count, s.Quantile(0.5))
+	}
+	if _, ok := s.index(math.Inf(1)); ok {
+		t.Error("+Inf has a bin")
+	}
+}
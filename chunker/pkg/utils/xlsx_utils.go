@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// SplitXLSXToChunks построчно читает лист книги Excel и режет его на
+// CSV-чанки того же вида, что и SplitCSVToChunks: первая непустая строка
+// листа — заголовок, он идёт первой записью первого чанка. Пустой sheet
+// означает первый лист книги.
+//
+// Книга сбрасывается во временный файл (не больше MaxSpoolSize), строки
+// листа читаются потоком, но чанки возвращаются все сразу. Сжатая книга
+// во много раз меньше своих листов, поэтому MaxSplitInputSize
+// сравнивается с распакованным размером книги.
+func SplitXLSXToChunks(r io.Reader, policy ChunkPolicy, sheet string) ([][]byte, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	tmp, size, err := spoolToTempFile(r, "chunker-*.xlsx")
+	if err != nil {
+		return nil, fmt.Errorf("spool xlsx file: %w", err)
+	}
+	defer removeTempFile(tmp)
+	if err := checkUnzippedSize(tmp, size); err != nil {
+		return nil, err
+	}
+
+	f, err := excelize.OpenFile(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx file: %w", err)
+	}
+	defer f.Close()
+
+	sheet, err = resolveSheet(f, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("read sheet %s: %w", sheet, err)
+	}
+	defer rows.Close()
+
+	var chunks [][]byte
+	var buf *bytes.Buffer
+	// строка сначала кодируется отдельно, чтобы знать её размер в CSV
+	var line bytes.Buffer
+	writer := csv.NewWriter(&line)
+	width := 0
+	count := 0
+
+	for rowNum := 1; rows.Next(); rowNum++ {
+		cells, err := rows.Columns()
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s row %d: %w", sheet, rowNum, err)
+		}
+		if isBlankRow(cells) {
+			continue
+		}
+
+		if width == 0 {
+			width = len(cells)
+		}
+		record, err := fitRow(cells, width)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s row %d: %w", sheet, rowNum, err)
+		}
+
+		line.Reset()
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+
+		if buf != nil && !policy.fits(count, buf.Len(), line.Len()) {
+			chunks = append(chunks, buf.Bytes())
+			buf, count = nil, 0
+		}
+		if buf == nil {
+			buf = new(bytes.Buffer)
+		}
+		buf.Write(line.Bytes())
+		count++
+	}
+	if err := rows.Error(); err != nil {
+		return nil, fmt.Errorf("read sheet %s: %w", sheet, err)
+	}
+
+	if buf != nil {
+		chunks = append(chunks, buf.Bytes())
+	}
+
+	return chunks, nil
+}
+
+// checkUnzippedSize сверяет суммарный распакованный размер zip-контейнера
+// с MaxSplitInputSize по заголовкам записей.
+func checkUnzippedSize(f io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return fmt.Errorf("open xlsx file: %w", err)
+	}
+	var total uint64
+	for _, entry := range zr.File {
+		total += entry.UncompressedSize64
+		if total > uint64(MaxSplitInputSize) {
+			return ErrInputTooLarge
+		}
+	}
+	return nil
+}
+
+func resolveSheet(f *excelize.File, sheet string) (string, error) {
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+	if sheet == "" {
+		return sheets[0], nil
+	}
+	for _, s := range sheets {
+		if strings.EqualFold(s, sheet) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("sheet %q not found, available: %s", sheet, strings.Join(sheets, ", "))
+}
+
+func isBlankRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// fitRow выравнивает строку по ширине заголовка: excelize обрезает пустые
+// ячейки справа, а CSV-читатели воркеров ждут одинаковое число полей.
+func fitRow(cells []string, width int) ([]string, error) {
+	if len(cells) < width {
+		return append(cells, make([]string, width-len(cells))...), nil
+	}
+	for _, c := range cells[width:] {
+		if strings.TrimSpace(c) != "" {
+			return nil, fmt.Errorf("row has %d cells, header has %d", len(cells), width)
+		}
+	}
+	return cells[:width], nil
+}
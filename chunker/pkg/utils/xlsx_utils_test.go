@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func xlsxSample(t *testing.T) []byte {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if _, err := f.NewSheet("Readings"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]any{
+		{"sensor_id", "temperature", "humidity"},
+		{"s1", 20.5, 40},
+		{},
+		{"s2", 21},
+		{"s3", 22, 45, ""},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Readings", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSplitXLSXToChunks(t *testing.T) {
+	chunks, err := SplitXLSXToChunks(bytes.NewReader(xlsxSample(t)), ChunkPolicy{MaxRecords: 3}, "readings")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := joinCSVChunks(t, chunks)
+	want := [][]string{
+		{"sensor_id", "temperature", "humidity"},
+		{"s1", "20.5", "40"},
+		{"s2", "21", ""},
+		{"s3", "22", "45"},
+	}
+	if len(chunks) != 2 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %d chunks with %q", len(chunks), got)
+	}
+
+	if _, err := csv.NewReader(bytes.NewReader(chunks[1])).ReadAll(); err != nil {
+		t.Fatalf("second chunk is not valid csv: %v", err)
+	}
+}
+
+func TestSplitXLSXToChunksSheetSelection(t *testing.T) {
+	data := xlsxSample(t)
+
+	chunks, err := SplitXLSXToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 10}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 0 {
+		t.Fatalf("default sheet should be the empty Sheet1, got %d chunks", len(chunks))
+	}
+
+	if _, err := SplitXLSXToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 10}, "Missing"); err == nil {
+		t.Fatal("expected error for unknown sheet")
+	}
+}
+
+func TestSplitXLSXToChunksUnzippedSizeLimit(t *testing.T) {
+	defer func(limit int64) { MaxSplitInputSize = limit }(MaxSplitInputSize)
+	data := xlsxSample(t)
+
+	// сжатая книга укладывается в предел, распакованная — нет
+	MaxSplitInputSize = int64(len(data))
+	if _, err := SplitXLSXToChunks(bytes.NewReader(data), ChunkPolicy{MaxRecords: 10}, "Readings"); !errors.Is(err, ErrInputTooLarge) {
+		t.Fatalf("err = %v, want ErrInputTooLarge", err)
+	}
+}
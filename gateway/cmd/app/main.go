@@ -13,6 +13,7 @@ import (
 	redisGo "gateway/pkg/client/redis"
 	s3ClientGo "gateway/pkg/client/s3"
 	"gateway/pkg/middleware"
+	"gateway/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -37,6 +38,10 @@ type Config struct {
 	S3Bucket    string
 	S3AccessKey string
 	S3SecretKey string
+	S3TLS       s3ClientGo.TLSConfig
+	S3SSE       s3.SSEMode
+	// S3KeyStoreKeyFile — мастер-ключ хранилища ключей SSE-C тенантов.
+	S3KeyStoreKeyFile string
 
 	RabbitMQURL string
 }
@@ -74,19 +79,31 @@ func main() {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(&entity.Job{}); err != nil {
+	if err := db.AutoMigrate(&entity.Job{}, &entity.ProtoSchema{}, &entity.MappingProfile{}, &entity.TenantKey{}, &entity.AlertRule{}, &entity.SensorBaseline{}); err != nil {
 		panic(err)
 	}
 
+	schemaRepo := psqlRepo.NewGormSchemaRepo(db)
+	mappingRepo := psqlRepo.NewGormMappingRepo(db)
+	ruleRepo := psqlRepo.NewGormAlertRuleRepo(db)
+	baselineRepo := psqlRepo.NewGormBaselineRepo(db)
+	var tenantKeys s3.CustomerKeyStore
+	if cfg.S3SSE == s3.SSEC {
+		masterKey, err := utils.ReadKeyFile(cfg.S3KeyStoreKeyFile)
+		if err != nil {
+			log.Fatalf("failed to load key store master key: %v", err)
+		}
+		tenantKeys = psqlRepo.NewGormTenantKeyRepo(db, masterKey)
+	}
 	psqlRepo := psqlRepo.NewGormJobRepo(db)
 
 	redisRepo := redis.NewRedisRepo(redisClient)
 
-	s3Client, err := s3ClientGo.NewS3Client(cfg.S3Host, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket)
+	s3Client, err := s3ClientGo.NewS3Client(cfg.S3Host, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3TLS)
 	if err != nil {
 		panic(err)
 	}
-	s3Repo := s3.NewS3Repo(s3Client)
+	s3Repo := s3.NewS3Repo(s3Client, cfg.S3SSE, tenantKeys)
 
 	conn, err := amqp.Dial(cfg.RabbitMQURL)
 	if err != nil {
@@ -99,13 +116,31 @@ func main() {
 		log.Fatalf("failed to init publisher: %v", err)
 	}
 
-	uc := usecase.NewJobUseCase(redisRepo, s3Repo, psqlRepo, jobPublisher)
+	uc := usecase.NewJobUseCase(redisRepo, s3Repo, psqlRepo, jobPublisher, mappingRepo)
 	handler := v1.NewJobHandler(uc)
+	schemaHandler := v1.NewSchemaHandler(usecase.NewSchemaUseCase(schemaRepo))
+	mappingHandler := v1.NewMappingHandler(usecase.NewMappingUseCase(mappingRepo))
+	ruleHandler := v1.NewAlertRuleHandler(usecase.NewAlertRuleUseCase(ruleRepo))
+	baselineHandler := v1.NewBaselineHandler(usecase.NewBaselineUseCase(baselineRepo))
 
 	v1Group := r.Group("/api/v1")
 	{
 		v1Group.POST("/jobs", handler.CreateJob)
 		v1Group.GET("/jobs/:job_id/status", handler.GetStatus)
+		v1Group.GET("/jobs/:job_id/result", handler.GetResult)
+		v1Group.GET("/jobs/:job_id/artifacts/:name", handler.DownloadArtifact)
+		v1Group.PUT("/schemas/protobuf", schemaHandler.RegisterProtoSchema)
+		v1Group.GET("/schemas/protobuf", schemaHandler.GetProtoSchema)
+		v1Group.GET("/mappings", mappingHandler.ListProfiles)
+		v1Group.GET("/mappings/:name", mappingHandler.GetProfile)
+		v1Group.PUT("/mappings/:name", mappingHandler.SaveProfile)
+		v1Group.DELETE("/mappings/:name", mappingHandler.DeleteProfile)
+		v1Group.GET("/rules", ruleHandler.ListRules)
+		v1Group.GET("/rules/:name", ruleHandler.GetRule)
+		v1Group.PUT("/rules/:name", ruleHandler.SaveRule)
+		v1Group.DELETE("/rules/:name", ruleHandler.DeleteRule)
+		v1Group.GET("/baselines", baselineHandler.ListBaselines)
+		v1Group.GET("/baselines/:sensor_id", baselineHandler.GetBaseline)
 	}
 
 	err = r.Run(":8080")
@@ -152,6 +187,27 @@ func loadConfig() Config {
 	rmqPort := mustGetEnv("RABBITMQ_PORT")
 	rabbitMQURL := "amqp://" + rmqUser + ":" + rmqPassword + "@" + rmqHost + ":" + rmqPort + "/"
 
+	// S3
+	var s3TLS bool
+	if v := os.Getenv("S3_USE_TLS"); v != "" {
+		s3TLS, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("Invalid S3_USE_TLS value: %v", err)
+		}
+	}
+	s3SSE, err := s3.ParseSSEMode(os.Getenv("S3_SSE"))
+	if err != nil {
+		log.Fatalf("Invalid S3_SSE value: %v", err)
+	}
+	s3KeyStoreKeyFile := os.Getenv("S3_SSE_KEY_STORE_KEY_FILE")
+	if s3SSE == s3.SSEC && s3KeyStoreKeyFile == "" {
+		log.Fatalf("Environment variable S3_SSE_KEY_STORE_KEY_FILE is required for S3_SSE=sse-c")
+	}
+	// ключ SSE-C уходит в заголовках каждого запроса: без TLS он открыт
+	if s3SSE == s3.SSEC && !s3TLS {
+		log.Fatalf("S3_SSE=sse-c requires S3_USE_TLS=true")
+	}
+
 	return Config{
 		RedisAddr: redisHost + ":" + redisPort,
 		RedisDB:   redisDB,
@@ -167,6 +223,10 @@ func loadConfig() Config {
 		S3Bucket:    mustGetEnv("S3_BUCKET"),
 		S3AccessKey: mustGetEnv("S3_ACCESS_KEY"),
 		S3SecretKey: mustGetEnv("S3_SECRET_KEY"),
+		S3TLS:       s3ClientGo.TLSConfig{Enabled: s3TLS, CAFile: os.Getenv("S3_CA_FILE")},
+		S3SSE:       s3SSE,
+
+		S3KeyStoreKeyFile: s3KeyStoreKeyFile,
 
 		RabbitMQURL: rabbitMQURL,
 	}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"context"
+	"gateway/internal/domain/entity"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type AlertRuleUseCase interface {
+	SaveRule(ctx context.Context, tenantID, name string, rule entity.AlertRule) (*entity.AlertRule, error)
+	GetRule(ctx context.Context, tenantID, name string) (*entity.AlertRule, error)
+	ListRules(ctx context.Context, tenantID string) ([]entity.AlertRule, error)
+	DeleteRule(ctx context.Context, tenantID, name string) error
+}
+
+type AlertRuleHandler struct {
+	UseCase AlertRuleUseCase
+}
+
+func NewAlertRuleHandler(u AlertRuleUseCase) *AlertRuleHandler {
+	return &AlertRuleHandler{UseCase: u}
+}
+
+type saveAlertRuleRequest struct {
+	Expression string `json:"expression" binding:"required"`
+	Severity   string `json:"severity"`
+	Enabled    *bool  `json:"enabled"`
+}
+
+// SaveRule сохраняет правило под именем из пути. Без поля enabled
+// правило включено.
+func (h *AlertRuleHandler) SaveRule(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	var req saveAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := entity.AlertRule{Expression: req.Expression, Severity: req.Severity, Enabled: req.Enabled == nil || *req.Enabled}
+	saved, err := h.UseCase.SaveRule(c.Request.Context(), userID.(string), c.Param("name"), rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *AlertRuleHandler) GetRule(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	rule, err := h.UseCase.GetRule(c.Request.Context(), userID.(string), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *AlertRuleHandler) ListRules(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	rules, err := h.UseCase.ListRules(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (h *AlertRuleHandler) DeleteRule(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	if err := h.UseCase.DeleteRule(c.Request.Context(), userID.(string), c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"context"
+	"gateway/internal/domain/entity"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type BaselineUseCase interface {
+	GetBaseline(ctx context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error)
+	ListBaselines(ctx context.Context, tenantID string) ([]entity.SensorBaseline, error)
+}
+
+type BaselineHandler struct {
+	UseCase BaselineUseCase
+}
+
+func NewBaselineHandler(u BaselineUseCase) *BaselineHandler {
+	return &BaselineHandler{UseCase: u}
+}
+
+func (h *BaselineHandler) GetBaseline(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	baseline, err := h.UseCase.GetBaseline(c.Request.Context(), userID.(string), c.Param("sensor_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "baseline not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, baseline)
+}
+
+func (h *BaselineHandler) ListBaselines(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	baselines, err := h.UseCase.ListBaselines(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"baselines": baselines})
+}
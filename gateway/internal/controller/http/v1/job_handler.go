@@ -2,16 +2,25 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"gateway/internal/domain/entity"
+	"gateway/internal/domain/usecase"
+	"gateway/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type JobUseCase interface {
-	CreateJob(ctx context.Context, fileBytes []byte, fileName, userID string) (*entity.Job, error)
-	GetStatus(ctx context.Context, jobID string) (entity.JobStatus, string, error)
+	CreateJob(ctx context.Context, fileBytes []byte, fileName, userID string, opts entity.JobOptions) (*entity.Job, error)
+	GetStatus(ctx context.Context, jobID, userID string) (entity.JobStatus, *entity.PresignedURL, *entity.QualityReport, error)
+	GetResult(ctx context.Context, jobID, userID string) (*entity.JobResult, map[string]*entity.PresignedURL, error)
+	DownloadArtifact(ctx context.Context, jobID, userID, name string) (io.ReadCloser, int64, string, error)
 }
 
 type JobHandler struct {
@@ -50,7 +59,98 @@ func (h *JobHandler) CreateJob(c *gin.Context) {
 
 	bytes, _ := io.ReadAll(f)
 
-	job, err := h.UseCase.CreateJob(c.Request.Context(), bytes, file.Filename, userID.(string))
+	opts := entity.JobOptions{
+		Sheet:     c.PostForm("sheet"),
+		Precision: c.PostForm("precision"),
+		// Формат проверяет chunker: список форматов знает только его реестр.
+		Format:      strings.ToLower(strings.TrimSpace(c.PostForm("format"))),
+		ContentType: file.Header.Get("Content-Type"),
+	}
+	if schema := c.PostForm("schema"); schema != "" {
+		if err := utils.ValidateJSONSchema([]byte(schema)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts.Schema = json.RawMessage(schema)
+	}
+	if mapping := c.PostForm("mapping"); mapping != "" {
+		opts.Mapping = &entity.ColumnMapping{}
+		if err := json.Unmarshal([]byte(mapping), opts.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mapping: " + err.Error()})
+			return
+		}
+	}
+	opts.MappingProfile = c.PostForm("mapping_profile")
+	// Пустое encrypt_fields отключает шифрование, отсутствие — поля по умолчанию.
+	if fields, ok := c.GetPostForm("encrypt_fields"); ok {
+		opts.EncryptFields = []string{}
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				opts.EncryptFields = append(opts.EncryptFields, f)
+			}
+		}
+	}
+	if redact := c.PostForm("redact"); redact != "" {
+		if err := json.Unmarshal([]byte(redact), &opts.Redact); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid redact: " + err.Error()})
+			return
+		}
+	}
+	// Детекторы проверяет chunker: список детекторов знает только его реестр.
+	if anomalies := c.PostForm("anomalies"); anomalies != "" {
+		if err := json.Unmarshal([]byte(anomalies), &opts.Anomalies); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anomalies: " + err.Error()})
+			return
+		}
+	}
+	if ranges := c.PostForm("quality_ranges"); ranges != "" {
+		if err := json.Unmarshal([]byte(ranges), &opts.QualityRanges); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quality_ranges: " + err.Error()})
+			return
+		}
+	}
+	if resample := c.PostForm("resample"); resample != "" {
+		opts.Resample = &entity.ResampleSpec{}
+		if err := json.Unmarshal([]byte(resample), opts.Resample); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resample: " + err.Error()})
+			return
+		}
+	}
+	if correlation := c.PostForm("correlation"); correlation != "" {
+		opts.Correlation = &entity.CorrelationSpec{}
+		if err := json.Unmarshal([]byte(correlation), opts.Correlation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid correlation: " + err.Error()})
+			return
+		}
+	}
+	if partition := c.PostForm("partition"); partition != "" {
+		opts.Partition = &entity.PartitionSpec{}
+		if err := json.Unmarshal([]byte(partition), opts.Partition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid partition: " + err.Error()})
+			return
+		}
+	}
+	if records, size := c.PostForm("chunk_records"), c.PostForm("chunk_bytes"); records != "" || size != "" {
+		opts.Chunking = &entity.ChunkPolicy{}
+		if records != "" {
+			if opts.Chunking.MaxRecords, err = strconv.Atoi(records); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk_records: " + err.Error()})
+				return
+			}
+		}
+		if size != "" {
+			if opts.Chunking.MaxBytes, err = strconv.Atoi(size); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk_bytes: " + err.Error()})
+				return
+			}
+		}
+	}
+
+	job, err := h.UseCase.CreateJob(c.Request.Context(), bytes, file.Filename, userID.(string), opts)
+	if errors.Is(err, usecase.ErrInvalidJobOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -60,16 +160,85 @@ func (h *JobHandler) CreateJob(c *gin.Context) {
 }
 
 func (h *JobHandler) GetStatus(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
 	jobID := c.Param("job_id")
-	status, url, err := h.UseCase.GetStatus(c.Request.Context(), jobID)
+	status, url, quality, err := h.UseCase.GetStatus(c.Request.Context(), jobID, userID.(string))
+	if errors.Is(err, usecase.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{"job_id": jobID, "status": status}
+	if quality != nil {
+		resp["quality"] = quality
+	}
+	if url != nil {
+		resp["file_url"] = url.URL
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetResult отдаёт итоги анализа задания и ссылки на его артефакты.
+func (h *JobHandler) GetResult(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	jobID := c.Param("job_id")
+	result, urls, err := h.UseCase.GetResult(c.Request.Context(), jobID, userID.(string))
+	if errors.Is(err, usecase.ErrJobNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	artifacts := gin.H{}
+	for name, url := range urls {
+		artifacts[name] = gin.H{"url": url.URL}
+	}
+	c.JSON(http.StatusOK, gin.H{"job_id": jobID, "gaps": result.Gaps, "quality": result.Quality, "metrics": result.Metrics, "anomalies": result.Anomalies, "correlation": result.Correlation, "artifacts": artifacts})
+}
 
-	if url != "" {
-		c.JSON(http.StatusOK, gin.H{"job_id": jobID, "status": status, "file_url": url})
+// DownloadArtifact отдаёт артефакт задания через gateway — так
+// скачиваются объекты, зашифрованные ключом тенанта (SSE-C).
+func (h *JobHandler) DownloadArtifact(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"job_id": jobID, "status": status})
+
+	name := c.Param("name")
+	rc, size, fileName, err := h.UseCase.DownloadArtifact(c.Request.Context(), c.Param("job_id"), userID.(string), name)
+	if errors.Is(err, usecase.ErrJobNotFound) || errors.Is(err, usecase.ErrArtifactNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rc.Close()
+
+	contentType := "application/octet-stream"
+	if name == entity.ArtifactReport {
+		contentType = "application/pdf"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, rc, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": fileName}),
+	})
 }
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"context"
+	"gateway/internal/domain/entity"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type MappingUseCase interface {
+	SaveProfile(ctx context.Context, tenantID, name string, mapping entity.ColumnMapping) (*entity.MappingProfile, error)
+	GetProfile(ctx context.Context, tenantID, name string) (*entity.MappingProfile, error)
+	ListProfiles(ctx context.Context, tenantID string) ([]entity.MappingProfile, error)
+	DeleteProfile(ctx context.Context, tenantID, name string) error
+}
+
+type MappingHandler struct {
+	UseCase MappingUseCase
+}
+
+func NewMappingHandler(u MappingUseCase) *MappingHandler {
+	return &MappingHandler{UseCase: u}
+}
+
+// SaveProfile принимает ColumnMapping в теле запроса и сохраняет его
+// под именем из пути.
+func (h *MappingHandler) SaveProfile(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	var mapping entity.ColumnMapping
+	if err := c.ShouldBindJSON(&mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := h.UseCase.SaveProfile(c.Request.Context(), userID.(string), c.Param("name"), mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *MappingHandler) GetProfile(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	profile, err := h.UseCase.GetProfile(c.Request.Context(), userID.(string), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mapping profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *MappingHandler) ListProfiles(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	profiles, err := h.UseCase.ListProfiles(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+func (h *MappingHandler) DeleteProfile(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	if err := h.UseCase.DeleteProfile(c.Request.Context(), userID.(string), c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mapping profile not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"context"
+	"gateway/internal/domain/entity"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+type SchemaUseCase interface {
+	RegisterProtoSchema(ctx context.Context, tenantID, messageName string, descriptorSet []byte) (*entity.ProtoSchema, error)
+	GetProtoSchema(ctx context.Context, tenantID string) (*entity.ProtoSchema, error)
+}
+
+type SchemaHandler struct {
+	UseCase SchemaUseCase
+}
+
+func NewSchemaHandler(u SchemaUseCase) *SchemaHandler {
+	return &SchemaHandler{UseCase: u}
+}
+
+// RegisterProtoSchema принимает FileDescriptorSet (поле descriptor) и полное
+// имя сообщения (поле message), которым кодируются потоки тенанта.
+func (h *SchemaHandler) RegisterProtoSchema(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	messageName := c.PostForm("message")
+	if messageName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message required"})
+		return
+	}
+
+	file, err := c.FormFile("descriptor")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "descriptor required"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	descriptorSet, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	schema, err := h.UseCase.RegisterProtoSchema(c.Request.Context(), userID.(string), messageName, descriptorSet)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": schema.MessageName, "updated_at": schema.UpdatedAt})
+}
+
+func (h *SchemaHandler) GetProtoSchema(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id required"})
+		return
+	}
+
+	schema, err := h.UseCase.GetProtoSchema(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schema not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": schema.MessageName, "updated_at": schema.UpdatedAt})
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// AlertRule — правило оповещения тенанта на языке правил
+// (temperature > 80 for 5m and humidity < 20). Срабатывания правил
+// воркеры записывают в аномалии чанков.
+type AlertRule struct {
+	TenantID   string    `json:"-" gorm:"primaryKey;type:uuid"`
+	Name       string    `json:"name" gorm:"primaryKey"`
+	Expression string    `json:"expression" gorm:"not null"`
+	Severity   string    `json:"severity" gorm:"not null"`
+	Enabled    bool      `json:"enabled" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
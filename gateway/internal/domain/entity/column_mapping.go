@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"units"
+)
+
+// Поля SensorReading, на которые можно отобразить колонки источника.
+const (
+	FieldTimestamp   = "timestamp"
+	FieldSensorID    = "sensor_id"
+	FieldTemperature = "temperature"
+	FieldHumidity    = "humidity"
+	FieldPressure    = "pressure"
+)
+
+// metricNameRe — имя произвольной метрики (co2, pm2_5, battery_v).
+var metricNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// ColumnMapping сопоставляет полям SensorReading колонки или JSON-пути
+// источника. Поля без сопоставления ищутся по обычным синонимам. Кроме
+// основных полей можно сопоставить произвольные метрики: их значения не
+// переводятся, а единица сохраняется как есть.
+type ColumnMapping struct {
+	Fields map[string]FieldMapping `json:"fields"`
+}
+
+type FieldMapping struct {
+	// Source — имя колонки или путь вида payload.values[0].temp.
+	Source string `json:"source"`
+	// Unit — единица значения в источнике.
+	Unit string `json:"unit,omitempty"`
+	// Scale и Offset применяются до перевода единиц: v*Scale + Offset.
+	Scale  *float64 `json:"scale,omitempty"`
+	Offset float64  `json:"offset,omitempty"`
+	// Format — формат времени для timestamp: rfc3339, unix_s, unix_ms,
+	// unix_us, unix_ns или Go-раскладка.
+	Format string `json:"format,omitempty"`
+}
+
+func (m ColumnMapping) Validate() error {
+	if len(m.Fields) == 0 {
+		return fmt.Errorf("mapping has no fields")
+	}
+	for field, fm := range m.Fields {
+		if strings.TrimSpace(fm.Source) == "" {
+			return fmt.Errorf("field %s: source required", field)
+		}
+		switch field {
+		case FieldTimestamp:
+			if fm.Unit != "" || fm.Scale != nil || fm.Offset != 0 {
+				return fmt.Errorf("field %s: only format is supported", field)
+			}
+		case FieldSensorID:
+			if fm.Unit != "" || fm.Scale != nil || fm.Offset != 0 || fm.Format != "" {
+				return fmt.Errorf("field %s: conversions are not supported", field)
+			}
+		case FieldTemperature, FieldHumidity, FieldPressure:
+			if fm.Format != "" {
+				return fmt.Errorf("field %s: format is only supported for timestamp", field)
+			}
+			// единицы проверяются по тем же таблицам, по которым chunker
+			// переводит значения
+			if fm.Unit != "" {
+				if _, err := units.ParseUnit(units.Quantity(field), fm.Unit); err != nil {
+					return fmt.Errorf("field %s: unknown unit %s, supported: %s", field, fm.Unit, strings.Join(units.Units(units.Quantity(field)), ", "))
+				}
+			}
+		default:
+			if !metricNameRe.MatchString(field) {
+				return fmt.Errorf("invalid metric name %s: lowercase letters, digits and _", field)
+			}
+			if fm.Format != "" {
+				return fmt.Errorf("field %s: format is only supported for timestamp", field)
+			}
+		}
+	}
+	return nil
+}
+
+func (m ColumnMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *ColumnMapping) Scan(src any) error {
+	*m = ColumnMapping{}
+	return scanJSONB(src, m)
+}
@@ -9,11 +9,22 @@ type JobStatus string
 
 const (
 	StatusPending   JobStatus = "PENDING"
+	StatusChunking  JobStatus = "CHUNKING"
 	StatusRunning   JobStatus = "RUNNING"
 	StatusCompleted JobStatus = "COMPLETED"
 	StatusFailed    JobStatus = "FAILED"
 )
 
+// JobStatusRecord — запись статуса задания в redis: её пишут gateway при
+// создании задания и chunker при смене статуса, по ней отвечает опрос
+// статуса.
+type JobStatusRecord struct {
+	UserID string    `json:"user_id"`
+	Status JobStatus `json:"status"`
+	// Quality — сводка качества данных без разбивки по датчикам.
+	Quality *QualityReport `json:"quality,omitempty"`
+}
+
 type Job struct {
 	JobID     string    `gorm:"primaryKey;type:uuid"`
 	UserID    string    `gorm:"not null;type:uuid"`
@@ -22,4 +33,9 @@ type Job struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Options      JobOptions     `gorm:"type:jsonb"`
+	Diagnostics  JobDiagnostics `gorm:"type:jsonb"`
+	SchemaReport SchemaReport   `gorm:"type:jsonb"`
+	Result       JobResult      `gorm:"type:jsonb"`
 }
@@ -4,4 +4,6 @@ type JobCreatedMessage struct {
 	JobID   string `json:"job_id"`
 	UserID  string `json:"user_id"`
 	FileKey string `json:"file_key"`
+
+	Options JobOptions `json:"options"`
 }
@@ -0,0 +1,292 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JobOptions — параметры обработки, заданные при создании задания.
+type JobOptions struct {
+	// Sheet — лист книги .xlsx, пусто = первый лист.
+	Sheet string `json:"sheet,omitempty"`
+	// Precision — единицы отметок времени line protocol (ns, us, ms, s).
+	Precision string `json:"precision,omitempty"`
+	// Format — явно заданный формат (имя или MIME-тип), важнее расширения.
+	Format string `json:"format,omitempty"`
+	// ContentType — Content-Type загруженного файла.
+	ContentType string `json:"content_type,omitempty"`
+	// Schema — JSON Schema одной записи; пусто = контракт SensorReading.
+	Schema json.RawMessage `json:"schema,omitempty"`
+	// Mapping — сопоставление колонок полям SensorReading. Если задан
+	// MappingProfile, сюда копируется сохранённый профиль.
+	Mapping        *ColumnMapping `json:"mapping,omitempty"`
+	MappingProfile string         `json:"mapping_profile,omitempty"`
+	// Chunking заменяет политику нарезки chunker для этого задания.
+	Chunking *ChunkPolicy `json:"chunking,omitempty"`
+	// Partition — разбиение записей на разделы, nil = чанки по порядку записей.
+	Partition *PartitionSpec `json:"partition,omitempty"`
+	// EncryptFields — поля чанков, шифруемые ключом задания: поля
+	// SensorReading или пути metrics.<имя>, tags.<имя>, extra.<имя>
+	// (см. ValidateEncryptFields). nil = поля по умолчанию chunker, пустой
+	// список = без шифрования.
+	EncryptFields []string `json:"encrypt_fields"`
+	// Redact — правила обработки чувствительных полей до записи чанков.
+	Redact []RedactionRule `json:"redact,omitempty"`
+	// Anomalies — детекторы аномалий для воркеров. nil = набор по умолчанию
+	// chunker, пустой список = без поиска аномалий.
+	Anomalies []DetectorSpec `json:"anomalies"`
+	// Resample — передискретизация рядов датчиков на этапе анализа, nil =
+	// без неё.
+	Resample *ResampleSpec `json:"resample,omitempty"`
+	// QualityRanges — правдоподобные диапазоны метрик для оценки качества
+	// поверх диапазонов по умолчанию chunker.
+	QualityRanges map[string]ValueRange `json:"quality_ranges,omitempty"`
+	// Correlation — параметры анализа корреляций и совместных аномалий,
+	// nil = параметры по умолчанию.
+	Correlation *CorrelationSpec `json:"correlation,omitempty"`
+}
+
+// CorrelationSpec — параметры анализа совместных аномалий датчиков.
+// Длительности — как в Go: 30s, 5m; пустые и нулевые поля = значения по
+// умолчанию.
+type CorrelationSpec struct {
+	// Interval — интервал, по средним которого сравниваются ряды (1m).
+	Interval string `json:"interval,omitempty"`
+	// Tolerance — насколько разнесённые во времени аномалии разных
+	// датчиков считаются одним инцидентом (1m).
+	Tolerance string `json:"tolerance,omitempty"`
+	// MinCorrelation — модуль коэффициента, с которого пара рядов попадает
+	// в отчёт (0.7).
+	MinCorrelation float64 `json:"min_correlation,omitempty"`
+}
+
+// ValueRange — допустимые значения метрики в канонических единицах.
+type ValueRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// DetectorSpec — детектор аномалий и его параметры; какие поля нужны,
+// зависит от детектора.
+type DetectorSpec struct {
+	Name       string   `json:"name"`
+	Metrics    []string `json:"metrics,omitempty"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	Threshold  float64  `json:"threshold,omitempty"`
+	Window     int      `json:"window,omitempty"`
+	MinSamples int      `json:"min_samples,omitempty"`
+}
+
+// ResampleSpec — интервал передискретизации и обработка разрывов.
+// Длительности — как в Go: 30s, 5m, 1h.
+type ResampleSpec struct {
+	Interval string `json:"interval"`
+	// Aggregate — mean, last или max; пусто = mean.
+	Aggregate string `json:"aggregate,omitempty"`
+	// GapThreshold — перерыв между показаниями, с которого он попадает
+	// в отчёт о разрывах; не меньше интервала, пусто = два интервала.
+	GapThreshold string `json:"gap_threshold,omitempty"`
+	// Fill — linear или previous; пусто = пустые интервалы пропускаются.
+	Fill string `json:"fill,omitempty"`
+}
+
+// ChunkPolicy — предел чанка по числу записей и/или по размеру в байтах;
+// чанк закрывается по тому пределу, что наступит раньше. Ноль — без предела.
+type ChunkPolicy struct {
+	MaxRecords int `json:"max_records,omitempty"`
+	MaxBytes   int `json:"max_bytes,omitempty"`
+}
+
+// PartitionSpec — разбиение записей на разделы по датчику и/или окну
+// времени: все записи задания из раздела попадают в один чанк.
+type PartitionSpec struct {
+	// Sensor — exact (раздел на датчик) или hash (Buckets корзин по хэшу
+	// sensor_id), пусто — без разбиения по датчику.
+	Sensor  string `json:"sensor,omitempty"`
+	Buckets int    `json:"buckets,omitempty"`
+	// Window — hour или day, пусто — без окон.
+	Window string `json:"window,omitempty"`
+}
+
+const (
+	PartitionSensorExact = "exact"
+	PartitionSensorHash  = "hash"
+	PartitionWindowHour  = "hour"
+	PartitionWindowDay   = "day"
+)
+
+// RedactionRule — обработка чувствительного поля: колонки CSV или пути
+// в JSON-записи через точку (owner.name, location.lat). Для Avro,
+// protobuf, line protocol и SenML путь задаётся в декодированной записи
+// до сопоставления колонок (tags.owner).
+type RedactionRule struct {
+	Field  string `json:"field"`
+	Action string `json:"action"`
+	// Precision — знаков после запятой для coarsen.
+	Precision int `json:"precision,omitempty"`
+}
+
+const (
+	RedactDrop     = "drop"
+	RedactHash     = "hash"
+	RedactTokenize = "tokenize"
+	RedactCoarsen  = "coarsen"
+)
+
+func (o JobOptions) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+func (o *JobOptions) Scan(src any) error {
+	*o = JobOptions{}
+	return scanJSONB(src, o)
+}
+
+func (p ChunkPolicy) Validate() error {
+	if p.MaxRecords < 0 || p.MaxBytes < 0 {
+		return errors.New("chunk limits must not be negative")
+	}
+	if p.MaxRecords == 0 && p.MaxBytes == 0 {
+		return errors.New("set max_records, max_bytes or both")
+	}
+	return nil
+}
+
+// MaxPartitionBuckets ограничивает число корзин при разбиении по хэшу.
+const MaxPartitionBuckets = 4096
+
+func (p PartitionSpec) Validate() error {
+	switch p.Sensor {
+	case "", PartitionSensorExact:
+		if p.Buckets != 0 {
+			return errors.New("buckets require sensor partitioning by hash")
+		}
+	case PartitionSensorHash:
+		if p.Buckets <= 0 || p.Buckets > MaxPartitionBuckets {
+			return fmt.Errorf("buckets must be in 1..%d", MaxPartitionBuckets)
+		}
+	default:
+		return fmt.Errorf("unknown sensor partitioning %s, supported: %s, %s", p.Sensor, PartitionSensorExact, PartitionSensorHash)
+	}
+	switch p.Window {
+	case "", PartitionWindowHour, PartitionWindowDay:
+	default:
+		return fmt.Errorf("unknown window %s, supported: %s, %s", p.Window, PartitionWindowHour, PartitionWindowDay)
+	}
+	if p.Sensor == "" && p.Window == "" {
+		return errors.New("set sensor, window or both")
+	}
+	return nil
+}
+
+const (
+	ResampleMean    = "mean"
+	ResampleLast    = "last"
+	ResampleMax     = "max"
+	GapFillLinear   = "linear"
+	GapFillPrevious = "previous"
+)
+
+func (r ResampleSpec) Validate() error {
+	interval, err := time.ParseDuration(r.Interval)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid interval %q", r.Interval)
+	}
+	if r.GapThreshold != "" {
+		d, err := time.ParseDuration(r.GapThreshold)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid gap_threshold %q", r.GapThreshold)
+		}
+		if d < interval {
+			return fmt.Errorf("gap_threshold %s is shorter than interval %s", d, interval)
+		}
+	}
+	switch r.Aggregate {
+	case "", ResampleMean, ResampleLast, ResampleMax:
+	default:
+		return fmt.Errorf("unknown aggregate %s, supported: %s, %s, %s", r.Aggregate, ResampleMean, ResampleLast, ResampleMax)
+	}
+	switch r.Fill {
+	case "", GapFillLinear, GapFillPrevious:
+	default:
+		return fmt.Errorf("unknown fill %s, supported: %s, %s", r.Fill, GapFillLinear, GapFillPrevious)
+	}
+	return nil
+}
+
+func (s CorrelationSpec) Validate() error {
+	if s.Interval != "" {
+		if d, err := time.ParseDuration(s.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid interval %q", s.Interval)
+		}
+	}
+	if s.Tolerance != "" {
+		if d, err := time.ParseDuration(s.Tolerance); err != nil || d < 0 {
+			return fmt.Errorf("invalid tolerance %q", s.Tolerance)
+		}
+	}
+	if s.MinCorrelation < 0 || s.MinCorrelation > 1 {
+		return fmt.Errorf("min_correlation must be in 0..1")
+	}
+	return nil
+}
+
+// encryptableFields — поля записи SensorReading, которые можно шифровать;
+// ключи вложенных объектов адресуются путём "<объект>.<ключ>".
+var (
+	encryptableFields  = map[string]bool{"temperature": true, "humidity": true, "pressure": true, "metrics": true, "tags": true, "extra": true}
+	encryptableObjects = map[string]bool{"metrics": true, "tags": true, "extra": true}
+)
+
+// ValidateEncryptFields проверяет, что каждое поле может встретиться
+// в чанке: chunker пишет чанки записями SensorReading, и поле, которого
+// в них нет (co2 вместо metrics.co2), молча осталось бы открытым.
+func ValidateEncryptFields(fields []string) error {
+	for _, f := range fields {
+		if encryptableFields[f] {
+			continue
+		}
+		if parent, name, ok := strings.Cut(f, "."); ok && name != "" && encryptableObjects[parent] {
+			continue
+		}
+		if !strings.Contains(f, ".") {
+			return fmt.Errorf("unknown field %s, use temperature, humidity, pressure or metrics.%s", f, f)
+		}
+		return fmt.Errorf("unknown field %s, nested fields are metrics.<name>, tags.<name> and extra.<name>", f)
+	}
+	return nil
+}
+
+func (r ValueRange) Validate() error {
+	if r.Min > r.Max {
+		return fmt.Errorf("min %g is greater than max %g", r.Min, r.Max)
+	}
+	return nil
+}
+
+// MaxRedactPrecision — предел знаков после запятой для coarsen.
+const MaxRedactPrecision = 8
+
+func (r RedactionRule) Validate() error {
+	if r.Field == "" {
+		return errors.New("field is required")
+	}
+	switch r.Action {
+	case RedactDrop, RedactHash, RedactTokenize:
+		if r.Precision != 0 {
+			return fmt.Errorf("field %s: precision applies only to %s", r.Field, RedactCoarsen)
+		}
+	case RedactCoarsen:
+		if r.Precision < 0 || r.Precision > MaxRedactPrecision {
+			return fmt.Errorf("field %s: precision must be in 0..%d", r.Field, MaxRedactPrecision)
+		}
+	default:
+		return fmt.Errorf("field %s: unknown action %s, supported: %s, %s, %s, %s", r.Field, r.Action, RedactDrop, RedactHash, RedactTokenize, RedactCoarsen)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// MappingProfile — сохранённое тенантом сопоставление колонок, которое
+// можно указать при загрузке вместо полного описания.
+type MappingProfile struct {
+	TenantID  string        `json:"-" gorm:"primaryKey;type:uuid"`
+	Name      string        `json:"name" gorm:"primaryKey"`
+	Mapping   ColumnMapping `json:"mapping" gorm:"type:jsonb;not null"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
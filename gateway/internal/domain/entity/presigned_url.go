@@ -0,0 +1,8 @@
+package entity
+
+// PresignedURL — ссылка на скачивание объекта: подписанная ссылка S3 или,
+// если объект зашифрован ключом тенанта (SSE-C), путь скачивания через
+// gateway.
+type PresignedURL struct {
+	URL string
+}
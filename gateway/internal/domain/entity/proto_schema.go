@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// ProtoSchema — дескриптор Protobuf-сообщения, зарегистрированный тенантом
+// для разбора его потоков телеметрии.
+type ProtoSchema struct {
+	TenantID      string `gorm:"primaryKey;type:uuid"`
+	MessageName   string `gorm:"not null"`
+	DescriptorSet []byte `gorm:"not null"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// SensorBaseline — история датчика тенанта по завершённым заданиям. Её
+// ведут воркеры, gateway только отдаёт.
+type SensorBaseline struct {
+	TenantID  string          `json:"-" gorm:"primaryKey;type:uuid"`
+	SensorID  string          `json:"sensor_id" gorm:"primaryKey"`
+	Metrics   BaselineMetrics `json:"metrics" gorm:"type:jsonb;not null"`
+	LastJobID string          `json:"last_job_id"`
+	Jobs      int             `json:"jobs"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// BaselineMetrics — профили по именам метрик.
+type BaselineMetrics map[string]*MetricProfile
+
+// MetricProfile — скользящие моменты метрики и сезонные профили по часу
+// суток и дню недели (UTC, воскресенье — 0).
+type MetricProfile struct {
+	Overall Moments     `json:"overall"`
+	Hourly  [24]Moments `json:"hourly"`
+	Weekday [7]Moments  `json:"weekday"`
+}
+
+// Moments — вес, среднее и сумма квадратов отклонений; вес дробный,
+// потому что старая история затухает.
+type Moments struct {
+	N    float64 `json:"n"`
+	Mean float64 `json:"mean"`
+	M2   float64 `json:"m2"`
+}
+
+func (m BaselineMetrics) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *BaselineMetrics) Scan(src any) error {
+	*m = BaselineMetrics{}
+	return scanJSONB(src, m)
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// TenantKey — ключ SSE-C тенанта для объектов в S3. Key хранится
+// зашифрованным мастер-ключом хранилища ключей.
+type TenantKey struct {
+	TenantID  string `gorm:"primaryKey;type:uuid"`
+	Key       []byte `gorm:"not null"`
+	CreatedAt time.Time
+}
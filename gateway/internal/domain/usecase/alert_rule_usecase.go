@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"gateway/internal/domain/entity"
+	"rules"
+	"time"
+)
+
+type AlertRuleRepo interface {
+	SaveAlertRule(ctx context.Context, rule *entity.AlertRule) error
+	GetAlertRule(ctx context.Context, tenantID, name string) (*entity.AlertRule, error)
+	ListAlertRules(ctx context.Context, tenantID string) ([]entity.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, tenantID, name string) error
+}
+
+type AlertRuleUseCase struct {
+	Repo AlertRuleRepo
+}
+
+func NewAlertRuleUseCase(r AlertRuleRepo) *AlertRuleUseCase {
+	return &AlertRuleUseCase{Repo: r}
+}
+
+// SaveRule проверяет выражение правила и сохраняет его под именем name.
+// Пустая важность — warning.
+func (u *AlertRuleUseCase) SaveRule(ctx context.Context, tenantID, name string, rule entity.AlertRule) (*entity.AlertRule, error) {
+	if name == "" {
+		return nil, fmt.Errorf("rule name required")
+	}
+	if _, err := rules.ParseRule(rule.Expression); err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	switch rule.Severity {
+	case "":
+		rule.Severity = entity.SeverityWarning
+	case entity.SeverityWarning, entity.SeverityCritical:
+	default:
+		return nil, fmt.Errorf("unknown severity %q, supported: %s, %s", rule.Severity, entity.SeverityWarning, entity.SeverityCritical)
+	}
+
+	rule.TenantID = tenantID
+	rule.Name = name
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+
+	if err := u.Repo.SaveAlertRule(ctx, &rule); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (u *AlertRuleUseCase) GetRule(ctx context.Context, tenantID, name string) (*entity.AlertRule, error) {
+	return u.Repo.GetAlertRule(ctx, tenantID, name)
+}
+
+func (u *AlertRuleUseCase) ListRules(ctx context.Context, tenantID string) ([]entity.AlertRule, error) {
+	return u.Repo.ListAlertRules(ctx, tenantID)
+}
+
+func (u *AlertRuleUseCase) DeleteRule(ctx context.Context, tenantID, name string) error {
+	return u.Repo.DeleteAlertRule(ctx, tenantID, name)
+}
@@ -0,0 +1,27 @@
+package usecase
+
+import (
+	"context"
+	"gateway/internal/domain/entity"
+)
+
+type BaselineRepo interface {
+	GetBaseline(ctx context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error)
+	ListBaselines(ctx context.Context, tenantID string) ([]entity.SensorBaseline, error)
+}
+
+type BaselineUseCase struct {
+	Repo BaselineRepo
+}
+
+func NewBaselineUseCase(r BaselineRepo) *BaselineUseCase {
+	return &BaselineUseCase{Repo: r}
+}
+
+func (u *BaselineUseCase) GetBaseline(ctx context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error) {
+	return u.Repo.GetBaseline(ctx, tenantID, sensorID)
+}
+
+func (u *BaselineUseCase) ListBaselines(ctx context.Context, tenantID string) ([]entity.SensorBaseline, error) {
+	return u.Repo.ListBaselines(ctx, tenantID)
+}
@@ -7,19 +7,26 @@ import (
 	"fmt"
 	"gateway/internal/domain/entity"
 	"gateway/pkg/utils"
+	"io"
+	"net/url"
+	"path"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type JobStatusRepo interface {
-	SetStatus(ctx context.Context, jobID, status string) error
-	GetStatus(ctx context.Context, jobID string) (string, error)
+	SetStatus(ctx context.Context, jobID string, rec entity.JobStatusRecord) error
+	SetStatusIfAbsent(ctx context.Context, jobID string, rec entity.JobStatusRecord) error
+	// GetStatus возвращает nil, если записи нет.
+	GetStatus(ctx context.Context, jobID string) (*entity.JobStatusRecord, error)
 }
 
 type S3Uploader interface {
-	GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
-	Upload(ctx context.Context, key string, file []byte) error
+	GetPresignedURL(ctx context.Context, tenantID, key string, expiry time.Duration) (*entity.PresignedURL, error)
+	Upload(ctx context.Context, tenantID, key string, file []byte) error
+	Download(ctx context.Context, tenantID, key string) (io.ReadCloser, int64, error)
+	DirectDownloads() bool
 }
 
 type PsqlJobRepo interface {
@@ -37,22 +44,98 @@ type JobUseCase struct {
 	S3Repo       S3Uploader
 	PostgresRepo PsqlJobRepo
 	Publisher    Publisher
+	MappingRepo  MappingRepo
 }
 
-func NewJobUseCase(r JobStatusRepo, s3 S3Uploader, psql PsqlJobRepo, pub Publisher) *JobUseCase {
+func NewJobUseCase(r JobStatusRepo, s3 S3Uploader, psql PsqlJobRepo, pub Publisher, mappings MappingRepo) *JobUseCase {
 	return &JobUseCase{
 		RedisRepo:    r,
 		PostgresRepo: psql,
 		S3Repo:       s3,
 		Publisher:    pub,
+		MappingRepo:  mappings,
 	}
 }
 
-func (u *JobUseCase) CreateJob(ctx context.Context, fileBytes []byte, fileName, userID string) (*entity.Job, error) {
+// ErrInvalidJobOptions — параметры задания не прошли проверку, это ошибка
+// клиента, а не сервиса.
+var ErrInvalidJobOptions = errors.New("invalid job options")
+
+// ErrJobNotFound — задания нет или оно принадлежит другому пользователю;
+// чужие задания неотличимы от несуществующих.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrArtifactNotFound — у задания нет артефакта с таким именем.
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// resolveMapping подставляет сохранённый профиль вместо имени, чтобы
+// задание не зависело от его последующих изменений.
+func (u *JobUseCase) resolveMapping(ctx context.Context, userID string, opts *entity.JobOptions) error {
+	if opts.MappingProfile != "" {
+		if opts.Mapping != nil {
+			return fmt.Errorf("%w: mapping and mapping_profile are mutually exclusive", ErrInvalidJobOptions)
+		}
+		profile, err := u.MappingRepo.GetMappingProfile(ctx, userID, opts.MappingProfile)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJobOptions, err)
+		}
+		opts.Mapping = &profile.Mapping
+	}
+	if opts.Mapping != nil {
+		if err := opts.Mapping.Validate(); err != nil {
+			return fmt.Errorf("%w: mapping: %v", ErrInvalidJobOptions, err)
+		}
+	}
+	return nil
+}
+
+func (u *JobUseCase) CreateJob(ctx context.Context, fileBytes []byte, fileName, userID string, opts entity.JobOptions) (*entity.Job, error) {
+	if err := u.resolveMapping(ctx, userID, &opts); err != nil {
+		return nil, err
+	}
+	if opts.Chunking != nil {
+		if err := opts.Chunking.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: chunking: %v", ErrInvalidJobOptions, err)
+		}
+	}
+	if opts.Partition != nil {
+		if err := opts.Partition.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: partition: %v", ErrInvalidJobOptions, err)
+		}
+	}
+	if opts.Resample != nil {
+		if err := opts.Resample.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: resample: %v", ErrInvalidJobOptions, err)
+		}
+	}
+	if opts.Correlation != nil {
+		if err := opts.Correlation.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: correlation: %v", ErrInvalidJobOptions, err)
+		}
+	}
+	if err := entity.ValidateEncryptFields(opts.EncryptFields); err != nil {
+		return nil, fmt.Errorf("%w: encrypt_fields: %v", ErrInvalidJobOptions, err)
+	}
+	for metric, r := range opts.QualityRanges {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: quality_ranges: %s: %v", ErrInvalidJobOptions, metric, err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, rule := range opts.Redact {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: redact: %v", ErrInvalidJobOptions, err)
+		}
+		if seen[rule.Field] {
+			return nil, fmt.Errorf("%w: redact: duplicate rule for field %s", ErrInvalidJobOptions, rule.Field)
+		}
+		seen[rule.Field] = true
+	}
+
 	jobID := uuid.New().String()
 	s3Key := "jobs/" + jobID + "/" + fileName
 
-	if err := u.S3Repo.Upload(ctx, s3Key, fileBytes); err != nil {
+	if err := u.S3Repo.Upload(ctx, userID, s3Key, fileBytes); err != nil {
 		return nil, err
 	}
 
@@ -61,6 +144,7 @@ func (u *JobUseCase) CreateJob(ctx context.Context, fileBytes []byte, fileName,
 		UserID:    userID,
 		FileKey:   s3Key,
 		Status:    entity.StatusPending,
+		Options:   opts,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -69,7 +153,7 @@ func (u *JobUseCase) CreateJob(ctx context.Context, fileBytes []byte, fileName,
 		return nil, err
 	}
 
-	if err := u.RedisRepo.SetStatus(ctx, jobID, string(job.Status)); err != nil {
+	if err := u.RedisRepo.SetStatus(ctx, jobID, entity.JobStatusRecord{UserID: userID, Status: job.Status}); err != nil {
 		return nil, err
 	}
 
@@ -77,6 +161,7 @@ func (u *JobUseCase) CreateJob(ctx context.Context, fileBytes []byte, fileName,
 		JobID:   jobID,
 		UserID:  userID,
 		FileKey: s3Key,
+		Options: opts,
 	}
 
 	msgJson, err := utils.ToRawMessage(msgStruct)
@@ -90,21 +175,109 @@ func (u *JobUseCase) CreateJob(ctx context.Context, fileBytes []byte, fileName,
 
 	return job, nil
 }
-func (u *JobUseCase) GetStatus(ctx context.Context, jobID string) (entity.JobStatus, string, error) {
-	statusStr, err := u.RedisRepo.GetStatus(ctx, jobID)
+
+// ownJob возвращает задание пользователя userID.
+func (u *JobUseCase) ownJob(ctx context.Context, jobID, userID string) (*entity.Job, error) {
+	job, err := u.PostgresRepo.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrJobNotFound, err)
+	}
+	if job.UserID != userID {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// artifactLink выдаёт ссылку на артефакт задания: подписанную ссылку S3
+// или, если хранилище их не выдаёт (SSE-C), путь скачивания через gateway.
+func (u *JobUseCase) artifactLink(ctx context.Context, tenantID, jobID, name, key string) (*entity.PresignedURL, error) {
+	if !u.S3Repo.DirectDownloads() {
+		return &entity.PresignedURL{URL: fmt.Sprintf("/api/v1/jobs/%s/artifacts/%s", jobID, url.PathEscape(name))}, nil
+	}
+	return u.S3Repo.GetPresignedURL(ctx, tenantID, key, 24*time.Hour)
+}
+
+// reportKey — ключ PDF-отчёта, который chunker пишет при завершении
+// задания.
+func reportKey(jobID string) string {
+	return fmt.Sprintf("jobs/%s/result.pdf", jobID)
+}
+
+// statusRecord возвращает запись статуса задания из redis. Если записи
+// нет (истёк срок), она собирается из Postgres и кэшируется.
+func (u *JobUseCase) statusRecord(ctx context.Context, jobID string) (*entity.JobStatusRecord, error) {
+	rec, err := u.RedisRepo.GetStatus(ctx, jobID)
+	if err != nil || rec != nil {
+		return rec, err
+	}
+	job, err := u.PostgresRepo.GetJob(ctx, jobID)
 	if err != nil {
-		return "", "", err
+		return nil, fmt.Errorf("%w: %v", ErrJobNotFound, err)
+	}
+	rec = &entity.JobStatusRecord{UserID: job.UserID, Status: job.Status, Quality: job.Result.Quality.Summary()}
+	if err := u.RedisRepo.SetStatusIfAbsent(ctx, jobID, *rec); err != nil {
+		return nil, err
 	}
+	return rec, nil
+}
 
-	if statusStr == string(entity.StatusCompleted) {
-		key := fmt.Sprintf("jobs/%s/result.pdf", jobID)
-		presignedURL, err := u.S3Repo.GetPresignedURL(ctx, key, 24*time.Hour)
+// GetStatus возвращает статус задания пользователя, ссылку на отчёт
+// завершённого задания и сводку качества данных без разбивки по датчикам.
+// Опрос обслуживается записью статуса в redis, Postgres читается только
+// при её отсутствии.
+func (u *JobUseCase) GetStatus(ctx context.Context, jobID, userID string) (entity.JobStatus, *entity.PresignedURL, *entity.QualityReport, error) {
+	rec, err := u.statusRecord(ctx, jobID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if rec.UserID != userID {
+		return "", nil, nil, ErrJobNotFound
+	}
+	if rec.Status != entity.StatusCompleted {
+		return rec.Status, nil, rec.Quality, nil
+	}
+	link, err := u.artifactLink(ctx, userID, jobID, entity.ArtifactReport, reportKey(jobID))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return rec.Status, link, rec.Quality, nil
+}
+
+// GetResult возвращает итоги анализа задания пользователя и ссылки на его
+// артефакты.
+func (u *JobUseCase) GetResult(ctx context.Context, jobID, userID string) (*entity.JobResult, map[string]*entity.PresignedURL, error) {
+	job, err := u.ownJob(ctx, jobID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	urls := make(map[string]*entity.PresignedURL, len(job.Result.Artifacts))
+	for name, key := range job.Result.Artifacts {
+		link, err := u.artifactLink(ctx, job.UserID, job.JobID, name, key)
 		if err != nil {
-			return "", "", err
+			return nil, nil, err
 		}
-		return entity.JobStatus(statusStr), presignedURL, nil
+		urls[name] = link
+	}
+	return &job.Result, urls, nil
+}
+
+// DownloadArtifact открывает артефакт задания пользователя и возвращает
+// его размер и имя файла. Ключ SSE-C остаётся в gateway.
+func (u *JobUseCase) DownloadArtifact(ctx context.Context, jobID, userID, name string) (io.ReadCloser, int64, string, error) {
+	job, err := u.ownJob(ctx, jobID, userID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	key, ok := job.Result.Artifacts[name]
+	if !ok {
+		return nil, 0, "", ErrArtifactNotFound
+	}
+
+	rc, size, err := u.S3Repo.Download(ctx, job.UserID, key)
+	if err != nil {
+		return nil, 0, "", err
 	}
-	return entity.JobStatus(statusStr), "", nil
+	return rc, size, path.Base(key), nil
 }
 
 func (u *JobUseCase) publishWithRetry(ctx context.Context, msg json.RawMessage) error {
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"gateway/internal/domain/entity"
+	"time"
+)
+
+type MappingRepo interface {
+	SaveMappingProfile(ctx context.Context, profile *entity.MappingProfile) error
+	GetMappingProfile(ctx context.Context, tenantID, name string) (*entity.MappingProfile, error)
+	ListMappingProfiles(ctx context.Context, tenantID string) ([]entity.MappingProfile, error)
+	DeleteMappingProfile(ctx context.Context, tenantID, name string) error
+}
+
+type MappingUseCase struct {
+	Repo MappingRepo
+}
+
+func NewMappingUseCase(r MappingRepo) *MappingUseCase {
+	return &MappingUseCase{Repo: r}
+}
+
+func (u *MappingUseCase) SaveProfile(ctx context.Context, tenantID, name string, mapping entity.ColumnMapping) (*entity.MappingProfile, error) {
+	if name == "" {
+		return nil, fmt.Errorf("profile name required")
+	}
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+
+	profile := &entity.MappingProfile{
+		TenantID:  tenantID,
+		Name:      name,
+		Mapping:   mapping,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := u.Repo.SaveMappingProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (u *MappingUseCase) GetProfile(ctx context.Context, tenantID, name string) (*entity.MappingProfile, error) {
+	return u.Repo.GetMappingProfile(ctx, tenantID, name)
+}
+
+func (u *MappingUseCase) ListProfiles(ctx context.Context, tenantID string) ([]entity.MappingProfile, error) {
+	return u.Repo.ListMappingProfiles(ctx, tenantID)
+}
+
+func (u *MappingUseCase) DeleteProfile(ctx context.Context, tenantID, name string) error {
+	return u.Repo.DeleteMappingProfile(ctx, tenantID, name)
+}
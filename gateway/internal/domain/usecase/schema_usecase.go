@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"gateway/internal/domain/entity"
+	"gateway/pkg/utils"
+	"time"
+)
+
+type SchemaRepo interface {
+	SaveProtoSchema(ctx context.Context, schema *entity.ProtoSchema) error
+	GetProtoSchema(ctx context.Context, tenantID string) (*entity.ProtoSchema, error)
+}
+
+type SchemaUseCase struct {
+	Repo SchemaRepo
+}
+
+func NewSchemaUseCase(r SchemaRepo) *SchemaUseCase {
+	return &SchemaUseCase{Repo: r}
+}
+
+// RegisterProtoSchema проверяет, что в FileDescriptorSet есть нужное
+// сообщение, и сохраняет его как схему тенанта.
+func (u *SchemaUseCase) RegisterProtoSchema(ctx context.Context, tenantID, messageName string, descriptorSet []byte) (*entity.ProtoSchema, error) {
+	if err := utils.ValidateMessageDescriptor(descriptorSet, messageName); err != nil {
+		return nil, err
+	}
+
+	schema := &entity.ProtoSchema{
+		TenantID:      tenantID,
+		MessageName:   messageName,
+		DescriptorSet: descriptorSet,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	if err := u.Repo.SaveProtoSchema(ctx, schema); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func (u *SchemaUseCase) GetProtoSchema(ctx context.Context, tenantID string) (*entity.ProtoSchema, error) {
+	return u.Repo.GetProtoSchema(ctx, tenantID)
+}
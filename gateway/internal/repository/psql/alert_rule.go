@@ -0,0 +1,51 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"gateway/internal/domain/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormAlertRuleRepo struct {
+	DB *gorm.DB
+}
+
+func NewGormAlertRuleRepo(db *gorm.DB) *GormAlertRuleRepo {
+	return &GormAlertRuleRepo{DB: db}
+}
+
+// SaveAlertRule создаёт правило или заменяет правило с тем же именем.
+func (r *GormAlertRuleRepo) SaveAlertRule(ctx context.Context, rule *entity.AlertRule) error {
+	return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expression", "severity", "enabled", "updated_at"}),
+	}).Create(rule).Error
+}
+
+func (r *GormAlertRuleRepo) GetAlertRule(ctx context.Context, tenantID, name string) (*entity.AlertRule, error) {
+	rule := &entity.AlertRule{}
+	if err := r.DB.WithContext(ctx).First(rule, "tenant_id = ? AND name = ?", tenantID, name).Error; err != nil {
+		return nil, fmt.Errorf("alert rule not found: %w", err)
+	}
+	return rule, nil
+}
+
+func (r *GormAlertRuleRepo) ListAlertRules(ctx context.Context, tenantID string) ([]entity.AlertRule, error) {
+	var rules []entity.AlertRule
+	err := r.DB.WithContext(ctx).Where("tenant_id = ?", tenantID).Order("name").Find(&rules).Error
+	return rules, err
+}
+
+func (r *GormAlertRuleRepo) DeleteAlertRule(ctx context.Context, tenantID, name string) error {
+	res := r.DB.WithContext(ctx).Delete(&entity.AlertRule{}, "tenant_id = ? AND name = ?", tenantID, name)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("alert rule not found: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
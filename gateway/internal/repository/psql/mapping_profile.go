@@ -0,0 +1,51 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"gateway/internal/domain/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormMappingRepo struct {
+	DB *gorm.DB
+}
+
+func NewGormMappingRepo(db *gorm.DB) *GormMappingRepo {
+	return &GormMappingRepo{DB: db}
+}
+
+// SaveMappingProfile создаёт профиль или заменяет профиль с тем же именем.
+func (r *GormMappingRepo) SaveMappingProfile(ctx context.Context, profile *entity.MappingProfile) error {
+	return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mapping", "updated_at"}),
+	}).Create(profile).Error
+}
+
+func (r *GormMappingRepo) GetMappingProfile(ctx context.Context, tenantID, name string) (*entity.MappingProfile, error) {
+	profile := &entity.MappingProfile{}
+	if err := r.DB.WithContext(ctx).First(profile, "tenant_id = ? AND name = ?", tenantID, name).Error; err != nil {
+		return nil, fmt.Errorf("mapping profile not found: %w", err)
+	}
+	return profile, nil
+}
+
+func (r *GormMappingRepo) ListMappingProfiles(ctx context.Context, tenantID string) ([]entity.MappingProfile, error) {
+	var profiles []entity.MappingProfile
+	err := r.DB.WithContext(ctx).Where("tenant_id = ?", tenantID).Order("name").Find(&profiles).Error
+	return profiles, err
+}
+
+func (r *GormMappingRepo) DeleteMappingProfile(ctx context.Context, tenantID, name string) error {
+	res := r.DB.WithContext(ctx).Delete(&entity.MappingProfile{}, "tenant_id = ? AND name = ?", tenantID, name)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("mapping profile not found: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
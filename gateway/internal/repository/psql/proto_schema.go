@@ -0,0 +1,34 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"gateway/internal/domain/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormSchemaRepo struct {
+	DB *gorm.DB
+}
+
+func NewGormSchemaRepo(db *gorm.DB) *GormSchemaRepo {
+	return &GormSchemaRepo{DB: db}
+}
+
+// SaveProtoSchema регистрирует дескриптор тенанта, заменяя предыдущий.
+func (r *GormSchemaRepo) SaveProtoSchema(ctx context.Context, schema *entity.ProtoSchema) error {
+	return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"message_name", "descriptor_set", "updated_at"}),
+	}).Create(schema).Error
+}
+
+func (r *GormSchemaRepo) GetProtoSchema(ctx context.Context, tenantID string) (*entity.ProtoSchema, error) {
+	schema := &entity.ProtoSchema{}
+	if err := r.DB.WithContext(ctx).First(schema, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, fmt.Errorf("schema not found: %w", err)
+	}
+	return schema, nil
+}
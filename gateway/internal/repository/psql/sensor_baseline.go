@@ -0,0 +1,31 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"gateway/internal/domain/entity"
+
+	"gorm.io/gorm"
+)
+
+type GormBaselineRepo struct {
+	DB *gorm.DB
+}
+
+func NewGormBaselineRepo(db *gorm.DB) *GormBaselineRepo {
+	return &GormBaselineRepo{DB: db}
+}
+
+func (r *GormBaselineRepo) GetBaseline(ctx context.Context, tenantID, sensorID string) (*entity.SensorBaseline, error) {
+	baseline := &entity.SensorBaseline{}
+	if err := r.DB.WithContext(ctx).First(baseline, "tenant_id = ? AND sensor_id = ?", tenantID, sensorID).Error; err != nil {
+		return nil, fmt.Errorf("baseline not found: %w", err)
+	}
+	return baseline, nil
+}
+
+func (r *GormBaselineRepo) ListBaselines(ctx context.Context, tenantID string) ([]entity.SensorBaseline, error) {
+	var baselines []entity.SensorBaseline
+	err := r.DB.WithContext(ctx).Where("tenant_id = ?", tenantID).Order("sensor_id").Find(&baselines).Error
+	return baselines, err
+}
@@ -0,0 +1,65 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway/internal/domain/entity"
+	"gateway/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormTenantKeyRepo — хранилище ключей SSE-C тенантов. Ключи шифруются
+// мастер-ключом, tenant_id входит в AAD, чтобы запись нельзя было
+// подставить другому тенанту.
+type GormTenantKeyRepo struct {
+	DB        *gorm.DB
+	MasterKey []byte
+}
+
+func NewGormTenantKeyRepo(db *gorm.DB, masterKey []byte) *GormTenantKeyRepo {
+	return &GormTenantKeyRepo{DB: db, MasterKey: masterKey}
+}
+
+// CustomerKey возвращает ключ тенанта, при первом обращении создаёт его.
+func (r *GormTenantKeyRepo) CustomerKey(ctx context.Context, tenantID string) ([]byte, error) {
+	db := r.DB.WithContext(ctx)
+	stored := &entity.TenantKey{}
+	err := db.First(stored, "tenant_id = ?", tenantID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := r.createKey(ctx, tenantID); err != nil {
+			return nil, err
+		}
+		err = db.First(stored, "tenant_id = ?", tenantID).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tenant key not found: %w", err)
+	}
+
+	key, err := utils.OpenAESGCM(r.MasterKey, stored.Key, []byte(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("unseal tenant key: %w", err)
+	}
+	return key, nil
+}
+
+// createKey сохраняет новый ключ тенанта. При гонке двух загрузок
+// остаётся первый ключ, второй отбрасывается.
+func (r *GormTenantKeyRepo) createKey(ctx context.Context, tenantID string) error {
+	key, err := utils.GenerateKey()
+	if err != nil {
+		return err
+	}
+	sealed, err := utils.SealAESGCM(r.MasterKey, key, []byte(tenantID))
+	if err != nil {
+		return err
+	}
+	err = r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.TenantKey{TenantID: tenantID, Key: sealed}).Error
+	if err != nil {
+		return fmt.Errorf("save tenant key: %w", err)
+	}
+	return nil
+}
@@ -2,11 +2,18 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"gateway/internal/domain/entity"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// jobStatusTTL — сколько живёт запись статуса; после неё статус читается
+// из Postgres и кэшируется заново.
+const jobStatusTTL = 24 * time.Hour
+
 type RedisRepo struct {
 	Client *redis.Client
 }
@@ -15,10 +22,40 @@ func NewRedisRepo(client *redis.Client) *RedisRepo {
 	return &RedisRepo{Client: client}
 }
 
-func (r *RedisRepo) SetStatus(ctx context.Context, jobID, status string) error {
-	return r.Client.Set(ctx, "job_status:"+jobID, status, time.Hour).Err()
+func statusKey(jobID string) string {
+	return "job_status:" + jobID
+}
+
+func (r *RedisRepo) SetStatus(ctx context.Context, jobID string, rec entity.JobStatusRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return r.Client.Set(ctx, statusKey(jobID), data, jobStatusTTL).Err()
+}
+
+// SetStatusIfAbsent кэширует запись, только если её ещё нет: запись,
+// которую chunker успел записать после чтения из Postgres, новее.
+func (r *RedisRepo) SetStatusIfAbsent(ctx context.Context, jobID string, rec entity.JobStatusRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return r.Client.SetNX(ctx, statusKey(jobID), data, jobStatusTTL).Err()
 }
 
-func (r *RedisRepo) GetStatus(ctx context.Context, jobID string) (string, error) {
-	return r.Client.Get(ctx, "job_status:"+jobID).Result()
+// GetStatus возвращает запись статуса или nil, если её нет.
+func (r *RedisRepo) GetStatus(ctx context.Context, jobID string) (*entity.JobStatusRecord, error) {
+	data, err := r.Client.Get(ctx, statusKey(jobID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec entity.JobStatusRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
 }
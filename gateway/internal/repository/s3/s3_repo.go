@@ -4,38 +4,94 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"gateway/internal/domain/entity"
 	"gateway/pkg/client/s3"
 	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"io"
 	"net/url"
 	"time"
 )
 
+// SSEMode — шифрование объектов на стороне S3.
+type SSEMode string
+
+const (
+	SSENone SSEMode = ""
+	// SSES3 — ключами хранилища (SSE-S3), прозрачно для чтения.
+	SSES3 SSEMode = "sse-s3"
+	// SSEC — ключом тенанта (SSE-C), который передаётся в каждом запросе.
+	SSEC SSEMode = "sse-c"
+)
+
+func ParseSSEMode(s string) (SSEMode, error) {
+	switch m := SSEMode(s); m {
+	case SSENone, SSES3, SSEC:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown S3 SSE mode %q, want sse-s3 or sse-c", s)
+	}
+}
+
+// CustomerKeyStore выдаёт ключ SSE-C тенанта.
+type CustomerKeyStore interface {
+	CustomerKey(ctx context.Context, tenantID string) ([]byte, error)
+}
+
 type S3Repo struct {
 	StorageS3 *s3.StorageS3
+	SSE       SSEMode
+	Keys      CustomerKeyStore // нужен только для SSEC
 }
 
-func NewS3Repo(storageS3 *s3.StorageS3) *S3Repo {
+func NewS3Repo(storageS3 *s3.StorageS3, sse SSEMode, keys CustomerKeyStore) *S3Repo {
 	return &S3Repo{
 		StorageS3: storageS3,
+		SSE:       sse,
+		Keys:      keys,
+	}
+}
+
+// serverSide возвращает параметры SSE для объектов тенанта, nil = без SSE.
+func (s *S3Repo) serverSide(ctx context.Context, tenantID string) (encrypt.ServerSide, error) {
+	switch s.SSE {
+	case SSES3:
+		return encrypt.NewSSE(), nil
+	case SSEC:
+		if s.Keys == nil {
+			return nil, fmt.Errorf("sse-c requires a customer key store")
+		}
+		key, err := s.Keys.CustomerKey(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("customer key: %w", err)
+		}
+		return encrypt.NewSSEC(key)
+	default:
+		return nil, nil
 	}
 }
 
-func (s *S3Repo) Upload(ctx context.Context, key string, file []byte) error {
+func (s *S3Repo) Upload(ctx context.Context, tenantID, key string, file []byte) error {
 	if s.StorageS3 == nil || s.StorageS3.Client == nil {
 		return fmt.Errorf("s3 client not initialized")
 	}
+	sse, err := s.serverSide(ctx, tenantID)
+	if err != nil {
+		return err
+	}
 
 	reader := bytes.NewReader(file)
 	fileSize := int64(len(file))
 
-	_, err := s.StorageS3.Client.PutObject(
+	_, err = s.StorageS3.Client.PutObject(
 		ctx,
 		s.StorageS3.Bucket,
 		key,
 		reader,
 		fileSize,
 		minio.PutObjectOptions{
-			ContentType: "application/octet-stream",
+			ContentType:          "application/octet-stream",
+			ServerSideEncryption: sse,
 		},
 	)
 	if err != nil {
@@ -45,16 +101,53 @@ func (s *S3Repo) Upload(ctx context.Context, key string, file []byte) error {
 	return nil
 }
 
-func (s *S3Repo) GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+// DirectDownloads — можно ли отдавать клиенту подписанные ссылки. Объект
+// SSE-C читается только с ключом тенанта, а ключ клиенту не выдаётся:
+// такие объекты скачиваются через gateway (Download).
+func (s *S3Repo) DirectDownloads() bool {
+	return s.SSE != SSEC
+}
+
+// GetPresignedURL подписывает ссылку на скачивание. Для SSE-C ссылок нет,
+// см. DirectDownloads.
+func (s *S3Repo) GetPresignedURL(ctx context.Context, tenantID, key string, expiry time.Duration) (*entity.PresignedURL, error) {
 	if s.StorageS3 == nil || s.StorageS3.Client == nil {
-		return "", fmt.Errorf("s3 client not initialized")
+		return nil, fmt.Errorf("s3 client not initialized")
+	}
+	if !s.DirectDownloads() {
+		return nil, fmt.Errorf("sse-c objects cannot be presigned without exposing the customer key")
+	}
+
+	presignedURL, err := s.StorageS3.Client.PresignedGetObject(ctx, s.StorageS3.Bucket, key, expiry, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("presigned get object: %w", err)
 	}
+	return &entity.PresignedURL{URL: presignedURL.String()}, nil
+}
 
-	reqParams := url.Values{}
+// Download открывает объект тенанта на чтение и возвращает его размер.
+// Ключ SSE-C подставляется здесь и не покидает gateway.
+func (s *S3Repo) Download(ctx context.Context, tenantID, key string) (io.ReadCloser, int64, error) {
+	if s.StorageS3 == nil || s.StorageS3.Client == nil {
+		return nil, 0, fmt.Errorf("s3 client not initialized")
+	}
+	opts := minio.GetObjectOptions{}
+	if s.SSE == SSEC {
+		sse, err := s.serverSide(ctx, tenantID)
+		if err != nil {
+			return nil, 0, err
+		}
+		opts.ServerSideEncryption = sse
+	}
 
-	presignedURL, err := s.StorageS3.Client.PresignedGetObject(ctx, s.StorageS3.Bucket, key, expiry, reqParams)
+	obj, err := s.StorageS3.Client.GetObject(ctx, s.StorageS3.Bucket, key, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("s3 get object: %w", err)
+	}
+	info, err := obj.Stat()
 	if err != nil {
-		return "", fmt.Errorf("presigned get object: %w", err)
+		_ = obj.Close()
+		return nil, 0, fmt.Errorf("s3 stat object: %w", err)
 	}
-	return presignedURL.String(), nil
+	return obj, info.Size, nil
 }
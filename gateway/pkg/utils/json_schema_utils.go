@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ValidateJSONSchema проверяет, что схема записи, переданная с заданием,
+// разбирается и компилируется. Сама проверка данных идёт в chunker.
+func ValidateJSONSchema(schema []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return fmt.Errorf("parse json schema: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("record.json", doc); err != nil {
+		return fmt.Errorf("load json schema: %w", err)
+	}
+	if _, err := c.Compile("record.json"); err != nil {
+		return fmt.Errorf("compile json schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ValidateMessageDescriptor проверяет, что сериализованный FileDescriptorSet
+// собирается и содержит сообщение messageName.
+func ValidateMessageDescriptor(descriptorSet []byte, messageName string) error {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(descriptorSet, &set); err != nil {
+		return fmt.Errorf("parse descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return fmt.Errorf("build descriptor registry: %w", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(messageName))
+	if err != nil {
+		return fmt.Errorf("find message %s: %w", messageName, err)
+	}
+	if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+		return fmt.Errorf("%s is not a message", messageName)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package rules
+
+import (
+	"math"
+	"time"
+)
+
+// RuleInput — одна запись потока показаний для правила.
+type RuleInput struct {
+	SensorID string
+	Time     time.Time
+	Metrics  map[string]float64
+	Tags     map[string]string
+}
+
+// RuleEvaluator проверяет правило на потоке показаний в порядке времени.
+// Состояние окон и "for" ведётся отдельно для каждой группы.
+type RuleEvaluator struct {
+	rule   *Rule
+	groups map[string]*ruleState
+}
+
+type ruleState struct {
+	since   map[*ruleFor]time.Time
+	windows map[*ruleOperand][]timedValue
+	matched bool
+}
+
+type timedValue struct {
+	t time.Time
+	v float64
+}
+
+func (r *Rule) NewEvaluator() *RuleEvaluator {
+	return &RuleEvaluator{rule: r, groups: map[string]*ruleState{}}
+}
+
+// Eval учитывает запись и сообщает о срабатывании: true возвращается,
+// когда условие группы становится истинным, а не на каждой записи,
+// пока оно держится. Запись без тега группировки пропускается.
+func (e *RuleEvaluator) Eval(in RuleInput) bool {
+	group := in.SensorID
+	if e.rule.GroupBy != "" {
+		var ok bool
+		if group, ok = in.Tags[e.rule.GroupBy]; !ok {
+			return false
+		}
+	}
+	st := e.groups[group]
+	if st == nil {
+		st = &ruleState{since: map[*ruleFor]time.Time{}, windows: map[*ruleOperand][]timedValue{}}
+		e.groups[group] = st
+	}
+
+	ok := st.eval(e.rule.root, in)
+	fired := ok && !st.matched
+	st.matched = ok
+	return fired
+}
+
+// eval не сокращает and/or: окна и "for" во всех ветках должны видеть
+// каждую запись.
+func (st *ruleState) eval(n ruleNode, in RuleInput) bool {
+	switch n := n.(type) {
+	case *ruleLogic:
+		l, r := st.eval(n.left, in), st.eval(n.right, in)
+		if n.op == "and" {
+			return l && r
+		}
+		return l || r
+	case *ruleNot:
+		return !st.eval(n.expr, in)
+	case *ruleFor:
+		if !st.eval(n.expr, in) {
+			delete(st.since, n)
+			return false
+		}
+		since, ok := st.since[n]
+		if !ok {
+			st.since[n] = in.Time
+			since = in.Time
+		}
+		return in.Time.Sub(since) >= n.d
+	case *ruleCompare:
+		return st.compare(n, in)
+	}
+	return false
+}
+
+func (st *ruleState) compare(c *ruleCompare, in RuleInput) bool {
+	if c.left.isString() {
+		l, lok := st.text(c.left, in)
+		r, rok := st.text(c.right, in)
+		if !lok || !rok {
+			return false
+		}
+		if c.op == "==" {
+			return l == r
+		}
+		return l != r
+	}
+
+	l, lok := st.number(c.left, in)
+	r, rok := st.number(c.right, in)
+	if !lok || !rok {
+		return false
+	}
+	switch c.op {
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case "==":
+		return l == r
+	default:
+		return l != r
+	}
+}
+
+func (st *ruleState) text(o *ruleOperand, in RuleInput) (string, bool) {
+	switch o.kind {
+	case operandSensor:
+		return in.SensorID, true
+	case operandTag:
+		v, ok := in.Tags[o.str]
+		return v, ok
+	default:
+		return o.str, true
+	}
+}
+
+// number возвращает значение операнда; ok == false — метрики нет в записи
+// или окно пусто, сравнение ложно.
+func (st *ruleState) number(o *ruleOperand, in RuleInput) (float64, bool) {
+	switch o.kind {
+	case operandNumber:
+		return o.num, true
+	case operandMetric:
+		v, ok := in.Metrics[o.str]
+		return v, ok
+	case operandAggregate:
+		return st.aggregate(o, in)
+	}
+	return 0, false
+}
+
+func (st *ruleState) aggregate(o *ruleOperand, in RuleInput) (float64, bool) {
+	window := st.windows[o]
+	if v, ok := in.Metrics[o.metric]; ok {
+		window = append(window, timedValue{in.Time, v})
+	}
+	cutoff := in.Time.Add(-o.window)
+	for len(window) > 0 && window[0].t.Before(cutoff) {
+		window = window[1:]
+	}
+	st.windows[o] = window
+	if len(window) == 0 {
+		return 0, o.str == "count"
+	}
+
+	switch o.str {
+	case "count":
+		return float64(len(window)), true
+	case "delta":
+		return window[len(window)-1].v - window[0].v, true
+	}
+	agg := window[0].v
+	sum := 0.0
+	for _, tv := range window {
+		sum += tv.v
+		switch o.str {
+		case "min":
+			agg = math.Min(agg, tv.v)
+		case "max":
+			agg = math.Max(agg, tv.v)
+		}
+	}
+	switch o.str {
+	case "sum":
+		return sum, true
+	case "avg":
+		return sum / float64(len(window)), true
+	}
+	return agg, true
+}
@@ -0,0 +1,405 @@
+// Package rules — язык правил оповещений: разбор выражений (gateway
+// проверяет их при сохранении) и проверка на потоке показаний (chunker).
+// Один модуль для обоих сервисов, чтобы язык не расходился.
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Язык правил оповещений:
+//
+//	rule       = expr [ "by" group ]
+//	expr       = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | atom [ "for" duration ]
+//	atom       = "(" expr ")" | operand op operand
+//	operand    = number | string | field | func "(" metric "," duration ")"
+//	op         = ">" | ">=" | "<" | "<=" | "==" | "=" | "!="
+//	group      = "sensor" | "tags." name
+//
+// Поля: sensor (sensor_id), tags.<имя> — строки, остальные имена — метрики
+// записи. Функции avg, min, max, sum, count, delta считаются по окну
+// показаний группы. "for 5m" — условие держится не меньше пяти минут.
+// Длительности — как в Go (90s, 5m, 1h30m) и дни (7d). Состояние окон
+// и "for" ведётся по группе, по умолчанию — по датчику.
+//
+// Пример: temperature > 80 for 5m and humidity < 20
+
+// RuleAggregates — функции окна.
+var RuleAggregates = []string{"avg", "min", "max", "sum", "count", "delta"}
+
+// Rule — разобранное правило.
+type Rule struct {
+	Source string
+	// GroupBy — пусто (по датчику) или имя тега.
+	GroupBy string
+	root    ruleNode
+}
+
+type ruleNode interface{ ruleNode() }
+
+type ruleLogic struct {
+	op          string // and, or
+	left, right ruleNode
+}
+
+type ruleNot struct{ expr ruleNode }
+
+type ruleFor struct {
+	expr ruleNode
+	d    time.Duration
+}
+
+type ruleCompare struct {
+	op          string
+	left, right *ruleOperand
+}
+
+type operandKind int
+
+const (
+	operandNumber operandKind = iota
+	operandString
+	operandMetric
+	operandSensor
+	operandTag
+	operandAggregate
+)
+
+type ruleOperand struct {
+	kind   operandKind
+	num    float64
+	str    string // строка, метрика, имя тега или функция
+	metric string // аргумент функции
+	window time.Duration
+}
+
+func (ruleLogic) ruleNode()   {}
+func (ruleNot) ruleNode()     {}
+func (ruleFor) ruleNode()     {}
+func (ruleCompare) ruleNode() {}
+
+func (o *ruleOperand) isString() bool {
+	return o.kind == operandString || o.kind == operandSensor || o.kind == operandTag
+}
+
+// ParseRule разбирает и проверяет правило.
+func ParseRule(src string) (*Rule, error) {
+	tokens, err := lexRule(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{Source: src, root: root}
+	if p.keyword("by") {
+		t := p.next()
+		switch {
+		case t.kind == tokIdent && (t.text == "sensor" || t.text == "sensor_id"):
+		case t.kind == tokIdent && strings.HasPrefix(t.text, "tags.") && len(t.text) > len("tags."):
+			rule.GroupBy = strings.TrimPrefix(t.text, "tags.")
+		default:
+			return nil, fmt.Errorf("position %d: expected sensor or tags.<name> after by", t.pos)
+		}
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("position %d: unexpected %q", t.pos, t.text)
+	}
+	return rule, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokDuration
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type ruleToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexRule(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, ruleToken{tokLParen, "(", start})
+			i++
+		case c == ')':
+			tokens = append(tokens, ruleToken{tokRParen, ")", start})
+			i++
+		case c == ',':
+			tokens = append(tokens, ruleToken{tokComma, ",", start})
+			i++
+		case c == '"' || c == '\'':
+			i++
+			for i < len(runes) && runes[i] != c {
+				i++
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("position %d: unterminated string", start)
+			}
+			tokens = append(tokens, ruleToken{tokString, string(runes[start+1 : i]), start})
+			i++
+		case strings.ContainsRune("<>=!", c):
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("position %d: expected != ", start)
+			}
+			if op == "=" {
+				op = "=="
+			}
+			tokens = append(tokens, ruleToken{tokOp, op, start})
+		case unicode.IsDigit(c) || c == '.' || c == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokNumber
+			if i < len(runes) && unicode.IsLetter(runes[i]) {
+				kind = tokDuration
+				for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.') {
+					i++
+				}
+			}
+			tokens = append(tokens, ruleToken{kind, string(runes[start:i]), start})
+		case unicode.IsLetter(c) || c == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{tokIdent, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("position %d: unexpected character %q", start, c)
+		}
+	}
+	return append(tokens, ruleToken{tokEOF, "end of rule", len(runes)}), nil
+}
+
+// parseRuleDuration понимает длительности Go и дни: 7d.
+func parseRuleDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	i      int
+}
+
+func (p *ruleParser) peek() ruleToken { return p.tokens[p.i] }
+
+func (p *ruleParser) next() ruleToken {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// keyword съедает ключевое слово (без учёта регистра), если оно следующее.
+func (p *ruleParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokIdent && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) expr() (ruleNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &ruleLogic{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) and() (ruleNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &ruleLogic{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) unary() (ruleNode, error) {
+	if p.keyword("not") {
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleNot{expr: expr}, nil
+	}
+
+	node, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("for") {
+		t := p.next()
+		if t.kind != tokDuration {
+			return nil, fmt.Errorf("position %d: expected duration after for", t.pos)
+		}
+		d, err := parseRuleDuration(t.text)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("position %d: invalid duration %s", t.pos, t.text)
+		}
+		node = &ruleFor{expr: node, d: d}
+	}
+	return node, nil
+}
+
+func (p *ruleParser) atom() (ruleNode, error) {
+	if p.peek().kind == tokLParen {
+		p.next()
+		node, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, fmt.Errorf("position %d: expected )", t.pos)
+		}
+		return node, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	opTok := p.next()
+	if opTok.kind != tokOp {
+		return nil, fmt.Errorf("position %d: expected comparison, got %q", opTok.pos, opTok.text)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if left.isString() != right.isString() {
+		return nil, fmt.Errorf("position %d: cannot compare text with number", opTok.pos)
+	}
+	if left.isString() && opTok.text != "==" && opTok.text != "!=" {
+		return nil, fmt.Errorf("position %d: text supports only == and !=", opTok.pos)
+	}
+	if (left.kind == operandNumber || left.kind == operandString) && (right.kind == operandNumber || right.kind == operandString) {
+		return nil, fmt.Errorf("position %d: comparison of two constants", opTok.pos)
+	}
+	return &ruleCompare{op: opTok.text, left: left, right: right}, nil
+}
+
+func (p *ruleParser) operand() (*ruleOperand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: invalid number %s", t.pos, t.text)
+		}
+		return &ruleOperand{kind: operandNumber, num: v}, nil
+	case tokString:
+		return &ruleOperand{kind: operandString, str: t.text}, nil
+	case tokIdent:
+		if isRuleKeyword(t.text) {
+			return nil, fmt.Errorf("position %d: unexpected %s", t.pos, t.text)
+		}
+		if p.peek().kind == tokLParen {
+			return p.aggregate(t)
+		}
+		switch {
+		case t.text == "sensor" || t.text == "sensor_id":
+			return &ruleOperand{kind: operandSensor}, nil
+		case strings.HasPrefix(t.text, "tags."):
+			return &ruleOperand{kind: operandTag, str: strings.TrimPrefix(t.text, "tags.")}, nil
+		default:
+			return &ruleOperand{kind: operandMetric, str: t.text}, nil
+		}
+	default:
+		return nil, fmt.Errorf("position %d: expected value, got %q", t.pos, t.text)
+	}
+}
+
+// aggregate разбирает func(metric, window); имя функции уже прочитано.
+func (p *ruleParser) aggregate(fn ruleToken) (*ruleOperand, error) {
+	name := strings.ToLower(fn.text)
+	known := false
+	for _, a := range RuleAggregates {
+		known = known || a == name
+	}
+	if !known {
+		return nil, fmt.Errorf("position %d: unknown function %s, supported: %s", fn.pos, fn.text, strings.Join(RuleAggregates, ", "))
+	}
+
+	p.next() // (
+	metric := p.next()
+	if metric.kind != tokIdent || isRuleKeyword(metric.text) || metric.text == "sensor" || strings.HasPrefix(metric.text, "tags.") {
+		return nil, fmt.Errorf("position %d: %s expects a metric", metric.pos, name)
+	}
+	if t := p.next(); t.kind != tokComma {
+		return nil, fmt.Errorf("position %d: expected , and window", t.pos)
+	}
+	w := p.next()
+	window, err := parseRuleDuration(w.text)
+	if w.kind != tokDuration || err != nil || window <= 0 {
+		return nil, fmt.Errorf("position %d: invalid window %s", w.pos, w.text)
+	}
+	if t := p.next(); t.kind != tokRParen {
+		return nil, fmt.Errorf("position %d: expected )", t.pos)
+	}
+	return &ruleOperand{kind: operandAggregate, str: name, metric: metric.text, window: window}, nil
+}
+
+func isRuleKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "for", "by":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type ruleStep struct {
+	sensor  string
+	minute  int
+	metrics map[string]float64
+	tags    map[string]string
+}
+
+// runRule возвращает минуты записей, на которых правило сработало.
+func runRule(t *testing.T, src string, steps []ruleStep) []int {
+	t.Helper()
+	rule, err := ParseRule(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := rule.NewEvaluator()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var fired []int
+	for _, s := range steps {
+		sensor := s.sensor
+		if sensor == "" {
+			sensor = "s1"
+		}
+		in := RuleInput{SensorID: sensor, Time: start.Add(time.Duration(s.minute) * time.Minute), Metrics: s.metrics, Tags: s.tags}
+		if e.Eval(in) {
+			fired = append(fired, s.minute)
+		}
+	}
+	return fired
+}
+
+func m(kv ...float64) map[string]float64 {
+	names := []string{"temperature", "humidity"}
+	out := map[string]float64{}
+	for i, v := range kv {
+		out[names[i]] = v
+	}
+	return out
+}
+
+func TestRuleForAndCondition(t *testing.T) {
+	steps := []ruleStep{
+		{minute: 0, metrics: m(85, 10)},
+		{minute: 3, metrics: m(90, 10)},
+		{minute: 5, metrics: m(91, 10)}, // 5 минут выше 80
+		{minute: 6, metrics: m(92, 10)}, // уже сработало
+		{minute: 7, metrics: m(70, 10)},
+		{minute: 8, metrics: m(85, 30)},
+	}
+	got := runRule(t, "temperature > 80 for 5m and humidity < 20", steps)
+	if len(got) != 1 || got[0] != 5 {
+		t.Fatalf("fired at %v", got)
+	}
+}
+
+func TestRuleAggregateWindow(t *testing.T) {
+	steps := []ruleStep{
+		{minute: 0, metrics: m(10)},
+		{minute: 5, metrics: m(20)},
+		{minute: 9, metrics: m(45)}, // avg(10, 20, 45) = 25
+		{minute: 16, metrics: m(40)},
+	}
+	if got := runRule(t, "avg(temperature, 10m) >= 25", steps); len(got) != 1 || got[0] != 9 {
+		t.Fatalf("fired at %v", got)
+	}
+	if got := runRule(t, "delta(temperature, 10m) > 30 or count(humidity, 1h) > 0", steps); len(got) != 1 || got[0] != 9 {
+		t.Fatalf("fired at %v", got)
+	}
+}
+
+func TestRuleScoping(t *testing.T) {
+	steps := []ruleStep{
+		{sensor: "a", minute: 0, metrics: m(90), tags: map[string]string{"site": "north"}},
+		{sensor: "b", minute: 0, metrics: m(90), tags: map[string]string{"site": "south"}},
+		{sensor: "c", minute: 1, metrics: m(95), tags: map[string]string{"site": "north"}},
+	}
+	if got := runRule(t, `temperature > 80 and tags.site == "north"`, steps); len(got) != 2 {
+		t.Fatalf("per-sensor: fired at %v", got)
+	}
+	if got := runRule(t, `sensor = 'b' and temperature > 80`, steps); len(got) != 1 {
+		t.Fatalf("sensor filter: fired at %v", got)
+	}
+	// по тегу: второй датчик площадки не даёт нового срабатывания
+	if got := runRule(t, `max(temperature, 1h) > 80 by tags.site`, steps); len(got) != 2 {
+		t.Fatalf("by tag: fired at %v", got)
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"temperature >":                      "expected value",
+		"temperature > 80 for":               "expected duration",
+		"temperature > 80 for 5x":            "invalid duration",
+		`temperature > "hot"`:                "cannot compare",
+		`sensor > "a"`:                       "only == and !=",
+		"median(temperature, 5m) > 1":        "unknown function",
+		"avg(sensor, 5m) > 1":                "expects a metric",
+		"(temperature > 1":                   "expected )",
+		"temperature > 1 humidity":           "unexpected",
+		"temperature > 1 by device":          "after by",
+		"1 > 2":                              "two constants",
+		`tags.site == "x`:                    "unterminated",
+		"temperature > 1 and and humidity":   "unexpected and",
+		"avg(temperature 5m) > 1":            "expected ,",
+		"temperature ! 1":                    "expected !=",
+		"temperature > 80 for 5m or; x > 1":  "unexpected character",
+		"count(temperature, 0s) > 1":         "invalid window",
+		"temperature > 80 for 1d and (x>1))": "unexpected",
+	} {
+		_, err := ParseRule(src)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseRule(%q) = %v, want error containing %q", src, err, want)
+		}
+	}
+}
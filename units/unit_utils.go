@@ -0,0 +1,197 @@
+// Package units — единицы метрик SensorReading: написания, перевод
+// в канонические единицы и проверка правдоподобия. Один модуль для gateway
+// (проверка сопоставления колонок при создании задания) и chunker
+// (перевод значений), чтобы списки единиц не расходились.
+package units
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Quantity string
+
+const (
+	QuantityTemperature Quantity = "temperature"
+	QuantityHumidity    Quantity = "humidity"
+	QuantityPressure    Quantity = "pressure"
+)
+
+// Канонические единицы SensorReading: градус Цельсия (производная единица СИ),
+// относительная влажность и гектопаскаль.
+const (
+	UnitCelsius          = "Cel"
+	UnitRelativeHumidity = "%RH"
+	UnitHectopascal      = "hPa"
+	// UnitAbsoluteHumidity — абсолютная влажность, г/м³. Переводится
+	// в относительную только вместе с температурой.
+	UnitAbsoluteHumidity = "g/m3"
+)
+
+var CanonicalUnits = map[Quantity]string{
+	QuantityTemperature: UnitCelsius,
+	QuantityHumidity:    UnitRelativeHumidity,
+	QuantityPressure:    UnitHectopascal,
+}
+
+// AbsoluteZero — нижняя граница температуры в градусах Цельсия.
+const AbsoluteZero = -273.15
+
+// unitAliases — написания единиц в файлах и заголовках колонок
+// (в нижнем регистре) и их обозначения в духе UCUM/SenML.
+var unitAliases = map[Quantity]map[string]string{
+	QuantityTemperature: {
+		"cel": "Cel", "c": "Cel", "°c": "Cel", "degc": "Cel", "celsius": "Cel",
+		"degf": "degF", "f": "degF", "°f": "degF", "fahrenheit": "degF",
+		"k": "K", "kelvin": "K",
+	},
+	QuantityHumidity: {
+		"%rh": "%RH", "rh": "%RH", "%": "%RH", "pct": "%RH", "percent": "%RH",
+		"/": "/", "ratio": "/", "fraction": "/",
+		"g/m3": "g/m3", "g/m³": "g/m3", "g/m^3": "g/m3",
+	},
+	QuantityPressure: {
+		"hpa": "hPa", "mbar": "hPa", "millibar": "hPa",
+		"pa": "Pa", "kpa": "kPa", "bar": "bar", "psi": "psi",
+		"mmhg": "mmHg", "torr": "mmHg", "inhg": "inHg", "atm": "atm",
+	},
+}
+
+// toCanonical переводят значения в канонические единицы. Абсолютной
+// влажности здесь нет: см. AbsoluteToRelativeHumidity.
+var toCanonical = map[Quantity]map[string]func(float64) float64{
+	QuantityTemperature: {
+		"Cel":  func(v float64) float64 { return v },
+		"degF": func(v float64) float64 { return (v - 32) * 5 / 9 },
+		"K":    func(v float64) float64 { return v + AbsoluteZero },
+	},
+	QuantityHumidity: {
+		"%RH": func(v float64) float64 { return v },
+		"/":   func(v float64) float64 { return v * 100 },
+	},
+	QuantityPressure: {
+		"hPa":  func(v float64) float64 { return v },
+		"Pa":   func(v float64) float64 { return v / 100 },
+		"kPa":  func(v float64) float64 { return v * 10 },
+		"bar":  func(v float64) float64 { return v * 1000 },
+		"psi":  func(v float64) float64 { return v * 68.9475729 },
+		"mmHg": func(v float64) float64 { return v * 1.33322387415 },
+		"inHg": func(v float64) float64 { return v * 33.8638866667 },
+		"atm":  func(v float64) float64 { return v * 1013.25 },
+	},
+}
+
+// ParseUnit приводит написание единицы к обозначению и проверяет,
+// что она подходит для величины.
+func ParseUnit(q Quantity, unit string) (string, error) {
+	aliases, ok := unitAliases[q]
+	if !ok {
+		return "", fmt.Errorf("unknown quantity %s", q)
+	}
+	u, ok := aliases[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok {
+		return "", fmt.Errorf("unknown %s unit %s", q, unit)
+	}
+	return u, nil
+}
+
+// Units — обозначения единиц величины, которые понимает ParseUnit,
+// по алфавиту.
+func Units(q Quantity) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, u := range unitAliases[q] {
+		if !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ConvertUnit переводит значение из единицы unit (любое написание)
+// в каноническую единицу величины.
+func ConvertUnit(q Quantity, unit string, v float64) (float64, error) {
+	u, err := ParseUnit(q, unit)
+	if err != nil {
+		return 0, err
+	}
+	conv, ok := toCanonical[q][u]
+	if !ok {
+		return 0, fmt.Errorf("%s in %s cannot be converted without temperature", q, u)
+	}
+	return conv(v), nil
+}
+
+// AbsoluteToRelativeHumidity переводит абсолютную влажность (г/м³)
+// в относительную при температуре tempC по формуле Магнуса.
+func AbsoluteToRelativeHumidity(gm3, tempC float64) float64 {
+	// давление насыщенного пара, гПа
+	es := 6.112 * math.Exp(17.67*tempC/(tempC+243.5))
+	// плотность насыщенного пара, г/м³
+	saturation := es * 100 * 18.01528 / (8.314462618 * (tempC - AbsoluteZero))
+	return gm3 / saturation * 100
+}
+
+// CheckPlausible отсеивает физически невозможные значения, уже
+// переведённые в канонические единицы.
+func CheckPlausible(q Quantity, v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("%s is not a finite number", q)
+	}
+	switch q {
+	case QuantityTemperature:
+		if v < AbsoluteZero {
+			return fmt.Errorf("temperature %.2f Cel is below absolute zero", v)
+		}
+	case QuantityHumidity:
+		if v < 0 || v > 100 {
+			return fmt.Errorf("relative humidity %.2f%% is out of range 0..100", v)
+		}
+	case QuantityPressure:
+		if v < 0 {
+			return fmt.Errorf("pressure %.2f hPa is negative", v)
+		}
+	}
+	return nil
+}
+
+// "temperature (°C)", "pressure [hPa]"
+var unitBracketRe = regexp.MustCompile(`^(.*?)\s*[(\[]\s*([^)\]]+?)\s*[)\]]\s*$`)
+
+// SplitColumnUnit отделяет единицу от имени колонки: "temperature (°F)" ->
+// ("temperature", "°F"), "temp_f" -> ("temp", "f"). Суффикс после _ или -
+// считается единицей, только если это известное написание.
+func SplitColumnUnit(name string) (string, string) {
+	if m := unitBracketRe.FindStringSubmatch(name); m != nil {
+		return m[1], m[2]
+	}
+	if i := strings.LastIndexAny(name, "_-"); i > 0 {
+		suffix := strings.ToLower(name[i+1:])
+		for _, aliases := range unitAliases {
+			if _, ok := aliases[suffix]; ok {
+				return name[:i], name[i+1:]
+			}
+		}
+	}
+	return name, ""
+}
+
+// UnitFromColumnName возвращает обозначение единицы из имени колонки
+// или пустую строку.
+func UnitFromColumnName(name string) string {
+	_, unit := SplitColumnUnit(name)
+	if unit == "" {
+		return ""
+	}
+	for _, q := range []Quantity{QuantityTemperature, QuantityHumidity, QuantityPressure} {
+		if u, err := ParseUnit(q, unit); err == nil {
+			return u
+		}
+	}
+	return unit
+}
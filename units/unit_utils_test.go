@@ -0,0 +1,133 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseUnitAliases(t *testing.T) {
+	tests := []struct {
+		q    Quantity
+		in   string
+		want string
+	}{
+		{QuantityTemperature, "°C", UnitCelsius},
+		{QuantityTemperature, "F", "degF"},
+		{QuantityTemperature, "kelvin", "K"},
+		{QuantityHumidity, "%", UnitRelativeHumidity},
+		{QuantityHumidity, "g/m³", UnitAbsoluteHumidity},
+		{QuantityPressure, "mbar", UnitHectopascal},
+		{QuantityPressure, " Torr ", "mmHg"},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnit(tt.q, tt.in)
+		if err != nil {
+			t.Fatalf("%s %q: %v", tt.q, tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: got %s, want %s", tt.q, tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := ParseUnit(QuantityTemperature, "hPa"); err == nil {
+		t.Fatal("expected error for pressure unit on temperature")
+	}
+}
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		q    Quantity
+		unit string
+		in   float64
+		want float64
+	}{
+		{QuantityTemperature, "degF", 212, 100},
+		{QuantityTemperature, "K", 273.15, 0},
+		{QuantityHumidity, "/", 0.45, 45},
+		{QuantityPressure, "Pa", 101325, 1013.25},
+		{QuantityPressure, "kPa", 101.325, 1013.25},
+		{QuantityPressure, "psi", 14.6959, 1013.25},
+		{QuantityPressure, "mmHg", 760, 1013.25},
+		{QuantityPressure, "inHg", 29.9213, 1013.25},
+		{QuantityPressure, "atm", 1, 1013.25},
+	}
+	for _, tt := range tests {
+		got, err := ConvertUnit(tt.q, tt.unit, tt.in)
+		if err != nil {
+			t.Fatalf("%v %s: %v", tt.in, tt.unit, err)
+		}
+		if math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("%v %s: got %v, want %v", tt.in, tt.unit, got, tt.want)
+		}
+	}
+
+	if _, err := ConvertUnit(QuantityHumidity, "g/m3", 10); err == nil {
+		t.Fatal("expected error for absolute humidity without temperature")
+	}
+}
+
+func TestAbsoluteToRelativeHumidity(t *testing.T) {
+	// насыщение при 20 °C — около 17.3 г/м³
+	if rh := AbsoluteToRelativeHumidity(17.3, 20); math.Abs(rh-100) > 1 {
+		t.Fatalf("got %v, want ~100", rh)
+	}
+	if rh := AbsoluteToRelativeHumidity(8.65, 20); math.Abs(rh-50) > 1 {
+		t.Fatalf("got %v, want ~50", rh)
+	}
+}
+
+func TestCheckPlausible(t *testing.T) {
+	tests := []struct {
+		q  Quantity
+		v  float64
+		ok bool
+	}{
+		{QuantityTemperature, -40, true},
+		{QuantityTemperature, -300, false},
+		{QuantityHumidity, 100, true},
+		{QuantityHumidity, 120, false},
+		{QuantityHumidity, -1, false},
+		{QuantityPressure, 0, true},
+		{QuantityPressure, -5, false},
+		{QuantityPressure, math.NaN(), false},
+	}
+	for _, tt := range tests {
+		err := CheckPlausible(tt.q, tt.v)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %v: err = %v", tt.q, tt.v, err)
+		}
+	}
+}
+
+func TestSplitColumnUnit(t *testing.T) {
+	tests := []struct {
+		in, base, unit, symbol string
+	}{
+		{"temperature (°F)", "temperature", "°F", "degF"},
+		{"pressure [kPa]", "pressure", "kPa", "kPa"},
+		{"temp_f", "temp", "f", "degF"},
+		{"humidity-pct", "humidity", "pct", UnitRelativeHumidity},
+		{"sensor_id", "sensor_id", "", ""},
+	}
+	for _, tt := range tests {
+		base, unit := SplitColumnUnit(tt.in)
+		if base != tt.base || unit != tt.unit {
+			t.Errorf("%q: got (%q, %q), want (%q, %q)", tt.in, base, unit, tt.base, tt.unit)
+		}
+		if got := UnitFromColumnName(tt.in); got != tt.symbol {
+			t.Errorf("%q: unit %q, want %q", tt.in, got, tt.symbol)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	got := Units(QuantityTemperature)
+	if len(got) != 3 || got[0] != "Cel" || got[1] != "K" || got[2] != "degF" {
+		t.Errorf("temperature units = %v", got)
+	}
+	for _, u := range Units(QuantityPressure) {
+		if _, err := ParseUnit(QuantityPressure, u); err != nil {
+			t.Errorf("%s: %v", u, err)
+		}
+	}
+}